@@ -10,6 +10,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of Token, e.g. the operator of an infix expression
 }
 
 type Statement interface {
@@ -24,6 +25,7 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	Comments   []token.Token // line comments, in source order, for tools such as formatters
 }
 
 func (p *Program) TokenLiteral() string {
@@ -32,6 +34,12 @@ func (p *Program) TokenLiteral() string {
 	}
 	return p.Statements[0].TokenLiteral()
 }
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) == 0 {
+		return token.Position{}
+	}
+	return p.Statements[0].Pos()
+}
 func (p *Program) String() string {
 	var buff strings.Builder
 	for _, s := range p.Statements {
@@ -41,12 +49,14 @@ func (p *Program) String() string {
 }
 
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // the opening brace
 	Statements []Statement
+	End        token.Position // position of the closing brace
 }
 
 func (b *BlockStatement) statement()           {}
 func (b *BlockStatement) TokenLiteral() string { return b.Token.Literal }
+func (b *BlockStatement) Pos() token.Position  { return b.Token.Position }
 func (b *BlockStatement) String() string {
 	var buff strings.Builder
 	for _, s := range b.Statements {
@@ -55,17 +65,21 @@ func (b *BlockStatement) String() string {
 	return buff.String()
 }
 
+// LetStatement is either a `let` or a `const` declaration, distinguished by Token.
+// Pattern is usually an *Identifier, but may destructure arrays and hashes.
 type LetStatement struct {
 	Token      token.Token
-	Identifier *Identifier
+	Pattern    Pattern
 	Expression Expression
 }
 
 func (l *LetStatement) statement()           {}
 func (l *LetStatement) TokenLiteral() string { return l.Token.Literal }
+func (l *LetStatement) Pos() token.Position  { return l.Token.Position }
+func (l *LetStatement) Constant() bool       { return l.Token.Type == token.CONST }
 func (l *LetStatement) String() string {
 	var buff strings.Builder
-	buff.WriteString(fmt.Sprintf("let %s = ", l.Identifier.Name))
+	buff.WriteString(fmt.Sprintf("%s %s = ", l.Token.Literal, l.Pattern.String()))
 	if l.Expression != nil {
 		buff.WriteString(l.Expression.String())
 	}
@@ -80,6 +94,7 @@ type ReturnStatement struct {
 
 func (r *ReturnStatement) statement()           {}
 func (r *ReturnStatement) TokenLiteral() string { return r.Token.Literal }
+func (r *ReturnStatement) Pos() token.Position  { return r.Token.Position }
 func (r *ReturnStatement) String() string {
 	var buff strings.Builder
 	buff.WriteString("return ")
@@ -90,6 +105,39 @@ func (r *ReturnStatement) String() string {
 	return buff.String()
 }
 
+type ThrowStatement struct {
+	Token      token.Token
+	Expression Expression
+}
+
+func (t *ThrowStatement) statement()           {}
+func (t *ThrowStatement) TokenLiteral() string { return t.Token.Literal }
+func (t *ThrowStatement) Pos() token.Position  { return t.Token.Position }
+func (t *ThrowStatement) String() string {
+	var buff strings.Builder
+	buff.WriteString("throw ")
+	if t.Expression != nil {
+		buff.WriteString(t.Expression.String())
+	}
+	buff.WriteString(";")
+	return buff.String()
+}
+
+// WhileStatement evaluates Body as long as Condition is truthy.
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+	Slots     int // number of slots of the scope of each iteration, set by the resolver
+}
+
+func (w *WhileStatement) statement()           {}
+func (w *WhileStatement) TokenLiteral() string { return w.Token.Literal }
+func (w *WhileStatement) Pos() token.Position  { return w.Token.Position }
+func (w *WhileStatement) String() string {
+	return fmt.Sprintf("while (%s) { %s }", w.Condition.String(), w.Body.String())
+}
+
 type ExpressionStatement struct {
 	Token      token.Token // first token of the expression
 	Expression Expression
@@ -97,6 +145,7 @@ type ExpressionStatement struct {
 
 func (e *ExpressionStatement) statement()           {}
 func (e *ExpressionStatement) TokenLiteral() string { return e.Token.Literal }
+func (e *ExpressionStatement) Pos() token.Position  { return e.Token.Position }
 func (e *ExpressionStatement) String() string {
 	var buff strings.Builder
 	if e.Expression != nil {
@@ -115,6 +164,7 @@ type IfExpression struct {
 
 func (i *IfExpression) expression()          {}
 func (i *IfExpression) TokenLiteral() string { return i.Token.Literal }
+func (i *IfExpression) Pos() token.Position  { return i.Token.Position }
 func (i *IfExpression) String() string {
 	var buff strings.Builder
 	buff.WriteString(fmt.Sprintf("if (%s) { %s }", i.Condition.String(), i.Consequence.String()))
@@ -124,30 +174,84 @@ func (i *IfExpression) String() string {
 	return buff.String()
 }
 
+// TryExpression evaluates Block, then Catch if Block raised an error, and then
+// Finally in any case. Either Catch or Finally may be nil, but not both.
+type TryExpression struct {
+	Token          token.Token
+	Block          *BlockStatement
+	CatchParameter *Identifier
+	Catch          *BlockStatement
+	CatchSlots     int // number of slots of the scope of Catch, set by the resolver
+	Finally        *BlockStatement
+}
+
+func (t *TryExpression) expression()          {}
+func (t *TryExpression) TokenLiteral() string { return t.Token.Literal }
+func (t *TryExpression) Pos() token.Position  { return t.Token.Position }
+func (t *TryExpression) String() string {
+	var buff strings.Builder
+	buff.WriteString(fmt.Sprintf("try { %s }", t.Block.String()))
+	if t.Catch != nil {
+		buff.WriteString(fmt.Sprintf(" catch (%s) { %s }", t.CatchParameter.Name, t.Catch.String()))
+	}
+	if t.Finally != nil {
+		buff.WriteString(fmt.Sprintf(" finally { %s }", t.Finally.String()))
+	}
+	return buff.String()
+}
+
 type FunctionLiteral struct {
 	Token      token.Token
-	Parameters []Identifier
+	Name       string // name of the binding the literal is assigned to by let, if any
+	Parameters []*Parameter
+	Rest       *Identifier // trailing `...rest` parameter, if any
 	Body       *BlockStatement
+	Slots      int // number of slots of the scope of a call, set by the resolver
 }
 
 func (f *FunctionLiteral) expression()          {}
 func (f *FunctionLiteral) TokenLiteral() string { return f.Token.Literal }
+func (f *FunctionLiteral) Pos() token.Position  { return f.Token.Position }
 func (f *FunctionLiteral) String() string {
-	var paramNames []string
-	for _, p := range f.Parameters {
-		paramNames = append(paramNames, p.Name)
+	return fmt.Sprintf("fn (%s) { %s }", ParametersString(f.Parameters, f.Rest), f.Body.String())
+}
+
+// Parameter is a parameter of a function literal. Default is evaluated when
+// the corresponding argument is omitted.
+type Parameter struct {
+	Pattern Pattern
+	Default Expression // optional
+}
+
+func (p *Parameter) String() string {
+	if p.Default != nil {
+		return fmt.Sprintf("%s = %s", p.Pattern.String(), p.Default.String())
+	}
+	return p.Pattern.String()
+}
+
+// ParametersString formats a parameter list as written between the parentheses of fn.
+func ParametersString(params []*Parameter, rest *Identifier) string {
+	var paramStrs []string
+	for _, p := range params {
+		paramStrs = append(paramStrs, p.String())
+	}
+	if rest != nil {
+		paramStrs = append(paramStrs, "..."+rest.Name)
 	}
-	return fmt.Sprintf("fn (%s) { %s }", strings.Join(paramNames, ", "), f.Body.String())
+	return strings.Join(paramStrs, ", ")
 }
 
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression // Identifier or FunctionLiteral
 	Arguments []Expression
+	End       token.Position // position of the closing parenthesis
 }
 
 func (c *CallExpression) expression()          {}
 func (c *CallExpression) TokenLiteral() string { return c.Token.Literal }
+func (c *CallExpression) Pos() token.Position  { return c.Token.Position }
 func (c *CallExpression) String() string {
 	var argStrs []string
 	for _, a := range c.Arguments {
@@ -156,6 +260,30 @@ func (c *CallExpression) String() string {
 	return fmt.Sprintf("%s(%s)", c.Function.String(), strings.Join(argStrs, ", "))
 }
 
+// SpreadExpression (`...args`) expands an array into the arguments of a call.
+type SpreadExpression struct {
+	Token token.Token
+	Value Expression
+}
+
+func (s *SpreadExpression) expression()          {}
+func (s *SpreadExpression) TokenLiteral() string { return s.Token.Literal }
+func (s *SpreadExpression) Pos() token.Position  { return s.Token.Position }
+func (s *SpreadExpression) String() string       { return "..." + s.Value.String() }
+
+// NamedArgument (`name: value`) passes Value to the parameter Name of the
+// function called, after the positional arguments of the call.
+type NamedArgument struct {
+	Token token.Token // the name
+	Name  string
+	Value Expression
+}
+
+func (n *NamedArgument) expression()          {}
+func (n *NamedArgument) TokenLiteral() string { return n.Token.Literal }
+func (n *NamedArgument) Pos() token.Position  { return n.Token.Position }
+func (n *NamedArgument) String() string       { return n.Name + ": " + n.Value.String() }
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -164,7 +292,10 @@ type PrefixExpression struct {
 
 func (p *PrefixExpression) expression()          {}
 func (p *PrefixExpression) TokenLiteral() string { return p.Token.Literal }
-func (p *PrefixExpression) String() string       { return fmt.Sprintf("(%s%s)", p.Operator, p.Right.String()) }
+func (p *PrefixExpression) Pos() token.Position  { return p.Token.Position }
+func (p *PrefixExpression) String() string {
+	return fmt.Sprintf("(%s%s)", p.Operator, p.Right.String())
+}
 
 type InfixExpression struct {
 	Token    token.Token
@@ -175,17 +306,30 @@ type InfixExpression struct {
 
 func (i *InfixExpression) expression()          {}
 func (i *InfixExpression) TokenLiteral() string { return i.Token.Literal }
+func (i *InfixExpression) Pos() token.Position  { return i.Token.Position }
 func (i *InfixExpression) String() string {
 	return fmt.Sprintf("(%s %s %s)", i.Left.String(), i.Operator, i.Right.String())
 }
 
 type Identifier struct {
-	Token token.Token
-	Name  string
+	Token   token.Token
+	Name    string
+	Binding *Binding // nil for names looked up by name, see Binding
+}
+
+// Binding locates the variable an identifier refers to or declares, as
+// computed by the resolver: slot Slot of the scope Depth scopes out from the
+// identifier. Names of the top level scope, which hosts and the REPL extend
+// by name, are not resolved to slots, nor are names declared nowhere in the
+// program, such as builtins.
+type Binding struct {
+	Depth int
+	Slot  int
 }
 
 func (i *Identifier) expression()          {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Position }
 func (i *Identifier) String() string       { return i.Name }
 
 type IntegerLiteral struct {
@@ -195,6 +339,7 @@ type IntegerLiteral struct {
 
 func (i *IntegerLiteral) expression()          {}
 func (i *IntegerLiteral) TokenLiteral() string { return i.Token.Literal }
+func (i *IntegerLiteral) Pos() token.Position  { return i.Token.Position }
 func (i *IntegerLiteral) String() string       { return i.Token.Literal }
 
 type BooleanLiteral struct {
@@ -204,4 +349,95 @@ type BooleanLiteral struct {
 
 func (b *BooleanLiteral) expression()          {}
 func (b *BooleanLiteral) TokenLiteral() string { return b.Token.Literal }
+func (b *BooleanLiteral) Pos() token.Position  { return b.Token.Position }
 func (b *BooleanLiteral) String() string       { return b.Token.Literal }
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (s *StringLiteral) expression()          {}
+func (s *StringLiteral) TokenLiteral() string { return s.Token.Literal }
+func (s *StringLiteral) Pos() token.Position  { return s.Token.Position }
+func (s *StringLiteral) String() string       { return `"` + s.Value + `"` }
+
+type ArrayLiteral struct {
+	Token    token.Token
+	Elements []Expression
+	End      token.Position // position of the closing bracket
+}
+
+func (a *ArrayLiteral) expression()          {}
+func (a *ArrayLiteral) TokenLiteral() string { return a.Token.Literal }
+func (a *ArrayLiteral) Pos() token.Position  { return a.Token.Position }
+func (a *ArrayLiteral) String() string {
+	var elemStrs []string
+	for _, e := range a.Elements {
+		elemStrs = append(elemStrs, e.String())
+	}
+	return fmt.Sprintf("[%s]", strings.Join(elemStrs, ", "))
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+type HashLiteral struct {
+	Token token.Token
+	Pairs []HashPair     // kept in source order
+	End   token.Position // position of the closing brace
+}
+
+func (h *HashLiteral) expression()          {}
+func (h *HashLiteral) TokenLiteral() string { return h.Token.Literal }
+func (h *HashLiteral) Pos() token.Position  { return h.Token.Position }
+func (h *HashLiteral) String() string {
+	var pairStrs []string
+	for _, p := range h.Pairs {
+		pairStrs = append(pairStrs, fmt.Sprintf("%s: %s", p.Key.String(), p.Value.String()))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(pairStrs, ", "))
+}
+
+type IndexExpression struct {
+	Token token.Token
+	Left  Expression
+	Index Expression
+}
+
+func (i *IndexExpression) expression()          {}
+func (i *IndexExpression) TokenLiteral() string { return i.Token.Literal }
+func (i *IndexExpression) Pos() token.Position  { return i.Token.Position }
+func (i *IndexExpression) String() string {
+	return fmt.Sprintf("(%s[%s])", i.Left.String(), i.Index.String())
+}
+
+// MemberExpression accesses a field or method of a host object, as in `req.header`.
+type MemberExpression struct {
+	Token  token.Token // the dot
+	Object Expression
+	Member *Identifier
+}
+
+func (m *MemberExpression) expression()          {}
+func (m *MemberExpression) TokenLiteral() string { return m.Token.Literal }
+func (m *MemberExpression) Pos() token.Position  { return m.Token.Position }
+func (m *MemberExpression) String() string {
+	return fmt.Sprintf("(%s.%s)", m.Object.String(), m.Member.Name)
+}
+
+type AssignExpression struct {
+	Token    token.Token
+	Operator string     // "=" or a compound operator such as "+="
+	Target   Expression // Identifier, IndexExpression or MemberExpression
+	Value    Expression
+}
+
+func (a *AssignExpression) expression()          {}
+func (a *AssignExpression) TokenLiteral() string { return a.Token.Literal }
+func (a *AssignExpression) Pos() token.Position  { return a.Token.Position }
+func (a *AssignExpression) String() string {
+	return fmt.Sprintf("(%s %s %s)", a.Target.String(), a.Operator, a.Value.String())
+}
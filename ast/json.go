@@ -0,0 +1,505 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/maiyama18/dog/token"
+)
+
+// EncodeJSON encodes node and its children as JSON. A node is an object
+// with its kind, the name of its Go type such as "InfixExpression", its
+// token, with type, literal and position, and its other fields named in
+// lower camel case, such as "left" and "right". Missing optional children
+// are null. Fields set by the resolver are not encoded.
+func EncodeJSON(node Node) ([]byte, error) {
+	return json.Marshal(encode(node))
+}
+
+// DecodeJSON decodes a node encoded by EncodeJSON, which must be an object.
+// Decoding a program gives the program encoded, but not resolved: call
+// parse.Resolve before evaluating it.
+func DecodeJSON(data []byte) (Node, error) {
+	if isNull(data) {
+		return nil, errors.New("ast: cannot decode null as a node")
+	}
+	d := &decoder{}
+	node := d.node(data)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return node, nil
+}
+
+func isNull(data []byte) bool {
+	return bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+}
+
+// object is a JSON object keeping its keys in order.
+type object []field
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.key)
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func nodeObject(kind string, tok token.Token, fields ...field) object {
+	return append(object{{"kind", kind}, {"token", tok}}, fields...)
+}
+
+func encode(node Node) interface{} {
+	switch n := node.(type) {
+	case nil:
+		return nil
+	case *Program:
+		comments := n.Comments
+		if comments == nil {
+			comments = []token.Token{}
+		}
+		return object{{"kind", "Program"}, {"statements", encodeStatements(n.Statements)}, {"comments", comments}}
+	case *BlockStatement:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("BlockStatement", n.Token, field{"statements", encodeStatements(n.Statements)}, field{"end", n.End})
+	case *LetStatement:
+		return nodeObject("LetStatement", n.Token, field{"pattern", encode(n.Pattern)}, field{"expression", encode(n.Expression)})
+	case *ReturnStatement:
+		return nodeObject("ReturnStatement", n.Token, field{"expression", encode(n.Expression)})
+	case *ThrowStatement:
+		return nodeObject("ThrowStatement", n.Token, field{"expression", encode(n.Expression)})
+	case *WhileStatement:
+		return nodeObject("WhileStatement", n.Token, field{"condition", encode(n.Condition)}, field{"body", encode(n.Body)})
+	case *ExpressionStatement:
+		return nodeObject("ExpressionStatement", n.Token, field{"expression", encode(n.Expression)})
+	case *IfExpression:
+		return nodeObject("IfExpression", n.Token,
+			field{"condition", encode(n.Condition)},
+			field{"consequence", encode(n.Consequence)},
+			field{"alternative", encode(n.Alternative)})
+	case *TryExpression:
+		return nodeObject("TryExpression", n.Token,
+			field{"block", encode(n.Block)},
+			field{"catchParameter", encode(n.CatchParameter)},
+			field{"catch", encode(n.Catch)},
+			field{"finally", encode(n.Finally)})
+	case *FunctionLiteral:
+		params := []interface{}{}
+		for _, p := range n.Parameters {
+			params = append(params, object{{"pattern", encode(p.Pattern)}, {"default", encode(p.Default)}})
+		}
+		return nodeObject("FunctionLiteral", n.Token,
+			field{"name", n.Name},
+			field{"parameters", params},
+			field{"rest", encode(n.Rest)},
+			field{"body", encode(n.Body)})
+	case *CallExpression:
+		return nodeObject("CallExpression", n.Token, field{"function", encode(n.Function)}, field{"arguments", encodeExpressions(n.Arguments)}, field{"end", n.End})
+	case *SpreadExpression:
+		return nodeObject("SpreadExpression", n.Token, field{"value", encode(n.Value)})
+	case *NamedArgument:
+		return nodeObject("NamedArgument", n.Token, field{"name", n.Name}, field{"value", encode(n.Value)})
+	case *PrefixExpression:
+		return nodeObject("PrefixExpression", n.Token, field{"operator", n.Operator}, field{"right", encode(n.Right)})
+	case *InfixExpression:
+		return nodeObject("InfixExpression", n.Token,
+			field{"operator", n.Operator},
+			field{"left", encode(n.Left)},
+			field{"right", encode(n.Right)})
+	case *Identifier:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("Identifier", n.Token, field{"name", n.Name})
+	case *IntegerLiteral:
+		return nodeObject("IntegerLiteral", n.Token, field{"value", n.Value})
+	case *BooleanLiteral:
+		return nodeObject("BooleanLiteral", n.Token, field{"value", n.Value})
+	case *StringLiteral:
+		return nodeObject("StringLiteral", n.Token, field{"value", n.Value})
+	case *ArrayLiteral:
+		return nodeObject("ArrayLiteral", n.Token, field{"elements", encodeExpressions(n.Elements)}, field{"end", n.End})
+	case *HashLiteral:
+		pairs := []interface{}{}
+		for _, p := range n.Pairs {
+			pairs = append(pairs, object{{"key", encode(p.Key)}, {"value", encode(p.Value)}})
+		}
+		return nodeObject("HashLiteral", n.Token, field{"pairs", pairs}, field{"end", n.End})
+	case *IndexExpression:
+		return nodeObject("IndexExpression", n.Token, field{"left", encode(n.Left)}, field{"index", encode(n.Index)})
+	case *MemberExpression:
+		return nodeObject("MemberExpression", n.Token, field{"object", encode(n.Object)}, field{"member", encode(n.Member)})
+	case *AssignExpression:
+		return nodeObject("AssignExpression", n.Token,
+			field{"operator", n.Operator},
+			field{"target", encode(n.Target)},
+			field{"value", encode(n.Value)})
+	case *WildcardPattern:
+		return nodeObject("WildcardPattern", n.Token)
+	case *LiteralPattern:
+		return nodeObject("LiteralPattern", n.Token, field{"value", encode(n.Value)})
+	case *ArrayPattern:
+		elements := []interface{}{}
+		for _, e := range n.Elements {
+			elements = append(elements, encode(e))
+		}
+		return nodeObject("ArrayPattern", n.Token, field{"elements", elements}, field{"rest", encode(n.Rest)})
+	case *HashPattern:
+		pairs := []interface{}{}
+		for _, p := range n.Pairs {
+			pairs = append(pairs, object{{"key", encode(p.Key)}, {"value", encode(p.Value)}})
+		}
+		return nodeObject("HashPattern", n.Token, field{"pairs", pairs})
+	case *MatchExpression:
+		arms := []interface{}{}
+		for _, a := range n.Arms {
+			arms = append(arms, object{{"pattern", encode(a.Pattern)}, {"guard", encode(a.Guard)}, {"body", encode(a.Body)}})
+		}
+		return nodeObject("MatchExpression", n.Token, field{"subject", encode(n.Subject)}, field{"arms", arms}, field{"end", n.End})
+	default:
+		panic(fmt.Sprintf("ast: cannot encode %T", node))
+	}
+}
+
+func encodeStatements(stmts []Statement) []interface{} {
+	encoded := []interface{}{}
+	for _, s := range stmts {
+		encoded = append(encoded, encode(s))
+	}
+	return encoded
+}
+
+func encodeExpressions(exps []Expression) []interface{} {
+	encoded := []interface{}{}
+	for _, e := range exps {
+		encoded = append(encoded, encode(e))
+	}
+	return encoded
+}
+
+// decoder decodes nodes, keeping the first error. Once it failed, it
+// returns zero values.
+type decoder struct {
+	err error
+}
+
+type fields map[string]json.RawMessage
+
+func (d *decoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("ast: "+format, args...)
+	}
+}
+
+// unmarshal decodes the value of key of f into v.
+func (d *decoder) unmarshal(f fields, key string, v interface{}) {
+	if d.err != nil {
+		return
+	}
+	raw, ok := f[key]
+	if !ok {
+		d.fail("missing field %q of %s", key, f["kind"])
+		return
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		d.fail("field %q of %s: %v", key, f["kind"], err)
+	}
+}
+
+func (d *decoder) list(f fields, key string) []json.RawMessage {
+	var list []json.RawMessage
+	d.unmarshal(f, key, &list)
+	return list
+}
+
+func (d *decoder) token(f fields) token.Token {
+	var tok token.Token
+	d.unmarshal(f, "token", &tok)
+	return tok
+}
+
+func (d *decoder) string(f fields, key string) string {
+	var s string
+	d.unmarshal(f, key, &s)
+	return s
+}
+
+// node decodes a node, or nil for null.
+func (d *decoder) node(data []byte) Node {
+	if d.err != nil || isNull(data) {
+		return nil
+	}
+	if len(data) == 0 {
+		d.fail("missing node")
+		return nil
+	}
+	var f fields
+	if err := json.Unmarshal(data, &f); err != nil {
+		d.fail("%v", err)
+		return nil
+	}
+
+	var kind string
+	d.unmarshal(f, "kind", &kind)
+	if d.err != nil {
+		return nil
+	}
+
+	switch kind {
+	case "Program":
+		program := &Program{}
+		for _, s := range d.list(f, "statements") {
+			program.Statements = append(program.Statements, d.statement(s))
+		}
+		d.unmarshal(f, "comments", &program.Comments)
+		if len(program.Comments) == 0 {
+			program.Comments = nil
+		}
+		return program
+	case "BlockStatement":
+		block := &BlockStatement{Token: d.token(f)}
+		for _, s := range d.list(f, "statements") {
+			block.Statements = append(block.Statements, d.statement(s))
+		}
+		d.unmarshal(f, "end", &block.End)
+		return block
+	case "LetStatement":
+		return &LetStatement{Token: d.token(f), Pattern: d.pattern(f["pattern"]), Expression: d.expression(f["expression"])}
+	case "ReturnStatement":
+		return &ReturnStatement{Token: d.token(f), Expression: d.optionalExpression(f["expression"])}
+	case "ThrowStatement":
+		return &ThrowStatement{Token: d.token(f), Expression: d.expression(f["expression"])}
+	case "WhileStatement":
+		return &WhileStatement{Token: d.token(f), Condition: d.expression(f["condition"]), Body: d.block(f["body"])}
+	case "ExpressionStatement":
+		return &ExpressionStatement{Token: d.token(f), Expression: d.expression(f["expression"])}
+	case "IfExpression":
+		return &IfExpression{
+			Token:       d.token(f),
+			Condition:   d.expression(f["condition"]),
+			Consequence: d.block(f["consequence"]),
+			Alternative: d.optionalBlock(f["alternative"]),
+		}
+	case "TryExpression":
+		try := &TryExpression{
+			Token:          d.token(f),
+			Block:          d.block(f["block"]),
+			CatchParameter: d.optionalIdentifier(f["catchParameter"]),
+			Catch:          d.optionalBlock(f["catch"]),
+			Finally:        d.optionalBlock(f["finally"]),
+		}
+		if (try.Catch == nil) != (try.CatchParameter == nil) {
+			d.fail("catch and catchParameter of TryExpression must be both null or both set")
+		}
+		return try
+	case "FunctionLiteral":
+		function := &FunctionLiteral{Token: d.token(f), Name: d.string(f, "name")}
+		for _, raw := range d.list(f, "parameters") {
+			p := d.fields(raw)
+			function.Parameters = append(function.Parameters, &Parameter{Pattern: d.pattern(p["pattern"]), Default: d.optionalExpression(p["default"])})
+		}
+		function.Rest = d.optionalIdentifier(f["rest"])
+		function.Body = d.block(f["body"])
+		return function
+	case "CallExpression":
+		call := &CallExpression{Token: d.token(f), Function: d.expression(f["function"]), Arguments: d.expressions(f, "arguments")}
+		d.unmarshal(f, "end", &call.End)
+		return call
+	case "SpreadExpression":
+		return &SpreadExpression{Token: d.token(f), Value: d.expression(f["value"])}
+	case "NamedArgument":
+		return &NamedArgument{Token: d.token(f), Name: d.string(f, "name"), Value: d.expression(f["value"])}
+	case "PrefixExpression":
+		return &PrefixExpression{Token: d.token(f), Operator: d.string(f, "operator"), Right: d.expression(f["right"])}
+	case "InfixExpression":
+		return &InfixExpression{
+			Token:    d.token(f),
+			Operator: d.string(f, "operator"),
+			Left:     d.expression(f["left"]),
+			Right:    d.expression(f["right"]),
+		}
+	case "Identifier":
+		return &Identifier{Token: d.token(f), Name: d.string(f, "name")}
+	case "IntegerLiteral":
+		lit := &IntegerLiteral{Token: d.token(f)}
+		d.unmarshal(f, "value", &lit.Value)
+		return lit
+	case "BooleanLiteral":
+		lit := &BooleanLiteral{Token: d.token(f)}
+		d.unmarshal(f, "value", &lit.Value)
+		return lit
+	case "StringLiteral":
+		return &StringLiteral{Token: d.token(f), Value: d.string(f, "value")}
+	case "ArrayLiteral":
+		array := &ArrayLiteral{Token: d.token(f), Elements: d.expressions(f, "elements")}
+		d.unmarshal(f, "end", &array.End)
+		return array
+	case "HashLiteral":
+		hash := &HashLiteral{Token: d.token(f)}
+		for _, raw := range d.list(f, "pairs") {
+			p := d.fields(raw)
+			hash.Pairs = append(hash.Pairs, HashPair{Key: d.expression(p["key"]), Value: d.expression(p["value"])})
+		}
+		d.unmarshal(f, "end", &hash.End)
+		return hash
+	case "IndexExpression":
+		return &IndexExpression{Token: d.token(f), Left: d.expression(f["left"]), Index: d.expression(f["index"])}
+	case "MemberExpression":
+		return &MemberExpression{Token: d.token(f), Object: d.expression(f["object"]), Member: d.identifier(f["member"])}
+	case "AssignExpression":
+		return &AssignExpression{
+			Token:    d.token(f),
+			Operator: d.string(f, "operator"),
+			Target:   d.expression(f["target"]),
+			Value:    d.expression(f["value"]),
+		}
+	case "WildcardPattern":
+		return &WildcardPattern{Token: d.token(f)}
+	case "LiteralPattern":
+		return &LiteralPattern{Token: d.token(f), Value: d.expression(f["value"])}
+	case "ArrayPattern":
+		pattern := &ArrayPattern{Token: d.token(f)}
+		for _, raw := range d.list(f, "elements") {
+			pattern.Elements = append(pattern.Elements, d.pattern(raw))
+		}
+		pattern.Rest = d.optionalIdentifier(f["rest"])
+		return pattern
+	case "HashPattern":
+		pattern := &HashPattern{Token: d.token(f)}
+		for _, raw := range d.list(f, "pairs") {
+			p := d.fields(raw)
+			pattern.Pairs = append(pattern.Pairs, HashPatternPair{Key: d.expression(p["key"]), Value: d.pattern(p["value"])})
+		}
+		return pattern
+	case "MatchExpression":
+		match := &MatchExpression{Token: d.token(f), Subject: d.expression(f["subject"])}
+		for _, raw := range d.list(f, "arms") {
+			a := d.fields(raw)
+			match.Arms = append(match.Arms, MatchArm{Pattern: d.pattern(a["pattern"]), Guard: d.optionalExpression(a["guard"]), Body: d.expression(a["body"])})
+		}
+		d.unmarshal(f, "end", &match.End)
+		return match
+	default:
+		d.fail("unknown kind %q", kind)
+		return nil
+	}
+}
+
+func (d *decoder) fields(data []byte) fields {
+	var f fields
+	if d.err != nil {
+		return f
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		d.fail("%v", err)
+	}
+	return f
+}
+
+func (d *decoder) expressions(f fields, key string) []Expression {
+	var exps []Expression
+	for _, raw := range d.list(f, key) {
+		exps = append(exps, d.expression(raw))
+	}
+	return exps
+}
+
+// The following decode a node which must be of the given type. The optional
+// ones return nil for null, which the others reject, as the nodes holding
+// them cannot do without them.
+
+func (d *decoder) statement(data []byte) Statement {
+	node := d.required(data)
+	stmt, ok := node.(Statement)
+	if !ok {
+		d.fail("%T is not a statement", node)
+	}
+	return stmt
+}
+
+func (d *decoder) expression(data []byte) Expression {
+	node := d.required(data)
+	exp, ok := node.(Expression)
+	if !ok {
+		d.fail("%T is not an expression", node)
+	}
+	return exp
+}
+
+func (d *decoder) optionalExpression(data []byte) Expression {
+	if isNull(data) {
+		return nil
+	}
+	return d.expression(data)
+}
+
+func (d *decoder) pattern(data []byte) Pattern {
+	node := d.required(data)
+	pattern, ok := node.(Pattern)
+	if !ok {
+		d.fail("%T is not a pattern", node)
+	}
+	return pattern
+}
+
+func (d *decoder) block(data []byte) *BlockStatement {
+	node := d.required(data)
+	block, ok := node.(*BlockStatement)
+	if !ok {
+		d.fail("%T is not a block", node)
+	}
+	return block
+}
+
+func (d *decoder) optionalBlock(data []byte) *BlockStatement {
+	if isNull(data) {
+		return nil
+	}
+	return d.block(data)
+}
+
+func (d *decoder) identifier(data []byte) *Identifier {
+	node := d.required(data)
+	ident, ok := node.(*Identifier)
+	if !ok {
+		d.fail("%T is not an identifier", node)
+	}
+	return ident
+}
+
+func (d *decoder) optionalIdentifier(data []byte) *Identifier {
+	if isNull(data) {
+		return nil
+	}
+	return d.identifier(data)
+}
+
+// required decodes a node, failing for null.
+func (d *decoder) required(data []byte) Node {
+	if d.err == nil && isNull(data) {
+		d.fail("unexpected null node")
+	}
+	return d.node(data)
+}
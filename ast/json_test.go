@@ -0,0 +1,117 @@
+package ast_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/parse"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	inputs := map[string]string{
+		"everyNode": everyNode,
+		"empty":     "",
+		"comments":  "// leading\nlet f = fn() {}; // trailing\nf()",
+		"optional":  "let f = fn(a) { if (a) { return a } }; let [x] = [1]; match (x) { _ => try { 1 } finally {} }",
+	}
+	paths, err := filepath.Glob(filepath.Join("..", "conformance", "testdata", "*.dog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs[path] = string(src)
+	}
+
+	for name, input := range inputs {
+		program := parseProgram(t, input)
+		data, err := ast.EncodeJSON(program)
+		if err != nil {
+			t.Fatalf("%s: EncodeJSON returned error: %v", name, err)
+		}
+		node, err := ast.DecodeJSON(data)
+		if err != nil {
+			t.Fatalf("%s: DecodeJSON returned error: %v", name, err)
+		}
+		decoded, ok := node.(*ast.Program)
+		if !ok {
+			t.Fatalf("%s: decoded node is %T, not a program", name, node)
+		}
+
+		if decoded.String() != program.String() {
+			t.Errorf("%s: String of decoded program wrong.\nwant=%s\ngot= %s", name, program.String(), decoded.String())
+		}
+		if errs := parse.Resolve(decoded); len(errs) > 0 {
+			t.Fatalf("%s: Resolve returned errors: %v", name, errs)
+		}
+		if !reflect.DeepEqual(decoded, program) {
+			t.Errorf("%s: decoded program differs from the original", name)
+		}
+		again, err := ast.EncodeJSON(decoded)
+		if err != nil {
+			t.Fatalf("%s: EncodeJSON returned error: %v", name, err)
+		}
+		if string(again) != string(data) {
+			t.Errorf("%s: encoding the decoded program wrong.\nwant=%s\ngot= %s", name, data, again)
+		}
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	program := parseProgram(t, "-a")
+	data, err := ast.EncodeJSON(program.Statements[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"kind":"ExpressionStatement","token":{"type":"-","literal":"-","position":{"line":1,"column":1}},` +
+		`"expression":{"kind":"PrefixExpression","token":{"type":"-","literal":"-","position":{"line":1,"column":1}},"operator":"-",` +
+		`"right":{"kind":"Identifier","token":{"type":"IDENT","literal":"a","position":{"line":1,"column":2}},"name":"a"}}}`
+	if string(data) != want {
+		t.Errorf("EncodeJSON wrong.\nwant=%s\ngot= %s", want, data)
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: `null`, want: "cannot decode null"},
+		{input: ` null `, want: "cannot decode null"},
+		{input: `[]`, want: "cannot unmarshal"},
+		{input: `"Program"`, want: "cannot unmarshal"},
+		{input: ``, want: "missing node"},
+		{input: `{"kind": "Unknown"}`, want: `unknown kind "Unknown"`},
+		{input: `{"kind": "Identifier", "name": "a"}`, want: `missing field "token"`},
+		{input: `{"kind": "ExpressionStatement", "token": {}}`, want: "missing node"},
+		{
+			input: `{"kind": "Program", "statements": [{"kind": "Identifier", "token": {}, "name": "a"}], "comments": []}`,
+			want:  "*ast.Identifier is not a statement",
+		},
+		{
+			input: `{"kind": "InfixExpression", "token": {}, "operator": "+", "left": null, "right": {"kind": "Identifier", "token": {}, "name": "a"}}`,
+			want:  "unexpected null node",
+		},
+		{input: `{"kind": "Program", "statements": [null], "comments": []}`, want: "unexpected null node"},
+		{input: `{"kind": "LetStatement", "token": {}, "pattern": null, "expression": null}`, want: "unexpected null node"},
+		{
+			input: `{"kind": "TryExpression", "token": {}, "block": {"kind": "BlockStatement", "token": {}, "statements": [], "end": {}}, "catchParameter": null, "catch": {"kind": "BlockStatement", "token": {}, "statements": [], "end": {}}, "finally": null}`,
+			want:  "catch and catchParameter of TryExpression must be both null or both set",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := ast.DecodeJSON([]byte(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("DecodeJSON(%s) error wrong. want containing %q, got=%v", tt.input, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/maiyama18/dog/token"
+)
+
+// Pattern is the left hand side of a match arm, a let statement or a function
+// parameter. An *Identifier is a pattern which binds the matched value to its name.
+type Pattern interface {
+	Node
+	pattern()
+}
+
+func (i *Identifier) pattern() {}
+
+// WildcardPattern (`_`) matches any value without binding it.
+type WildcardPattern struct {
+	Token token.Token
+}
+
+func (w *WildcardPattern) pattern()             {}
+func (w *WildcardPattern) TokenLiteral() string { return w.Token.Literal }
+func (w *WildcardPattern) Pos() token.Position  { return w.Token.Position }
+func (w *WildcardPattern) String() string       { return "_" }
+
+// LiteralPattern matches values equal to an integer, string or boolean literal.
+type LiteralPattern struct {
+	Token token.Token
+	Value Expression
+}
+
+func (l *LiteralPattern) pattern()             {}
+func (l *LiteralPattern) TokenLiteral() string { return l.Token.Literal }
+func (l *LiteralPattern) Pos() token.Position  { return l.Token.Position }
+func (l *LiteralPattern) String() string       { return l.Value.String() }
+
+// ArrayPattern matches arrays element by element. Without Rest the array must
+// have exactly len(Elements) elements; with Rest the remaining elements are
+// bound to it as a new array.
+type ArrayPattern struct {
+	Token    token.Token
+	Elements []Pattern
+	Rest     *Identifier
+}
+
+func (a *ArrayPattern) pattern()             {}
+func (a *ArrayPattern) TokenLiteral() string { return a.Token.Literal }
+func (a *ArrayPattern) Pos() token.Position  { return a.Token.Position }
+func (a *ArrayPattern) String() string {
+	var elemStrs []string
+	for _, e := range a.Elements {
+		elemStrs = append(elemStrs, e.String())
+	}
+	if a.Rest != nil {
+		elemStrs = append(elemStrs, ".."+a.Rest.Name)
+	}
+	return fmt.Sprintf("[%s]", strings.Join(elemStrs, ", "))
+}
+
+type HashPatternPair struct {
+	Key   Expression // literal key
+	Value Pattern
+}
+
+// HashPattern matches hashes having all of the keys of Pairs with values
+// matching the corresponding patterns. Other keys are ignored.
+type HashPattern struct {
+	Token token.Token
+	Pairs []HashPatternPair
+}
+
+func (h *HashPattern) pattern()             {}
+func (h *HashPattern) TokenLiteral() string { return h.Token.Literal }
+func (h *HashPattern) Pos() token.Position  { return h.Token.Position }
+func (h *HashPattern) String() string {
+	var pairStrs []string
+	for _, p := range h.Pairs {
+		pairStrs = append(pairStrs, fmt.Sprintf("%s: %s", p.Key.String(), p.Value.String()))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(pairStrs, ", "))
+}
+
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression // optional
+	Body    Expression
+	Slots   int // number of slots of the scope of the arm, set by the resolver
+}
+
+func (m *MatchArm) String() string {
+	if m.Guard != nil {
+		return fmt.Sprintf("%s if %s => %s", m.Pattern.String(), m.Guard.String(), m.Body.String())
+	}
+	return fmt.Sprintf("%s => %s", m.Pattern.String(), m.Body.String())
+}
+
+type MatchExpression struct {
+	Token   token.Token
+	Subject Expression
+	Arms    []MatchArm
+	End     token.Position // position of the closing brace
+}
+
+func (m *MatchExpression) expression()          {}
+func (m *MatchExpression) TokenLiteral() string { return m.Token.Literal }
+func (m *MatchExpression) Pos() token.Position  { return m.Token.Position }
+func (m *MatchExpression) String() string {
+	var armStrs []string
+	for _, a := range m.Arms {
+		armStrs = append(armStrs, a.String())
+	}
+	return fmt.Sprintf("match (%s) { %s }", m.Subject.String(), strings.Join(armStrs, ", "))
+}
@@ -0,0 +1,273 @@
+package ast
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If
+// the result visitor w is not nil, Walk visits each of the children of node
+// with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, in the order of the source:
+// it starts by calling v.Visit(node), and then walks the children of node
+// with the visitor returned, if not nil. Parameters, hash pairs and match arms
+// are not nodes, but their patterns and expressions are walked in turn.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		Walk(v, n.Pattern)
+		walkExpression(v, n.Expression)
+	case *ReturnStatement:
+		walkExpression(v, n.Expression)
+	case *ThrowStatement:
+		walkExpression(v, n.Expression)
+	case *WhileStatement:
+		Walk(v, n.Condition)
+		Walk(v, n.Body)
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+	case *IfExpression:
+		Walk(v, n.Condition)
+		Walk(v, n.Consequence)
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+	case *TryExpression:
+		Walk(v, n.Block)
+		if n.Catch != nil {
+			Walk(v, n.CatchParameter)
+			Walk(v, n.Catch)
+		}
+		if n.Finally != nil {
+			Walk(v, n.Finally)
+		}
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			Walk(v, p.Pattern)
+			walkExpression(v, p.Default)
+		}
+		if n.Rest != nil {
+			Walk(v, n.Rest)
+		}
+		Walk(v, n.Body)
+	case *CallExpression:
+		Walk(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *SpreadExpression:
+		Walk(v, n.Value)
+	case *NamedArgument:
+		Walk(v, n.Value)
+	case *PrefixExpression:
+		Walk(v, n.Right)
+	case *InfixExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *HashLiteral:
+		for _, p := range n.Pairs {
+			Walk(v, p.Key)
+			Walk(v, p.Value)
+		}
+	case *IndexExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Index)
+	case *MemberExpression:
+		Walk(v, n.Object)
+		Walk(v, n.Member)
+	case *AssignExpression:
+		Walk(v, n.Target)
+		Walk(v, n.Value)
+	case *LiteralPattern:
+		Walk(v, n.Value)
+	case *ArrayPattern:
+		for _, e := range n.Elements {
+			Walk(v, e)
+		}
+		if n.Rest != nil {
+			Walk(v, n.Rest)
+		}
+	case *HashPattern:
+		for _, p := range n.Pairs {
+			Walk(v, p.Key)
+			Walk(v, p.Value)
+		}
+	case *MatchExpression:
+		Walk(v, n.Subject)
+		for _, a := range n.Arms {
+			Walk(v, a.Pattern)
+			walkExpression(v, a.Guard)
+			Walk(v, a.Body)
+		}
+	case *Identifier, *IntegerLiteral, *BooleanLiteral, *StringLiteral, *WildcardPattern:
+		// leaves
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, stmts []Statement) {
+	for _, s := range stmts {
+		Walk(v, s)
+	}
+}
+
+func walkExpressions(v Visitor, exps []Expression) {
+	for _, e := range exps {
+		Walk(v, e)
+	}
+}
+
+// walkExpression walks an optional expression.
+func walkExpression(v Visitor, exp Expression) {
+	if exp != nil {
+		Walk(v, exp)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order like Walk: it starts by
+// calling f(node), and then inspects the children of node if f returns true.
+// After the children, it calls f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// ModifierFunc returns the node replacing node in the AST, or node itself.
+type ModifierFunc func(node Node) Node
+
+// Modify rewrites an AST bottom up: it replaces each child of node by the
+// result of Modify on it, and then returns modifier(node), so that modifier
+// sees nodes whose children are already rewritten. The children are the same
+// as for Walk. A node replacing another must fit the field holding it, e.g.
+// be an Expression for an operand or a *BlockStatement for a body; Modify
+// panics otherwise.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		modifyStatements(n.Statements, modifier)
+	case *BlockStatement:
+		modifyStatements(n.Statements, modifier)
+	case *LetStatement:
+		n.Pattern = Modify(n.Pattern, modifier).(Pattern)
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *ReturnStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *ThrowStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *WhileStatement:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Body = Modify(n.Body, modifier).(*BlockStatement)
+	case *ExpressionStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *IfExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = Modify(n.Consequence, modifier).(*BlockStatement)
+		if n.Alternative != nil {
+			n.Alternative = Modify(n.Alternative, modifier).(*BlockStatement)
+		}
+	case *TryExpression:
+		n.Block = Modify(n.Block, modifier).(*BlockStatement)
+		if n.Catch != nil {
+			n.CatchParameter = Modify(n.CatchParameter, modifier).(*Identifier)
+			n.Catch = Modify(n.Catch, modifier).(*BlockStatement)
+		}
+		if n.Finally != nil {
+			n.Finally = Modify(n.Finally, modifier).(*BlockStatement)
+		}
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			p.Pattern = Modify(p.Pattern, modifier).(Pattern)
+			p.Default = modifyExpression(p.Default, modifier)
+		}
+		if n.Rest != nil {
+			n.Rest = Modify(n.Rest, modifier).(*Identifier)
+		}
+		n.Body = Modify(n.Body, modifier).(*BlockStatement)
+	case *CallExpression:
+		n.Function = modifyExpression(n.Function, modifier)
+		modifyExpressions(n.Arguments, modifier)
+	case *SpreadExpression:
+		n.Value = modifyExpression(n.Value, modifier)
+	case *NamedArgument:
+		n.Value = modifyExpression(n.Value, modifier)
+	case *PrefixExpression:
+		n.Right = modifyExpression(n.Right, modifier)
+	case *InfixExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+	case *ArrayLiteral:
+		modifyExpressions(n.Elements, modifier)
+	case *HashLiteral:
+		for i, p := range n.Pairs {
+			n.Pairs[i] = HashPair{Key: modifyExpression(p.Key, modifier), Value: modifyExpression(p.Value, modifier)}
+		}
+	case *IndexExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Index = modifyExpression(n.Index, modifier)
+	case *MemberExpression:
+		n.Object = modifyExpression(n.Object, modifier)
+		n.Member = Modify(n.Member, modifier).(*Identifier)
+	case *AssignExpression:
+		n.Target = modifyExpression(n.Target, modifier)
+		n.Value = modifyExpression(n.Value, modifier)
+	case *LiteralPattern:
+		n.Value = modifyExpression(n.Value, modifier)
+	case *ArrayPattern:
+		for i, e := range n.Elements {
+			n.Elements[i] = Modify(e, modifier).(Pattern)
+		}
+		if n.Rest != nil {
+			n.Rest = Modify(n.Rest, modifier).(*Identifier)
+		}
+	case *HashPattern:
+		for i, p := range n.Pairs {
+			n.Pairs[i] = HashPatternPair{Key: modifyExpression(p.Key, modifier), Value: Modify(p.Value, modifier).(Pattern)}
+		}
+	case *MatchExpression:
+		n.Subject = modifyExpression(n.Subject, modifier)
+		for i := range n.Arms {
+			a := &n.Arms[i]
+			a.Pattern = Modify(a.Pattern, modifier).(Pattern)
+			a.Guard = modifyExpression(a.Guard, modifier)
+			a.Body = modifyExpression(a.Body, modifier)
+		}
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(stmts []Statement, modifier ModifierFunc) {
+	for i, s := range stmts {
+		stmts[i] = Modify(s, modifier).(Statement)
+	}
+}
+
+func modifyExpressions(exps []Expression, modifier ModifierFunc) {
+	for i, e := range exps {
+		exps[i] = modifyExpression(e, modifier)
+	}
+}
+
+// modifyExpression modifies an optional expression.
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	if exp == nil {
+		return nil
+	}
+	return Modify(exp, modifier).(Expression)
+}
@@ -0,0 +1,165 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/lex"
+	"github.com/maiyama18/dog/parse"
+)
+
+// everyNode is a program with every kind of node.
+const everyNode = `let [a, ..b] = [1, 2];
+const {"k": c} = {"k": -3};
+let f = fn(x, [y, _] = [4, 5], ...z) { return x + y; };
+while (a < 6) { a = a + 7 };
+if (a == 8) { f(...b, k: a) } else { req.header };
+try { throw 9 } catch (e) { e } finally { 10 };
+match (a) { 11 => 12, [13, ..r] if r => r[14], {"q": "s"} => true }`
+
+func TestInspect(t *testing.T) {
+	program := parseProgram(t, everyNode)
+
+	var got []string
+	depth := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return true
+		}
+		depth++
+		if lit, ok := node.(*ast.IntegerLiteral); ok {
+			got = append(got, lit.String())
+		}
+		return true
+	})
+	if depth != 0 {
+		t.Fatalf("unbalanced calls with nil: %d", depth)
+	}
+	if want := "1 2 3 4 5 6 7 8 9 10 11 12 13 14"; strings.Join(got, " ") != want {
+		t.Fatalf("integer literals wrong. want=%q, got=%q", want, strings.Join(got, " "))
+	}
+}
+
+func TestInspectPrunes(t *testing.T) {
+	program := parseProgram(t, "let f = fn(a) { a + 1 }; f(2)")
+
+	var got []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.Identifier, *ast.IntegerLiteral:
+			got = append(got, node.String())
+		}
+		return true
+	})
+	if want := "f f 2"; strings.Join(got, " ") != want {
+		t.Fatalf("nodes wrong. want=%q, got=%q", want, strings.Join(got, " "))
+	}
+}
+
+type counter map[string]int
+
+func (c counter) Visit(node ast.Node) ast.Visitor {
+	if node != nil {
+		c[fmt.Sprintf("%T", node)]++
+	}
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	c := counter{}
+	ast.Walk(c, parseProgram(t, everyNode))
+
+	want := map[string]int{
+		"*ast.Program":             1,
+		"*ast.LetStatement":        3,
+		"*ast.ArrayPattern":        3,
+		"*ast.HashPattern":         2,
+		"*ast.WildcardPattern":     1,
+		"*ast.LiteralPattern":      3,
+		"*ast.FunctionLiteral":     1,
+		"*ast.ReturnStatement":     1,
+		"*ast.WhileStatement":      1,
+		"*ast.IfExpression":        1,
+		"*ast.BlockStatement":      7,
+		"*ast.TryExpression":       1,
+		"*ast.ThrowStatement":      1,
+		"*ast.MatchExpression":     1,
+		"*ast.SpreadExpression":    1,
+		"*ast.NamedArgument":       1,
+		"*ast.MemberExpression":    1,
+		"*ast.AssignExpression":    1,
+		"*ast.IndexExpression":     1,
+		"*ast.CallExpression":      1,
+		"*ast.PrefixExpression":    1,
+		"*ast.HashLiteral":         1,
+		"*ast.ArrayLiteral":        2,
+		"*ast.BooleanLiteral":      1,
+		"*ast.StringLiteral":       4,
+		"*ast.ExpressionStatement": 8,
+	}
+	for typ, n := range want {
+		if c[typ] != n {
+			t.Errorf("visits of %s wrong. want=%d, got=%d", typ, n, c[typ])
+		}
+	}
+}
+
+func TestModify(t *testing.T) {
+	program := parseProgram(t, everyNode)
+
+	modified := ast.Modify(program, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.IntegerLiteral:
+			node.Value *= 10
+			node.Token.Literal = fmt.Sprint(node.Value)
+		case *ast.Identifier:
+			return &ast.Identifier{Token: node.Token, Name: strings.ToUpper(node.Name)}
+		}
+		return node
+	})
+
+	want := `let [A, ..B] = [10, 20];` +
+		`const {"k": C} = {"k": (-30)};` +
+		`let F = fn (X, [Y, _] = [40, 50], ...Z) { return (X + Y); };` +
+		`while ((A < 60)) { (A = (A + 70)); }` +
+		`if ((A == 80)) { F(...B, k: A); } else { (REQ.HEADER); };` +
+		`try { throw 90; } catch (E) { E; } finally { 100; };` +
+		`match (A) { 110 => 120, [130, ..R] if R => (R[140]), {"q": "s"} => true };`
+	if got := modified.String(); got != want {
+		t.Fatalf("program wrong. want=\n%s\ngot=\n%s", want, got)
+	}
+}
+
+func TestModifyReplacesStatements(t *testing.T) {
+	program := parseProgram(t, "if (a) { 1 } else { 2 }; fn(x = 3) { 4 }")
+
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		if stmt, ok := node.(*ast.ExpressionStatement); ok {
+			if lit, ok := stmt.Expression.(*ast.IntegerLiteral); ok {
+				return &ast.ReturnStatement{Token: stmt.Token, Expression: lit}
+			}
+		}
+		return node
+	})
+
+	want := "if (a) { return 1; } else { return 2; };fn (x = 3) { return 4; };"
+	if got := program.String(); got != want {
+		t.Fatalf("program wrong. want=%q, got=%q", want, got)
+	}
+}
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	parser := parse.NewParser(lex.NewLexer(input))
+	program := parser.ParseProgram()
+	if errs := parser.Errors(); len(errs) > 0 {
+		t.Fatalf("unexpected parser errors: %v", errs)
+	}
+	return program
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/maiyama18/dog"
+	"github.com/maiyama18/dog/compile"
+)
+
+// buildCommand compiles a script file to a bytecode file, which `dog run`
+// loads without parsing and compiling the script again.
+func buildCommand(args []string) error {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	output := flags.String("o", "", "output file (default: FILE with the extension .dogc)")
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		return errors.New("usage: dog build FILE [-o OUTPUT]")
+	}
+	path := flags.Arg(0)
+	// allow flags after the file, as in `dog build file.dog -o file.dogc`
+	flags.Parse(flags.Args()[1:])
+	if flags.NArg() != 0 {
+		return errors.New("usage: dog build FILE [-o OUTPUT]")
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(path, ".dog") + ".dogc"
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	bytecode, err := dog.Compile(string(src))
+	if err != nil {
+		return err
+	}
+	source := compile.Source{Path: sourcePath(path, *output), Checksum: compile.SourceChecksum(string(src))}
+	data, err := compile.Encode(bytecode, source)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*output, data, 0o644)
+}
+
+// sourcePath returns the path of the script at path as recorded in the
+// bytecode file output: relative to the directory of output, so that the two
+// can be moved together, or absolute if there is no such relative path.
+func sourcePath(path, output string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	dir, err := filepath.Abs(filepath.Dir(output))
+	if err != nil {
+		return abs
+	}
+	if rel, err := filepath.Rel(dir, abs); err == nil {
+		return rel
+	}
+	return abs
+}
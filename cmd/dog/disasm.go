@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/maiyama18/dog"
+	"github.com/maiyama18/dog/compile"
+)
+
+// disasmCommand prints the bytecode a script file compiles to.
+func disasmCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: dog disasm FILE")
+	}
+
+	src, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	bytecode, err := dog.Compile(string(src))
+	if err != nil {
+		return err
+	}
+	fmt.Print(compile.Disassemble(bytecode))
+	return nil
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/maiyama18/dog/format"
+)
+
+// fmtCommand formats script files, printing the result to the standard
+// output, or formats the standard input if no file is given.
+func fmtCommand(args []string) error {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to the file instead of the standard output")
+	diff := flags.Bool("d", false, "print a diff of the changes instead of the result")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		if *write {
+			return errors.New("usage: dog fmt [-w] [-d] FILE...")
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		return formatFile("<stdin>", src, false, *diff)
+	}
+
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := formatFile(path, src, *write, *diff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFile(path string, src []byte, write, diff bool) error {
+	formatted, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if diff {
+		os.Stdout.Write(unifiedDiff(path, src, formatted))
+	}
+	if write {
+		if bytes.Equal(src, formatted) {
+			return nil
+		}
+		return os.WriteFile(path, formatted, 0o644)
+	}
+	if !diff {
+		os.Stdout.Write(formatted)
+	}
+	return nil
+}
+
+// unifiedDiff returns the changes from a to b as a unified diff with three
+// lines of context, or nothing if they are equal.
+func unifiedDiff(path string, a, b []byte) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	// edits lists the lines of both sides, prefixed by ' ', '-' or '+'
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	const context = 3
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s (formatted)\n", path, path)
+	line := [2]int{1, 1} // next lines of a and b
+	for start := 0; start < len(edits); {
+		if edits[start][0] == ' ' {
+			line[0], line[1] = line[0]+1, line[1]+1
+			start++
+			continue
+		}
+
+		// extend the hunk while changes are closer than twice the context
+		first := max(start-context, 0)
+		end, unchanged := start, 0
+		for end < len(edits) && unchanged <= 2*context {
+			if edits[end][0] == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+			end++
+		}
+		end -= max(unchanged-context, 0)
+
+		var count [2]int
+		for _, e := range edits[first:end] {
+			if e[0] != '+' {
+				count[0]++
+			}
+			if e[0] != '-' {
+				count[1]++
+			}
+		}
+		before := start - first
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", line[0]-before, count[0], line[1]-before, count[1])
+		for _, e := range edits[first:end] {
+			out.WriteString(e + "\n")
+		}
+
+		line[0] += count[0] - before
+		line[1] += count[1] - before
+		start = end
+	}
+	return []byte(out.String())
+}
+
+// diffLines returns the shortest edit script turning x into y, as the lines
+// of both prefixed by ' ' if kept, '-' if deleted or '+' if inserted.
+func diffLines(x, y []string) []string {
+	// lines found on one side only are changed, so the search for the common
+	// lines skips them, which makes it fast for mostly rewritten files
+	count := make(map[string]int)
+	for _, l := range x {
+		count[l] |= 1
+	}
+	for _, l := range y {
+		count[l] |= 2
+	}
+	var xs, ys []int // indexes of the lines found on both sides
+	for i, l := range x {
+		if count[l] == 3 {
+			xs = append(xs, i)
+		}
+	}
+	for j, l := range y {
+		if count[l] == 3 {
+			ys = append(ys, j)
+		}
+	}
+	lines := func(s []string, indexes []int) []string {
+		out := make([]string, len(indexes))
+		for k, i := range indexes {
+			out[k] = s[i]
+		}
+		return out
+	}
+
+	var edits []string
+	i, j := 0, 0
+	for _, c := range commonLines(lines(x, xs), lines(y, ys)) {
+		for ; i < xs[c[0]]; i++ {
+			edits = append(edits, "-"+x[i])
+		}
+		for ; j < ys[c[1]]; j++ {
+			edits = append(edits, "+"+y[j])
+		}
+		edits = append(edits, " "+x[i])
+		i, j = i+1, j+1
+	}
+	for ; i < len(x); i++ {
+		edits = append(edits, "-"+x[i])
+	}
+	for ; j < len(y); j++ {
+		edits = append(edits, "+"+y[j])
+	}
+	return edits
+}
+
+// commonLines returns the indexes of the lines of a longest common
+// subsequence of x and y, in order. It uses the linear space variant of the
+// algorithm of Myers, which takes time proportional to the number of lines
+// times the number of lines not in common.
+func commonLines(x, y []string) [][2]int {
+	var common [][2]int
+	var search func(x, y []string, i0, j0 int)
+	search = func(x, y []string, i0, j0 int) {
+		prefix := 0
+		for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+			common = append(common, [2]int{i0 + prefix, j0 + prefix})
+			prefix++
+		}
+		x, y, i0, j0 = x[prefix:], y[prefix:], i0+prefix, j0+prefix
+		suffix := 0
+		for suffix < len(x) && suffix < len(y) && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+			suffix++
+		}
+		x, y = x[:len(x)-suffix], y[:len(y)-suffix]
+
+		if len(x) > 0 && len(y) > 0 {
+			// without a common prefix or suffix, at least two edits are
+			// needed, so both halves are smaller than the whole
+			i, j := middle(x, y)
+			search(x[:i], y[:j], i0, j0)
+			search(x[i:], y[j:], i0+i, j0+j)
+		}
+		for k := 0; k < suffix; k++ {
+			common = append(common, [2]int{i0 + len(x) + k, j0 + len(y) + k})
+		}
+	}
+	search(x, y, 0, 0)
+	return common
+}
+
+// middle returns a point (i, j) on a shortest edit path from x to y, found by
+// extending paths from both ends until they overlap.
+func middle(x, y []string) (int, int) {
+	n, m := len(x), len(y)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// forward[offset+k] is the furthest i reached from the start on
+	// diagonal i-j = k, backward likewise from the end
+	forward := make([]int, 2*maxD+2)
+	backward := make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+	delta := n - m
+	odd := delta%2 != 0
+
+	// the ranges of diagonals left of and right of the edit graph are skipped
+	kStart, kEnd, rStart, rEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + kStart; k <= d-kEnd; k += 2 {
+			var i int
+			if k == -d || k != d && forward[offset+k-1] < forward[offset+k+1] {
+				i = forward[offset+k+1]
+			} else {
+				i = forward[offset+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i, j = i+1, j+1
+			}
+			forward[offset+k] = i
+			switch {
+			case i > n:
+				kEnd += 2
+			case j > m:
+				kStart += 2
+			case odd:
+				if r := offset + delta - k; r >= 0 && r < len(backward) && backward[r] != -1 && i >= n-backward[r] {
+					return i, j
+				}
+			}
+		}
+
+		for k := -d + rStart; k <= d-rEnd; k += 2 {
+			var i int
+			if k == -d || k != d && backward[offset+k-1] < backward[offset+k+1] {
+				i = backward[offset+k+1]
+			} else {
+				i = backward[offset+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[n-1-i] == y[m-1-j] {
+				i, j = i+1, j+1
+			}
+			backward[offset+k] = i
+			switch {
+			case i > n:
+				rEnd += 2
+			case j > m:
+				rStart += 2
+			case !odd:
+				if f := offset + delta - k; f >= 0 && f < len(forward) && forward[f] != -1 {
+					fi := forward[f]
+					if fi >= n-i {
+						return fi, fi - (f - offset)
+					}
+				}
+			}
+		}
+	}
+	// not reached for inputs differing at both ends; deleting x before
+	// inserting y would still be a valid script
+	return n, 0
+}
+
+func splitLines(src []byte) []string {
+	return strings.Split(strings.TrimSuffix(string(src), "\n"), "\n")
+}
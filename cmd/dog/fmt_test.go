@@ -0,0 +1,114 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		x, y string
+		want string
+	}{
+		{x: "", y: "", want: ""},
+		{x: "a b c", y: "a b c", want: " a  b  c"},
+		{x: "", y: "a b", want: "+a +b"},
+		{x: "a b", y: "", want: "-a -b"},
+		{x: "a b c", y: "a c", want: " a -b  c"},
+		{x: "a c", y: "a b c", want: " a +b  c"},
+		{x: "a b c", y: "a x c", want: " a -b +x  c"},
+		{x: "a b c d e", y: "x b c d y", want: "-a +x  b  c  d -e +y"},
+		{x: "a b a b", y: "b a b a", want: "-a  b  a  b +a"},
+		{x: "x y", y: "z w", want: "-x -y +z +w"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(diffLines(strings.Fields(tt.x), strings.Fields(tt.y)), " ")
+		if got != tt.want {
+			t.Errorf("diffLines(%q, %q) wrong. want=%q, got=%q", tt.x, tt.y, tt.want, got)
+		}
+	}
+}
+
+// TestCommonLines checks commonLines against the length of a longest common
+// subsequence computed by dynamic programming, on random inputs with few
+// distinct lines so that they have many common subsequences.
+func TestCommonLines(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, r.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + r.Intn(3)))
+		}
+		return lines
+	}
+	for n := 0; n < 2000; n++ {
+		x, y := random(), random()
+		common := commonLines(x, y)
+
+		for k, c := range common {
+			if c[0] < 0 || c[0] >= len(x) || c[1] < 0 || c[1] >= len(y) || x[c[0]] != y[c[1]] {
+				t.Fatalf("commonLines(%q, %q) = %v: %v is not a common line", x, y, common, c)
+			}
+			if k > 0 && (c[0] <= common[k-1][0] || c[1] <= common[k-1][1]) {
+				t.Fatalf("commonLines(%q, %q) = %v: not in order", x, y, common)
+			}
+		}
+		if want := lcsLength(x, y); len(common) != want {
+			t.Fatalf("commonLines(%q, %q) = %v: %d lines, want %d", x, y, common, len(common), want)
+		}
+	}
+}
+
+func lcsLength(x, y []string) int {
+	prev, cur := make([]int, len(y)+1), make([]int, len(y)+1)
+	for i := range x {
+		for j := range y {
+			if x[i] == y[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(cur[j], prev[j+1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(y)]
+}
+
+func TestMiddle(t *testing.T) {
+	tests := []struct {
+		x, y string
+	}{
+		{x: "a", y: "b"},
+		{x: "a b c", y: "x y"},
+		{x: "a b c d", y: "d c b a"},
+		{x: "a b x c", y: "y a c b"},
+		{x: "b a a b a", y: "a b b a b b"},
+	}
+	for _, tt := range tests {
+		x, y := strings.Fields(tt.x), strings.Fields(tt.y)
+		i, j := middle(x, y)
+		if i < 0 || i > len(x) || j < 0 || j > len(y) {
+			t.Fatalf("middle(%q, %q) = (%d, %d): out of range", x, y, i, j)
+		}
+		// a point on a shortest edit path splits a longest common
+		// subsequence in two
+		if got, want := lcsLength(x[:i], y[:j])+lcsLength(x[i:], y[j:]), lcsLength(x, y); got != want {
+			t.Errorf("middle(%q, %q) = (%d, %d): not on a shortest edit path", x, y, i, j)
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	want := "--- f.dog\n+++ f.dog (formatted)\n" +
+		"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n" +
+		"@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+13\n"
+	if got := string(unifiedDiff("f.dog", []byte(a), []byte(b))); got != want {
+		t.Errorf("unifiedDiff wrong.\nwant:\n%s\ngot:\n%s", want, got)
+	}
+	if got := unifiedDiff("f.dog", []byte(a), []byte(a)); got != nil {
+		t.Errorf("unifiedDiff of equal files = %q, want nothing", got)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `usage: dog <command> [arguments]
+
+commands:
+  run [flags] FILE      evaluate a dog script, or run a bytecode file, and print its result
+  build FILE [-o OUT]   compile a dog script to a bytecode file (.dogc)
+  disasm FILE           print the bytecode a dog script compiles to
+  fmt [-w] [-d] FILE... format dog scripts in the canonical style
+  vet [-json] FILE...   report suspicious constructs of dog scripts
+  parse [-json] FILE    print the syntax tree of a dog script
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = runCommand(os.Args[2:])
+	case "build":
+		err = buildCommand(os.Args[2:])
+	case "disasm":
+		err = disasmCommand(os.Args[2:])
+	case "fmt":
+		err = fmtCommand(os.Args[2:])
+	case "vet":
+		err = vetCommand(os.Args[2:])
+	case "parse":
+		err = parseCommand(os.Args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
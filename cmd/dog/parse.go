@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/lex"
+	"github.com/maiyama18/dog/parse"
+)
+
+// parseCommand prints the syntax tree of a script file, as JSON or fully
+// parenthesized source.
+func parseCommand(args []string) error {
+	flags := flag.NewFlagSet("parse", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "print the syntax tree as JSON")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: dog parse [-json] FILE")
+	}
+
+	src, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	p := parse.NewParser(lex.NewLexer(string(src)))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return fmt.Errorf("%s: %w", flags.Arg(0), errs[0])
+	}
+
+	if !*jsonOutput {
+		for _, s := range program.Statements {
+			fmt.Println(s.String())
+		}
+		return nil
+	}
+	data, err := ast.EncodeJSON(program)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/maiyama18/dog"
+	"github.com/maiyama18/dog/compile"
+	"github.com/maiyama18/dog/evaluate"
+	"github.com/maiyama18/dog/object"
+)
+
+// runCommand evaluates a script file, or runs a bytecode file built by `dog
+// build` if its name ends in .dogc. Uncaught runtime errors are reported with
+// their traceback.
+func runCommand(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	maxDepth := flags.Int("max-depth", evaluate.DefaultMaxDepth, "maximum depth of the call stack, or 0 for no limit")
+	fuel := flags.Int64("fuel", 0, "maximum number of evaluation steps, or 0 for no limit")
+	maxMemory := flags.Int64("max-memory", 0, "approximate limit in bytes of the memory used by the script, or 0 for no limit")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: dog run [flags] FILE")
+	}
+
+	path := flags.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	opts := []dog.Option{dog.WithMaxDepth(*maxDepth), dog.WithFuel(*fuel)}
+	if *maxMemory > 0 {
+		opts = append(opts, dog.WithMemoryLimit(*maxMemory))
+	}
+	interpreter := dog.New(opts...)
+
+	var result object.Object
+	if strings.HasSuffix(path, ".dogc") {
+		var bytecode *compile.Bytecode
+		if bytecode, err = loadBytecode(path, src); err != nil {
+			return err
+		}
+		result, err = interpreter.Run(context.Background(), bytecode)
+	} else {
+		result, err = interpreter.Eval(context.Background(), string(src))
+	}
+	if err != nil {
+		var dogErr *dog.Error
+		if errors.As(err, &dogErr) {
+			return errors.New(dogErr.Traceback())
+		}
+		return err
+	}
+	if result != evaluate.NULL {
+		fmt.Println(result.Inspect())
+	}
+	return nil
+}
+
+// loadBytecode decodes the bytecode file at path. It rejects the file as
+// stale if the script it was built from has changed since, and skips the
+// check if that script no longer exists.
+func loadBytecode(path string, data []byte) (*compile.Bytecode, error) {
+	bytecode, source, err := compile.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if source.Path == "" {
+		return bytecode, nil
+	}
+
+	srcPath := source.Path
+	if !filepath.IsAbs(srcPath) {
+		srcPath = filepath.Join(filepath.Dir(path), srcPath)
+	}
+	if src, err := os.ReadFile(srcPath); err == nil && compile.SourceChecksum(string(src)) != source.Checksum {
+		return nil, fmt.Errorf("%s: stale bytecode, %s changed since it was built: %w", path, srcPath, compile.ErrChecksum)
+	}
+	return bytecode, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/maiyama18/dog/vet"
+)
+
+// fileDiagnostic is a diagnostic of a file, as printed by `dog vet -json`.
+type fileDiagnostic struct {
+	File string `json:"file"`
+	vet.Diagnostic
+}
+
+// vetCommand reports suspicious constructs of script files. It fails if any
+// is found.
+func vetCommand(args []string) error {
+	flags := flag.NewFlagSet("vet", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "print the diagnostics as a JSON array")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return errors.New("usage: dog vet [-json] FILE...")
+	}
+
+	diagnostics := []fileDiagnostic{}
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		found, err := vet.Source(src)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, d := range found {
+			diagnostics = append(diagnostics, fileDiagnostic{File: path, Diagnostic: d})
+		}
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diagnostics); err != nil {
+			return err
+		}
+	} else {
+		for _, d := range diagnostics {
+			fmt.Printf("%s:%s\n", d.File, d.Diagnostic)
+		}
+	}
+
+	if len(diagnostics) > 0 {
+		return fmt.Errorf("vet: %d problems found", len(diagnostics))
+	}
+	return nil
+}
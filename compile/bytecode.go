@@ -0,0 +1,124 @@
+package compile
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/maiyama18/dog/object"
+	"github.com/maiyama18/dog/token"
+)
+
+// Object types of the constants describing code rather than values.
+const (
+	CompiledFunctionType = "COMPILED_FUNCTION"
+	PatternType          = "PATTERN"
+	TryType              = "TRY"
+)
+
+// Bytecode is a compiled program: the code of its top level, the constant
+// pool shared by all of its functions and the names of the top level they
+// look up, by their index in Globals.
+type Bytecode struct {
+	Main      *Function
+	Constants []object.Object
+	Globals   []string
+}
+
+// Line maps the instructions from Offset up to the next Line to the position
+// of the source they were compiled from.
+type Line struct {
+	Offset   int
+	Position token.Position
+}
+
+// Function is a compiled unit of code: a function literal, a block of a try
+// expression, or the top level of a program. Closing it over an environment
+// makes a callable function.
+type Function struct {
+	Name         string // name of the binding the function literal is assigned to by let, if any
+	Instructions Instructions
+	Lines        []Line   // in order of Offset
+	Parameters   int      // number of parameters, excluding the rest parameter
+	Names        []string // names of the parameters, "" for destructuring ones
+	Required     int      // number of parameters without a default
+	Rest         bool     // whether the function has a rest parameter
+	Stack        bool     // whether the scopes of a call are kept on the stack rather than in environments
+	Slots        int      // number of slots of the scope of a call, or of all of its scopes if Stack
+	Source       string   // source of the function literal, as printed by Inspect
+}
+
+func (f *Function) Type() object.Type { return CompiledFunctionType }
+func (f *Function) Inspect() string   { return f.Source }
+
+// Position returns the position of the source of the instruction at offset.
+func (f *Function) Position(offset int) token.Position {
+	i := sort.Search(len(f.Lines), func(i int) bool { return f.Lines[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+	return f.Lines[i-1].Position
+}
+
+// addLine records that the instruction at offset was compiled from the source at pos.
+func (f *Function) addLine(offset int, pos token.Position) {
+	if n := len(f.Lines); n > 0 && f.Lines[n-1].Position == pos {
+		return
+	}
+	f.Lines = append(f.Lines, Line{Offset: offset, Position: pos})
+}
+
+// Try describes a try expression. Its blocks are compiled as units of code
+// run in the scope of the expression, except Catch, which runs in a scope
+// with CatchSlots slots enclosed by it, with CatchParameter bound to the
+// caught error. If CatchParameter is a stack slot, the scope of Catch is on
+// the stack too and Catch runs in the environment of the expression. Either
+// Catch or Finally may be nil, but not both.
+type Try struct {
+	Block          *Function
+	CatchParameter *Pattern // NamePattern, set with Catch
+	CatchSlots     int
+	Catch          *Function
+	Finally        *Function
+}
+
+func (t *Try) Type() object.Type { return TryType }
+func (t *Try) Inspect() string {
+	s := "try"
+	if t.Catch != nil {
+		s += fmt.Sprintf(" catch (%s)", t.CatchParameter.Name)
+	}
+	if t.Finally != nil {
+		s += " finally"
+	}
+	return s
+}
+
+type PatternKind int
+
+const (
+	WildcardPattern PatternKind = iota
+	NamePattern
+	LiteralPattern
+	ArrayPattern
+	HashPattern
+)
+
+// Pattern is a compiled pattern of a let statement, a parameter or a match
+// arm, with its literals evaluated.
+type Pattern struct {
+	Kind       PatternKind
+	Name       string         // bound by a NamePattern
+	Position   token.Position // of the name of a NamePattern
+	Local      bool           // whether the name is bound to Slot of the current scope rather than by name
+	Stack      bool           // whether the name is bound to Slot of the stack slots of the call instead
+	Slot       int
+	Value      object.Object // matched by a LiteralPattern
+	Elements   []*Pattern    // of an ArrayPattern, or the values of a HashPattern
+	Rest       *Pattern      // NamePattern for the rest of an ArrayPattern, if any
+	Keys       []object.Hashable
+	KeySources []string // keys of a HashPattern as written, for error messages
+	Source     string   // the pattern as written, for error messages
+}
+
+func (p *Pattern) Type() object.Type { return PatternType }
+func (p *Pattern) Inspect() string   { return p.Source }
@@ -0,0 +1,222 @@
+package compile
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions are a sequence of encoded instructions: an opcode followed by
+// its operands in big endian.
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota // push constant
+	OpNull
+	OpTrue
+	OpFalse
+	OpPop
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpMinus
+	OpBang
+
+	OpGetName         // push the value bound to the name of the global
+	OpCheckAssignable // fail if the name of the global cannot be assigned
+	OpAssign          // assign the top of the stack to the name of the global, leaving it
+	OpGetLocal        // push the value in the slot of the scope depth scopes out, named by the constant
+	OpLocalTarget     // check the slot named by the constant is bound, pushing its value if compound
+	OpSetLocal        // assign the top of the stack to the slot of the scope depth scopes out, leaving it
+	OpGetSlot         // push the value in the stack slot of the call, named by the constant
+	OpSlotTarget      // check the stack slot named by the constant is bound, pushing its value if compound
+	OpSetSlot         // assign the top of the stack to the stack slot of the call, leaving it
+	OpLet             // bind the popped value to the pattern constant with let
+	OpConst           // bind the popped value to the pattern constant with const
+	OpBindArgument    // bind the popped value to the pattern constant of the nth parameter
+	OpArgument        // push the nth argument and jump, if it was given
+	OpRest            // push the arguments from the nth as an array
+
+	OpArray  // pop n elements into an array
+	OpAppend // append the popped value to the array below it
+	OpExtend // append the elements of the popped array to the array below it
+	OpHash   // pop n key-value pairs into a hash
+	OpIndex
+
+	OpIndexTarget  // check the target of an index assignment, pushing its current value if compound
+	OpSetIndex     // assign the popped value to the element at the popped index of the popped object
+	OpMember       // push the member of the popped host object named by the constant
+	OpMemberTarget // check the target of a member assignment, pushing its current value if compound
+	OpSetMember    // assign the popped value to the member of the popped host object
+	OpCompound     // combine the popped current value and value with the operator of a compound assignment
+
+	OpJump
+	OpJumpIfFalse
+	OpIterate    // start an iteration of a loop
+	OpEnterScope // evaluate in a new scope with n slots enclosed by the current one
+	OpLeaveScope
+	OpMatch   // bind the top of the stack to the pattern constant, or jump if it does not match
+	OpNoMatch // fail as no arm matched the popped value
+
+	OpClosure // push the function constant closed over the current scope
+	OpCall    // call the function below the n arguments on the stack
+	OpReturn  // return the popped value from the function
+	OpEnd     // end the unit of code with the popped value
+	OpThrow
+	OpTry // evaluate the try constant
+)
+
+// Flags of OpCall.
+const (
+	CallTail   = 1 << iota // replace the frame of the current call by the call
+	CallSpread             // the arguments are an array on the stack rather than n values
+	CallNamed              // a hash of named arguments follows the others
+)
+
+// CompoundOperators are the operators of compound assignments, indexed by the operand of OpCompound.
+var CompoundOperators = []string{"+", "-", "*", "/", "%"}
+
+// Definition describes an opcode for encoding and disassembly.
+type Definition struct {
+	Name          string
+	OperandWidths []int // in bytes
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpNull:     {"OpNull", nil},
+	OpTrue:     {"OpTrue", nil},
+	OpFalse:    {"OpFalse", nil},
+	OpPop:      {"OpPop", nil},
+
+	OpAdd:         {"OpAdd", nil},
+	OpSub:         {"OpSub", nil},
+	OpMul:         {"OpMul", nil},
+	OpDiv:         {"OpDiv", nil},
+	OpMod:         {"OpMod", nil},
+	OpEqual:       {"OpEqual", nil},
+	OpNotEqual:    {"OpNotEqual", nil},
+	OpGreaterThan: {"OpGreaterThan", nil},
+	OpLessThan:    {"OpLessThan", nil},
+	OpMinus:       {"OpMinus", nil},
+	OpBang:        {"OpBang", nil},
+
+	OpGetName:         {"OpGetName", []int{2}},
+	OpCheckAssignable: {"OpCheckAssignable", []int{2}},
+	OpAssign:          {"OpAssign", []int{2}},
+	OpGetLocal:        {"OpGetLocal", []int{2, 1, 2}},
+	OpLocalTarget:     {"OpLocalTarget", []int{2, 1, 2, 1}},
+	OpSetLocal:        {"OpSetLocal", []int{2, 1, 2}},
+	OpGetSlot:         {"OpGetSlot", []int{2, 2}},
+	OpSlotTarget:      {"OpSlotTarget", []int{2, 2, 1}},
+	OpSetSlot:         {"OpSetSlot", []int{2, 2}},
+	OpLet:             {"OpLet", []int{2}},
+	OpConst:           {"OpConst", []int{2}},
+	OpBindArgument:    {"OpBindArgument", []int{2, 1}},
+	OpArgument:        {"OpArgument", []int{1, 2}},
+	OpRest:            {"OpRest", []int{1}},
+
+	OpArray:  {"OpArray", []int{2}},
+	OpAppend: {"OpAppend", nil},
+	OpExtend: {"OpExtend", nil},
+	OpHash:   {"OpHash", []int{2}},
+	OpIndex:  {"OpIndex", nil},
+
+	OpIndexTarget:  {"OpIndexTarget", []int{1}},
+	OpSetIndex:     {"OpSetIndex", nil},
+	OpMember:       {"OpMember", []int{2}},
+	OpMemberTarget: {"OpMemberTarget", []int{2, 1}},
+	OpSetMember:    {"OpSetMember", []int{2}},
+	OpCompound:     {"OpCompound", []int{1}},
+
+	OpJump:        {"OpJump", []int{2}},
+	OpJumpIfFalse: {"OpJumpIfFalse", []int{2}},
+	OpIterate:     {"OpIterate", nil},
+	OpEnterScope:  {"OpEnterScope", []int{2}},
+	OpLeaveScope:  {"OpLeaveScope", nil},
+	OpMatch:       {"OpMatch", []int{2, 2}},
+	OpNoMatch:     {"OpNoMatch", nil},
+
+	OpClosure: {"OpClosure", []int{2}},
+	OpCall:    {"OpCall", []int{2, 1}},
+	OpReturn:  {"OpReturn", nil},
+	OpEnd:     {"OpEnd", nil},
+	OpThrow:   {"OpThrow", nil},
+	OpTry:     {"OpTry", []int{2}},
+}
+
+// Lookup returns the definition of op.
+func Lookup(op Opcode) (*Definition, error) {
+	def, ok := definitions[op]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes an instruction.
+func Make(op Opcode, operands ...int) Instructions {
+	def, ok := definitions[op]
+	if !ok {
+		return Instructions{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	ins := make(Instructions, length)
+	ins[0] = byte(op)
+	offset := 1
+	for i, o := range operands {
+		putOperand(ins[offset:], def.OperandWidths[i], o)
+		offset += def.OperandWidths[i]
+	}
+	return ins
+}
+
+// ReadOperands decodes the operands of an instruction of def from ins,
+// returning them and the number of bytes they take.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+	for i, w := range def.OperandWidths {
+		operands[i] = readOperand(ins[offset:], w)
+		offset += w
+	}
+	return operands, offset
+}
+
+// ReadUint16 decodes a two byte operand.
+func ReadUint16(ins Instructions) int {
+	return int(binary.BigEndian.Uint16(ins))
+}
+
+func putOperand(ins Instructions, width, operand int) {
+	switch width {
+	case 1:
+		ins[0] = byte(operand)
+	case 2:
+		binary.BigEndian.PutUint16(ins, uint16(operand))
+	}
+}
+
+func readOperand(ins Instructions, width int) int {
+	switch width {
+	case 1:
+		return int(ins[0])
+	case 2:
+		return ReadUint16(ins)
+	default:
+		return 0
+	}
+}
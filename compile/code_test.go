@@ -0,0 +1,100 @@
+package compile
+
+import (
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		want     []byte
+	}{
+		{op: OpConstant, operands: []int{65534}, want: []byte{byte(OpConstant), 255, 254}},
+		{op: OpAdd, operands: nil, want: []byte{byte(OpAdd)}},
+		{op: OpCall, operands: []int{258, CallTail}, want: []byte{byte(OpCall), 1, 2, CallTail}},
+		{op: OpArgument, operands: []int{3, 512}, want: []byte{byte(OpArgument), 3, 2, 0}},
+	}
+	for _, test := range tests {
+		got := Make(test.op, test.operands...)
+		if string(got) != string(test.want) {
+			t.Fatalf("instruction wrong. want=%v, got=%v", test.want, got)
+		}
+
+		def, err := Lookup(test.op)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		operands, n := ReadOperands(def, got[1:])
+		if n != len(test.want)-1 {
+			t.Fatalf("operand bytes wrong. want=%d, got=%d", len(test.want)-1, n)
+		}
+		for i, want := range test.operands {
+			if operands[i] != want {
+				t.Fatalf("operand %d wrong. want=%d, got=%d", i, want, operands[i])
+			}
+		}
+	}
+}
+
+func TestFunctionPosition(t *testing.T) {
+	program := parseProgram(t, "let a = 1;\nlet b = a / 0;")
+	bytecode, err := Compile(program)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	main := bytecode.Main
+	for offset := 0; offset < len(main.Instructions); {
+		op := Opcode(main.Instructions[offset])
+		if op == OpDiv {
+			if pos := main.Position(offset); pos.String() != "2:11" {
+				t.Fatalf("position of OpDiv wrong. want=%q, got=%q", "2:11", pos)
+			}
+			return
+		}
+		def, err := Lookup(op)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, n := ReadOperands(def, main.Instructions[offset+1:])
+		offset += 1 + n
+	}
+	t.Fatalf("no OpDiv in %v", main.Instructions)
+}
+
+func TestDisassemble(t *testing.T) {
+	input := `let f = fn(x) {
+  x * 2
+};
+f(...[1])`
+	bytecode, err := Compile(parseProgram(t, input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `== <main> ==
+0000    1  OpClosure          3        ; fn f
+0003    |  OpLet              4        ; f
+0006    |  OpNull
+0007    |  OpPop
+0008    4  OpGetName          0        ; "f"
+0011    |  OpArray            0
+0014    |  OpConstant         5        ; 1
+0017    |  OpArray            1
+0020    |  OpExtend
+0021    |  OpCall             0 2      ; spread
+0025    1  OpEnd
+
+== fn f (3) ==
+0000    1  OpArgument         0 4      ; -> 0004
+0004    |  OpBindArgument     0 0      ; x
+0008    2  OpGetSlot          1 0      ; "x"
+0013    |  OpConstant         2        ; 2
+0016    |  OpMul
+0017    1  OpEnd
+`
+	if got := Disassemble(bytecode); got != want {
+		t.Fatalf("listing wrong. want=\n%s\ngot=\n%s", want, got)
+	}
+}
@@ -0,0 +1,840 @@
+// Package compile lowers programs to bytecode for the vm package.
+//
+// Every expression and statement compiles to code leaving exactly one value
+// on the operand stack, like the evaluator returns one object for each node:
+// let and while statements leave null. Bindings live in environments as in
+// the evaluator, so that compiled code shares its environments with the host
+// and the evaluator: the names the parser resolved are accessed by the depth
+// and slot of their binding, and the names of the top level by their index
+// in the globals of the bytecode. The scopes of a call of a function which
+// contains no function literal cannot be captured by a closure, so they are
+// kept in slots on the operand stack instead of in environments.
+package compile
+
+import (
+	"fmt"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/object"
+	"github.com/maiyama18/dog/token"
+)
+
+// maxOperand is the largest value of a two byte operand, which bounds the
+// number of constants and the size of a unit of code.
+const maxOperand = 1<<16 - 1
+
+// maxDepth is the largest depth of a binding, a one byte operand.
+const maxDepth = 1<<8 - 1
+
+var infixOpcodes = map[string]Opcode{
+	"+":  OpAdd,
+	"-":  OpSub,
+	"*":  OpMul,
+	"/":  OpDiv,
+	"%":  OpMod,
+	"==": OpEqual,
+	"!=": OpNotEqual,
+	">":  OpGreaterThan,
+	"<":  OpLessThan,
+}
+
+// compiler holds the state of the compilation of a program.
+type compiler struct {
+	constants []object.Object
+	names     map[string]int // indexes of the name constants
+	globals   []string
+	indexes   map[string]int // indexes of the globals
+	unit      *Function      // unit of code being compiled
+	scopes    []scope        // enclosing the code being compiled, innermost last
+	stack     *stackSlots    // of the call being compiled, if its scopes are on the stack
+}
+
+// scope is a scope of the program resolved by the parser, other than the top
+// level. Its slots are in an environment, or on the stack from offset.
+type scope struct {
+	stack  bool
+	offset int
+}
+
+// stackSlots allocates the stack slots of a call to its scopes, reusing the
+// slots of the scopes which ended for the ones which follow them.
+type stackSlots struct {
+	next int // first slot free
+	size int // number of slots needed
+}
+
+// Compile compiles program to bytecode.
+func Compile(program *ast.Program) (*Bytecode, error) {
+	c := &compiler{names: make(map[string]int), indexes: make(map[string]int)}
+	main, err := c.compileUnit(&Function{Name: "<main>"}, program.Pos(), func() error {
+		return c.compileStatements(program.Statements, program.Pos(), false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(c.constants) > maxOperand+1 {
+		return nil, fmt.Errorf("too many constants: %d", len(c.constants))
+	}
+	if len(c.globals) > maxOperand+1 {
+		return nil, fmt.Errorf("too many globals: %d", len(c.globals))
+	}
+	return &Bytecode{Main: main, Constants: c.constants, Globals: c.globals}, nil
+}
+
+// compileUnit compiles the code emitted by body into fn, ending it with OpEnd.
+func (c *compiler) compileUnit(fn *Function, pos token.Position, body func() error) (*Function, error) {
+	outer := c.unit
+	c.unit = fn
+	defer func() { c.unit = outer }()
+
+	if err := body(); err != nil {
+		return nil, err
+	}
+	c.emit(pos, OpEnd)
+	if len(fn.Instructions) > maxOperand {
+		return nil, fmt.Errorf("%s: code too large: %d bytes", pos, len(fn.Instructions))
+	}
+	return fn, nil
+}
+
+// compileStatements compiles stmts to code leaving the value of the last
+// one, or null if there are none. The last statement is in tail position if
+// tail.
+func (c *compiler) compileStatements(stmts []ast.Statement, pos token.Position, tail bool) error {
+	if len(stmts) == 0 {
+		c.emit(pos, OpNull)
+		return nil
+	}
+	for i, s := range stmts {
+		last := i == len(stmts)-1
+		if err := c.compile(s, tail && last); err != nil {
+			return err
+		}
+		if !last {
+			c.emit(s.Pos(), OpPop)
+		}
+	}
+	return nil
+}
+
+// compile compiles node. Calls of node in tail position of a function body,
+// as defined by the evaluator, replace the frame of the current call.
+func (c *compiler) compile(node ast.Node, tail bool) error {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		return c.compileStatements(node.Statements, node.Pos(), tail)
+	case *ast.ExpressionStatement:
+		return c.compile(node.Expression, tail)
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+	case *ast.ReturnStatement:
+		if err := c.compile(node.Expression, tail); err != nil {
+			return err
+		}
+		c.emit(node.Pos(), OpReturn)
+	case *ast.ThrowStatement:
+		if err := c.compile(node.Expression, false); err != nil {
+			return err
+		}
+		c.emit(node.Pos(), OpThrow)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	case *ast.TryExpression:
+		return c.compileTryExpression(node)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node, tail)
+	case *ast.MatchExpression:
+		return c.compileMatchExpression(node, tail)
+	case *ast.PrefixExpression:
+		if err := c.compile(node.Right, false); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(node.Pos(), OpBang)
+		case "-":
+			c.emit(node.Pos(), OpMinus)
+		default:
+			return fmt.Errorf("%s: unknown operator: %s", node.Pos(), node.Operator)
+		}
+	case *ast.InfixExpression:
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("%s: unknown operator: %s", node.Pos(), node.Operator)
+		}
+		if err := c.compile(node.Left, false); err != nil {
+			return err
+		}
+		if err := c.compile(node.Right, false); err != nil {
+			return err
+		}
+		c.emit(node.Pos(), op)
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+	case *ast.MemberExpression:
+		if err := c.compile(node.Object, false); err != nil {
+			return err
+		}
+		c.emit(node.Pos(), OpMember, c.name(node.Member.Name))
+	case *ast.IndexExpression:
+		if err := c.compile(node.Left, false); err != nil {
+			return err
+		}
+		if err := c.compile(node.Index, false); err != nil {
+			return err
+		}
+		c.emit(node.Pos(), OpIndex)
+	case *ast.CallExpression:
+		return c.compileCallExpression(node, tail)
+	case *ast.FunctionLiteral:
+		fn, err := c.compileFunctionLiteral(node)
+		if err != nil {
+			return err
+		}
+		c.emit(node.Pos(), OpClosure, c.addConstant(fn))
+	case *ast.Identifier:
+		if node.Binding == nil {
+			c.emit(node.Pos(), OpGetName, c.global(node.Name))
+			return nil
+		}
+		depth, slot, stack, err := c.local(node)
+		if err != nil {
+			return err
+		}
+		if stack {
+			c.emit(node.Pos(), OpGetSlot, c.name(node.Name), slot)
+		} else {
+			c.emit(node.Pos(), OpGetLocal, c.name(node.Name), depth, slot)
+		}
+	case *ast.IntegerLiteral:
+		c.emit(node.Pos(), OpConstant, c.addConstant(object.NewInteger(node.Value)))
+	case *ast.BooleanLiteral:
+		if node.Value {
+			c.emit(node.Pos(), OpTrue)
+		} else {
+			c.emit(node.Pos(), OpFalse)
+		}
+	case *ast.StringLiteral:
+		c.emit(node.Pos(), OpConstant, c.addConstant(object.NewString(node.Value)))
+	case *ast.ArrayLiteral:
+		for _, e := range node.Elements {
+			if err := c.compile(e, false); err != nil {
+				return err
+			}
+		}
+		c.emit(node.Pos(), OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		for _, p := range node.Pairs {
+			if err := c.compile(p.Key, false); err != nil {
+				return err
+			}
+			if err := c.compile(p.Value, false); err != nil {
+				return err
+			}
+		}
+		c.emit(node.Pos(), OpHash, len(node.Pairs))
+	default:
+		return fmt.Errorf("%s: cannot compile %T", node.Pos(), node)
+	}
+	return nil
+}
+
+func (c *compiler) compileLetStatement(let *ast.LetStatement) error {
+	if err := c.compile(let.Expression, false); err != nil {
+		return err
+	}
+	pattern, err := c.compilePattern(let.Pattern)
+	if err != nil {
+		return err
+	}
+
+	op := OpLet
+	if let.Constant() {
+		op = OpConst
+	}
+	c.emit(let.Pos(), op, c.addConstant(pattern))
+	c.emit(let.Pos(), OpNull)
+	return nil
+}
+
+// compileWhileStatement compiles a loop whose body runs in a new scope for
+// each iteration, like in the evaluator.
+func (c *compiler) compileWhileStatement(while *ast.WhileStatement) error {
+	start := c.emit(while.Pos(), OpIterate)
+	if err := c.compile(while.Condition, false); err != nil {
+		return err
+	}
+	exit := c.emit(while.Pos(), OpJumpIfFalse, 0)
+
+	c.enterScope(while.Pos(), while.Slots)
+	if err := c.compile(while.Body, false); err != nil {
+		return err
+	}
+	c.emit(while.Pos(), OpPop)
+	c.leaveScope(while.Pos(), while.Slots)
+	c.emit(while.Pos(), OpJump, start)
+
+	c.patch(exit, 0, c.offset())
+	c.emit(while.Pos(), OpNull)
+	return nil
+}
+
+func (c *compiler) compileTryExpression(try *ast.TryExpression) error {
+	block := func(b *ast.BlockStatement) (*Function, error) {
+		if b == nil {
+			return nil, nil
+		}
+		return c.compileUnit(&Function{Name: c.unit.Name}, b.Pos(), func() error {
+			return c.compileStatements(b.Statements, b.Pos(), false)
+		})
+	}
+
+	var t Try
+	var err error
+	if t.Block, err = block(try.Block); err != nil {
+		return err
+	}
+	if try.Catch != nil {
+		// the scope of the catch block is entered by the VM if it is an
+		// environment, so only stack slots are allocated here
+		c.pushScope(try.CatchSlots)
+		if t.CatchParameter, err = c.namePattern(try.CatchParameter); err != nil {
+			return err
+		}
+		if t.Catch, err = block(try.Catch); err != nil {
+			return err
+		}
+		if !t.CatchParameter.Stack {
+			t.CatchSlots = try.CatchSlots
+		}
+		c.popScope(try.CatchSlots)
+	}
+	if t.Finally, err = block(try.Finally); err != nil {
+		return err
+	}
+	c.emit(try.Pos(), OpTry, c.addConstant(&t))
+	return nil
+}
+
+func (c *compiler) compileIfExpression(ifExp *ast.IfExpression, tail bool) error {
+	if err := c.compile(ifExp.Condition, false); err != nil {
+		return err
+	}
+	alternative := c.emit(ifExp.Pos(), OpJumpIfFalse, 0)
+
+	if err := c.compile(ifExp.Consequence, tail); err != nil {
+		return err
+	}
+	end := c.emit(ifExp.Pos(), OpJump, 0)
+
+	c.patch(alternative, 0, c.offset())
+	if ifExp.Alternative == nil {
+		c.emit(ifExp.Pos(), OpNull)
+	} else if err := c.compile(ifExp.Alternative, tail); err != nil {
+		return err
+	}
+	c.patch(end, 0, c.offset())
+	return nil
+}
+
+// compileMatchExpression compiles the arms to code run in turn with the
+// subject on the stack, each in its own scope. The arm which matches pops
+// the subject before its body.
+func (c *compiler) compileMatchExpression(match *ast.MatchExpression, tail bool) error {
+	if err := c.compile(match.Subject, false); err != nil {
+		return err
+	}
+
+	var ends []int
+	for _, arm := range match.Arms {
+		c.enterScope(arm.Pattern.Pos(), arm.Slots)
+		pattern, err := c.compilePattern(arm.Pattern)
+		if err != nil {
+			return err
+		}
+		mismatch := []int{c.emit(arm.Pattern.Pos(), OpMatch, c.addConstant(pattern), 0)}
+		if arm.Guard != nil {
+			if err := c.compile(arm.Guard, false); err != nil {
+				return err
+			}
+			mismatch = append(mismatch, c.emit(arm.Guard.Pos(), OpJumpIfFalse, 0))
+		}
+		c.emit(arm.Pattern.Pos(), OpPop)
+		if err := c.compile(arm.Body, tail); err != nil {
+			return err
+		}
+		heap := !c.scopes[len(c.scopes)-1].stack
+		c.leaveScope(arm.Pattern.Pos(), arm.Slots)
+		ends = append(ends, c.emit(arm.Pattern.Pos(), OpJump, 0))
+
+		c.patch(mismatch[0], 1, c.offset())
+		if len(mismatch) > 1 {
+			c.patch(mismatch[1], 0, c.offset())
+		}
+		if heap {
+			c.emit(arm.Pattern.Pos(), OpLeaveScope)
+		}
+	}
+	c.emit(match.Pos(), OpNoMatch)
+
+	for _, end := range ends {
+		c.patch(end, 0, c.offset())
+	}
+	return nil
+}
+
+// compileAssignExpression compiles an assignment in the order the evaluator
+// runs it: the target is checked, and its current value read for a compound
+// assignment, before the value is evaluated.
+func (c *compiler) compileAssignExpression(assign *ast.AssignExpression) error {
+	compound := assign.Operator != "="
+	operator := -1
+	if compound {
+		for i, o := range CompoundOperators {
+			if o+"=" == assign.Operator {
+				operator = i
+			}
+		}
+		if operator < 0 {
+			return fmt.Errorf("%s: unknown operator: %s", assign.Pos(), assign.Operator)
+		}
+	}
+	value := func() error {
+		if err := c.compile(assign.Value, false); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(assign.Pos(), OpCompound, operator)
+		}
+		return nil
+	}
+
+	switch target := assign.Target.(type) {
+	case *ast.Identifier:
+		if target.Binding != nil {
+			// assignments to constants of the program are rejected by the
+			// parser, so a slot is always assignable once bound
+			depth, slot, stack, err := c.local(target)
+			if err != nil {
+				return err
+			}
+			name := c.name(target.Name)
+			if stack {
+				c.emit(assign.Pos(), OpSlotTarget, name, slot, flag(compound))
+			} else {
+				c.emit(assign.Pos(), OpLocalTarget, name, depth, slot, flag(compound))
+			}
+			if err := value(); err != nil {
+				return err
+			}
+			if stack {
+				c.emit(assign.Pos(), OpSetSlot, name, slot)
+			} else {
+				c.emit(assign.Pos(), OpSetLocal, name, depth, slot)
+			}
+			return nil
+		}
+		name := c.global(target.Name)
+		c.emit(assign.Pos(), OpCheckAssignable, name)
+		if compound {
+			c.emit(assign.Pos(), OpGetName, name)
+		}
+		if err := value(); err != nil {
+			return err
+		}
+		c.emit(assign.Pos(), OpAssign, name)
+	case *ast.IndexExpression:
+		if err := c.compile(target.Left, false); err != nil {
+			return err
+		}
+		if err := c.compile(target.Index, false); err != nil {
+			return err
+		}
+		c.emit(assign.Pos(), OpIndexTarget, flag(compound))
+		if err := value(); err != nil {
+			return err
+		}
+		c.emit(assign.Pos(), OpSetIndex)
+	case *ast.MemberExpression:
+		if err := c.compile(target.Object, false); err != nil {
+			return err
+		}
+		name := c.name(target.Member.Name)
+		c.emit(assign.Pos(), OpMemberTarget, name, flag(compound))
+		if err := value(); err != nil {
+			return err
+		}
+		c.emit(assign.Pos(), OpSetMember, name)
+	default:
+		return fmt.Errorf("%s: cannot assign to %s", assign.Pos(), assign.Target.String())
+	}
+	return nil
+}
+
+// compileCallExpression compiles a call. With spread arguments, the
+// arguments are collected into an array instead of being pushed one by one.
+// Named arguments, which follow the others, are collected into a hash from
+// their names to their values.
+func (c *compiler) compileCallExpression(call *ast.CallExpression, tail bool) error {
+	if err := c.compile(call.Function, false); err != nil {
+		return err
+	}
+
+	flags := 0
+	if tail {
+		flags |= CallTail
+	}
+	args := call.Arguments
+	var named []*ast.NamedArgument
+	for len(args) > 0 {
+		n, ok := args[len(args)-1].(*ast.NamedArgument)
+		if !ok {
+			break
+		}
+		named = append([]*ast.NamedArgument{n}, named...)
+		args = args[:len(args)-1]
+	}
+	spread := false
+	for _, a := range args {
+		if _, ok := a.(*ast.SpreadExpression); ok {
+			spread = true
+		}
+	}
+
+	argc := len(args)
+	if !spread {
+		for _, a := range args {
+			if err := c.compile(a, false); err != nil {
+				return err
+			}
+		}
+	} else {
+		argc = 0
+		flags |= CallSpread
+		c.emit(call.Pos(), OpArray, 0)
+		for _, a := range args {
+			if s, ok := a.(*ast.SpreadExpression); ok {
+				if err := c.compile(s.Value, false); err != nil {
+					return err
+				}
+				c.emit(call.Pos(), OpExtend)
+				continue
+			}
+			if err := c.compile(a, false); err != nil {
+				return err
+			}
+			c.emit(call.Pos(), OpAppend)
+		}
+	}
+
+	if len(named) > 0 {
+		flags |= CallNamed
+		for _, n := range named {
+			c.emit(n.Pos(), OpConstant, c.addConstant(object.NewString(n.Name)))
+			if err := c.compile(n.Value, false); err != nil {
+				return err
+			}
+		}
+		c.emit(call.Pos(), OpHash, len(named))
+	}
+	c.emit(call.Pos(), OpCall, argc, flags)
+	return nil
+}
+
+// compileFunctionLiteral compiles the binding of the arguments to the
+// parameters, evaluating the defaults of omitted ones, followed by the body
+// in tail position.
+func (c *compiler) compileFunctionLiteral(literal *ast.FunctionLiteral) (*Function, error) {
+	fn := &Function{
+		Name:       literal.Name,
+		Parameters: len(literal.Parameters),
+		Names:      parameterNames(literal.Parameters),
+		Rest:       literal.Rest != nil,
+		Slots:      literal.Slots,
+		Source:     literal.String(),
+	}
+
+	outer := c.stack
+	c.stack = nil
+	if !containsFunction(literal) {
+		fn.Stack = true
+		c.stack = &stackSlots{}
+	}
+	c.pushScope(literal.Slots)
+	defer func() {
+		c.popScope(literal.Slots)
+		if fn.Stack {
+			fn.Slots = c.stack.size
+		}
+		c.stack = outer
+	}()
+
+	return c.compileUnit(fn, literal.Body.Pos(), func() error {
+		for i, p := range literal.Parameters {
+			pattern, err := c.compilePattern(p.Pattern)
+			if err != nil {
+				return err
+			}
+
+			given := c.emit(p.Pattern.Pos(), OpArgument, i, 0)
+			if p.Default == nil {
+				fn.Required++
+			} else if err := c.compile(p.Default, false); err != nil {
+				return err
+			}
+			c.patch(given, 1, c.offset())
+			c.emit(p.Pattern.Pos(), OpBindArgument, c.addConstant(pattern), i)
+		}
+		if literal.Rest != nil {
+			rest, err := c.namePattern(literal.Rest)
+			if err != nil {
+				return err
+			}
+			c.emit(literal.Rest.Pos(), OpRest, len(literal.Parameters))
+			c.emit(literal.Rest.Pos(), OpBindArgument, c.addConstant(rest), len(literal.Parameters))
+		}
+		return c.compileStatements(literal.Body.Statements, literal.Body.Pos(), true)
+	})
+}
+
+// containsFunction reports whether a function literal is nested in literal,
+// which could then capture the scopes of its calls.
+func containsFunction(literal *ast.FunctionLiteral) bool {
+	found := false
+	ast.Inspect(literal, func(node ast.Node) bool {
+		if _, ok := node.(*ast.FunctionLiteral); ok && node != literal {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// parameterNames returns the names of parameters which can be passed by
+// name, "" for the destructuring ones.
+func parameterNames(parameters []*ast.Parameter) []string {
+	var names []string
+	for _, p := range parameters {
+		name := ""
+		if ident, ok := p.Pattern.(*ast.Identifier); ok {
+			name = ident.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// compilePattern compiles pattern, evaluating its literals.
+func (c *compiler) compilePattern(pattern ast.Pattern) (*Pattern, error) {
+	p := &Pattern{Source: pattern.String()}
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		p.Kind = WildcardPattern
+	case *ast.Identifier:
+		return c.namePattern(pattern)
+	case *ast.LiteralPattern:
+		value, err := literal(pattern.Value)
+		if err != nil {
+			return nil, err
+		}
+		p.Kind = LiteralPattern
+		p.Value = value
+	case *ast.ArrayPattern:
+		p.Kind = ArrayPattern
+		for _, e := range pattern.Elements {
+			element, err := c.compilePattern(e)
+			if err != nil {
+				return nil, err
+			}
+			p.Elements = append(p.Elements, element)
+		}
+		if pattern.Rest != nil {
+			rest, err := c.namePattern(pattern.Rest)
+			if err != nil {
+				return nil, err
+			}
+			p.Rest = rest
+		}
+	case *ast.HashPattern:
+		p.Kind = HashPattern
+		for _, pair := range pattern.Pairs {
+			key, err := literal(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			hashableKey, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("%s: unusable as hash key: %s", pair.Key.Pos(), key.Type())
+			}
+			value, err := c.compilePattern(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			p.Keys = append(p.Keys, hashableKey)
+			p.KeySources = append(p.KeySources, pair.Key.String())
+			p.Elements = append(p.Elements, value)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown pattern: %s", pattern.Pos(), pattern.String())
+	}
+	return p, nil
+}
+
+// namePattern compiles the pattern binding ident, in its slot if the parser
+// resolved it to one.
+func (c *compiler) namePattern(ident *ast.Identifier) (*Pattern, error) {
+	p := &Pattern{Kind: NamePattern, Name: ident.Name, Position: ident.Pos(), Source: ident.Name}
+	if ident.Binding != nil {
+		_, slot, stack, err := c.local(ident)
+		if err != nil {
+			return nil, err
+		}
+		p.Local, p.Stack, p.Slot = !stack, stack, slot
+	}
+	return p, nil
+}
+
+// local returns where the binding of ident is: its slot on the stack if
+// stack, or else its slot in the environment depth environments out from the
+// current one. It checks that they fit their operands.
+func (c *compiler) local(ident *ast.Identifier) (depth, slot int, stack bool, err error) {
+	b := ident.Binding
+	if b.Depth >= len(c.scopes) {
+		return 0, 0, false, fmt.Errorf("%s: %s is bound out of the scopes enclosing it", ident.Pos(), ident.Name)
+	}
+	s := c.scopes[len(c.scopes)-1-b.Depth]
+	slot = b.Slot
+	if s.stack {
+		stack = true
+		slot += s.offset
+	}
+	for _, inner := range c.scopes[len(c.scopes)-b.Depth:] {
+		if !inner.stack {
+			depth++
+		}
+	}
+	if depth > maxDepth {
+		return 0, 0, false, fmt.Errorf("%s: %s is nested too deeply: %d scopes", ident.Pos(), ident.Name, depth)
+	}
+	if slot > maxOperand {
+		return 0, 0, false, fmt.Errorf("%s: too many bindings in the scope of %s: %d", ident.Pos(), ident.Name, slot+1)
+	}
+	return depth, slot, stack, nil
+}
+
+// pushScope starts a scope with the given number of slots, on the stack if
+// the scopes of the call being compiled are, and popScope ends it.
+func (c *compiler) pushScope(slots int) {
+	if c.stack == nil {
+		c.scopes = append(c.scopes, scope{})
+		return
+	}
+	c.scopes = append(c.scopes, scope{stack: true, offset: c.stack.next})
+	c.stack.next += slots
+	if c.stack.next > c.stack.size {
+		c.stack.size = c.stack.next
+	}
+}
+
+func (c *compiler) popScope(slots int) {
+	if c.scopes[len(c.scopes)-1].stack {
+		c.stack.next -= slots
+	}
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+// enterScope starts a scope like pushScope, emitting the code entering it if
+// it is an environment, and leaveScope ends it likewise.
+func (c *compiler) enterScope(pos token.Position, slots int) {
+	c.pushScope(slots)
+	if !c.scopes[len(c.scopes)-1].stack {
+		c.emit(pos, OpEnterScope, slots)
+	}
+}
+
+func (c *compiler) leaveScope(pos token.Position, slots int) {
+	if !c.scopes[len(c.scopes)-1].stack {
+		c.emit(pos, OpLeaveScope)
+	}
+	c.popScope(slots)
+}
+
+// literal returns the value of a literal of a pattern.
+func literal(exp ast.Expression) (object.Object, error) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return object.NewInteger(exp.Value), nil
+	case *ast.StringLiteral:
+		return object.NewString(exp.Value), nil
+	case *ast.BooleanLiteral:
+		if exp.Value {
+			return object.TRUE, nil
+		}
+		return object.FALSE, nil
+	case *ast.PrefixExpression:
+		if integer, ok := exp.Right.(*ast.IntegerLiteral); ok && exp.Operator == "-" {
+			return object.NewInteger(-integer.Value), nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %s is not a literal", exp.Pos(), exp.String())
+}
+
+// emit appends an instruction compiled from the source at pos to the unit,
+// returning its offset.
+func (c *compiler) emit(pos token.Position, op Opcode, operands ...int) int {
+	offset := c.offset()
+	c.unit.addLine(offset, pos)
+	c.unit.Instructions = append(c.unit.Instructions, Make(op, operands...)...)
+	return offset
+}
+
+// offset returns the offset of the next instruction of the unit.
+func (c *compiler) offset() int {
+	return len(c.unit.Instructions)
+}
+
+// patch replaces the ith operand of the instruction at offset, typically the
+// target of a jump once it is known.
+func (c *compiler) patch(offset, i, operand int) {
+	op := Opcode(c.unit.Instructions[offset])
+	def := definitions[op]
+	at := offset + 1
+	for _, w := range def.OperandWidths[:i] {
+		at += w
+	}
+	putOperand(c.unit.Instructions[at:], def.OperandWidths[i], operand)
+}
+
+// addConstant adds obj to the constant pool, returning its index.
+func (c *compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// name returns the index of the string constant of a name, added once
+// however many times the name is used.
+func (c *compiler) name(name string) int {
+	if i, ok := c.names[name]; ok {
+		return i
+	}
+	i := c.addConstant(object.NewString(name))
+	c.names[name] = i
+	return i
+}
+
+// global returns the index of a name of the top level in the globals.
+func (c *compiler) global(name string) int {
+	if i, ok := c.indexes[name]; ok {
+		return i
+	}
+	c.globals = append(c.globals, name)
+	c.indexes[name] = len(c.globals) - 1
+	return len(c.globals) - 1
+}
+
+func flag(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,177 @@
+package compile
+
+import (
+	"testing"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/lex"
+	"github.com/maiyama18/dog/parse"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []Instructions
+	}{
+		{
+			input: "1 + 2",
+			want:  []Instructions{Make(OpConstant, 0), Make(OpConstant, 1), Make(OpAdd), Make(OpEnd)},
+		},
+		{
+			input: "1; 2",
+			want:  []Instructions{Make(OpConstant, 0), Make(OpPop), Make(OpConstant, 1), Make(OpEnd)},
+		},
+		{
+			input: "",
+			want:  []Instructions{Make(OpNull), Make(OpEnd)},
+		},
+		{
+			input: "let x = 1",
+			want:  []Instructions{Make(OpConstant, 0), Make(OpLet, 1), Make(OpNull), Make(OpEnd)},
+		},
+		{
+			input: "if (true) { 1 }",
+			want: []Instructions{
+				Make(OpTrue), Make(OpJumpIfFalse, 10), Make(OpConstant, 0), Make(OpJump, 11),
+				Make(OpNull), Make(OpEnd),
+			},
+		},
+		{
+			input: "x += 1",
+			want: []Instructions{
+				Make(OpCheckAssignable, 0), Make(OpGetName, 0), Make(OpConstant, 0), Make(OpCompound, 0),
+				Make(OpAssign, 0), Make(OpEnd),
+			},
+		},
+		{
+			input: "while (c) { let x = 1; x += x }",
+			want: []Instructions{
+				Make(OpIterate), Make(OpGetName, 0), Make(OpJumpIfFalse, 44), Make(OpEnterScope, 1),
+				Make(OpConstant, 0), Make(OpLet, 1), Make(OpNull), Make(OpPop),
+				Make(OpLocalTarget, 2, 0, 0, 1), Make(OpGetLocal, 2, 0, 0), Make(OpCompound, 0), Make(OpSetLocal, 2, 0, 0),
+				Make(OpPop), Make(OpLeaveScope), Make(OpJump, 0), Make(OpNull), Make(OpEnd),
+			},
+		},
+		{
+			input: "f(...a)",
+			want: []Instructions{
+				Make(OpGetName, 0), Make(OpArray, 0), Make(OpGetName, 1), Make(OpExtend),
+				Make(OpCall, 0, CallSpread), Make(OpEnd),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			bytecode, err := Compile(parseProgram(t, test.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var want Instructions
+			for _, ins := range test.want {
+				want = append(want, ins...)
+			}
+			if string(bytecode.Main.Instructions) != string(want) {
+				t.Fatalf("instructions wrong. want=%v, got=%v", want, bytecode.Main.Instructions)
+			}
+		})
+	}
+}
+
+func TestCompileTailCalls(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []int // flags of the calls in the body of the function, in order
+	}{
+		{input: "fn() { f(1) }", want: []int{CallTail}},
+		{input: "fn() { f(1); g() }", want: []int{0, CallTail}},
+		{input: "fn() { return f(1) }", want: []int{CallTail}},
+		{input: "fn() { 1 + f(1) }", want: []int{0}},
+		{input: "fn() { if (c) { f() } else { g(h()) } }", want: []int{CallTail, 0, CallTail}},
+		{input: "fn() { match (x) { 1 => f(), _ => g() } }", want: []int{CallTail, CallTail}},
+		{input: "fn() { let x = f(); x }", want: []int{0}},
+		{input: "f()", want: []int{0}},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			bytecode, err := Compile(parseProgram(t, test.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			code := bytecode.Main
+			for _, c := range bytecode.Constants {
+				if fn, ok := c.(*Function); ok {
+					code = fn
+				}
+			}
+			var got []int
+			for offset := 0; offset < len(code.Instructions); {
+				def, err := Lookup(Opcode(code.Instructions[offset]))
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				operands, n := ReadOperands(def, code.Instructions[offset+1:])
+				if Opcode(code.Instructions[offset]) == OpCall {
+					got = append(got, operands[1])
+				}
+				offset += 1 + n
+			}
+
+			if len(got) != len(test.want) {
+				t.Fatalf("calls wrong. want=%v, got=%v", test.want, got)
+			}
+			for i := range test.want {
+				if got[i] != test.want[i] {
+					t.Fatalf("flags of call %d wrong. want=%d, got=%d", i, test.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCompileStackSlots(t *testing.T) {
+	tests := []struct {
+		input string
+		stack bool
+		slots int
+	}{
+		{input: "fn(a) { let b = a; b }", stack: true, slots: 2},
+		// the scopes of the while body and of the match arms follow each
+		// other, so they share slots
+		{input: "fn(a) { while (a) { let b = a; a = b }; match (a) { [x, y] => x, z => z } }", stack: true, slots: 3},
+		{input: "fn(a) { try { a } catch (e) { let m = e; m } }", stack: true, slots: 3},
+		{input: "fn(a) { fn() { a } }", stack: false, slots: 1},
+		{input: "fn(a = fn() { 1 }) { a }", stack: false, slots: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			bytecode, err := Compile(parseProgram(t, test.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			// nested functions are added to the constants first
+			var fn *Function
+			for _, c := range bytecode.Constants {
+				if c, ok := c.(*Function); ok {
+					fn = c
+				}
+			}
+			if fn.Stack != test.stack || fn.Slots != test.slots {
+				t.Fatalf("scopes wrong. want stack=%t slots=%d, got stack=%t slots=%d", test.stack, test.slots, fn.Stack, fn.Slots)
+			}
+		})
+	}
+}
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parse.NewParser(lex.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	return program
+}
@@ -0,0 +1,149 @@
+package compile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/maiyama18/dog/object"
+)
+
+// constantTypes are the types of the constants referred to by the first
+// operand of the opcodes taking a constant.
+var constantTypes = map[Opcode][]object.Type{
+	OpConstant:     {object.IntegerType, object.StringType, object.BooleanType},
+	OpGetLocal:     {object.StringType},
+	OpLocalTarget:  {object.StringType},
+	OpSetLocal:     {object.StringType},
+	OpGetSlot:      {object.StringType},
+	OpSlotTarget:   {object.StringType},
+	OpSetSlot:      {object.StringType},
+	OpLet:          {PatternType},
+	OpConst:        {PatternType},
+	OpBindArgument: {PatternType},
+	OpMember:       {object.StringType},
+	OpMemberTarget: {object.StringType},
+	OpSetMember:    {object.StringType},
+	OpMatch:        {PatternType},
+	OpClosure:      {CompiledFunctionType},
+	OpTry:          {TryType},
+}
+
+// Disassemble returns a listing of bytecode. Each unit of code is listed
+// under a header, starting with the top level and followed by the functions
+// and try blocks in the order they appear. Each instruction is listed with its
+// offset, the source line it was compiled from ("|" for the line of the
+// previous instruction), its opcode name and operands, and the constants or
+// jump targets they refer to.
+func Disassemble(bytecode *Bytecode) string {
+	d := &disassembler{constants: bytecode.Constants, globals: bytecode.Globals}
+	d.queue("<main>", bytecode.Main)
+	for len(d.units) > 0 {
+		unit := d.units[0]
+		d.units = d.units[1:]
+		d.unit(unit.title, unit.fn)
+	}
+	return d.out.String()
+}
+
+type disassembler struct {
+	constants []object.Object
+	globals   []string
+	units     []titledFunction // to be listed
+	out       strings.Builder
+}
+
+type titledFunction struct {
+	title string
+	fn    *Function
+}
+
+func (d *disassembler) queue(title string, fn *Function) {
+	if fn != nil {
+		d.units = append(d.units, titledFunction{title: title, fn: fn})
+	}
+}
+
+func (d *disassembler) unit(title string, fn *Function) {
+	if d.out.Len() > 0 {
+		d.out.WriteString("\n")
+	}
+	fmt.Fprintf(&d.out, "== %s ==\n", title)
+
+	line := -1
+	for offset := 0; offset < len(fn.Instructions); {
+		op := Opcode(fn.Instructions[offset])
+		def, err := Lookup(op)
+		if err != nil {
+			fmt.Fprintf(&d.out, "%04d ERROR: %s\n", offset, err)
+			offset++
+			continue
+		}
+		operands, n := ReadOperands(def, fn.Instructions[offset+1:])
+
+		var operandStrs []string
+		for _, o := range operands {
+			operandStrs = append(operandStrs, strconv.Itoa(o))
+		}
+
+		source := "|"
+		if pos := fn.Position(offset); pos.Line != line {
+			line = pos.Line
+			source = strconv.Itoa(pos.Line)
+		}
+		listing := fmt.Sprintf("%04d %4s  %-18s %-8s %s", offset, source, def.Name, strings.Join(operandStrs, " "), d.comment(op, operands))
+		d.out.WriteString(strings.TrimRight(listing, " ") + "\n")
+		offset += 1 + n
+	}
+}
+
+// comment describes what the operands of an instruction refer to, queueing
+// the units of code among them to be listed.
+func (d *disassembler) comment(op Opcode, operands []int) string {
+	var refs []string
+	if _, ok := constantTypes[op]; ok {
+		switch c := d.constants[operands[0]].(type) {
+		case *Function:
+			title := "fn " + c.Name
+			if c.Name == "" {
+				title = "fn <anonymous>"
+			}
+			d.queue(fmt.Sprintf("%s (%d)", title, operands[0]), c)
+			refs = append(refs, title)
+		case *Try:
+			d.queue(fmt.Sprintf("try block (%d)", operands[0]), c.Block)
+			d.queue(fmt.Sprintf("catch block (%d)", operands[0]), c.Catch)
+			d.queue(fmt.Sprintf("finally block (%d)", operands[0]), c.Finally)
+			refs = append(refs, c.Inspect())
+		case *object.String:
+			refs = append(refs, strconv.Quote(c.Value))
+		default:
+			refs = append(refs, c.Inspect())
+		}
+	}
+
+	switch op {
+	case OpGetName, OpCheckAssignable, OpAssign:
+		refs = append(refs, strconv.Quote(d.globals[operands[0]]))
+	case OpJump, OpJumpIfFalse:
+		refs = append(refs, fmt.Sprintf("-> %04d", operands[0]))
+	case OpArgument, OpMatch:
+		refs = append(refs, fmt.Sprintf("-> %04d", operands[1]))
+	case OpCompound:
+		refs = append(refs, CompoundOperators[operands[0]]+"=")
+	case OpCall:
+		if operands[1]&CallTail != 0 {
+			refs = append(refs, "tail")
+		}
+		if operands[1]&CallSpread != 0 {
+			refs = append(refs, "spread")
+		}
+		if operands[1]&CallNamed != 0 {
+			refs = append(refs, "named")
+		}
+	}
+	if len(refs) == 0 {
+		return ""
+	}
+	return "; " + strings.Join(refs, " ")
+}
@@ -0,0 +1,548 @@
+package compile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/maiyama18/dog/object"
+	"github.com/maiyama18/dog/token"
+)
+
+// The binary format of bytecode files is
+//
+//	magic           "DOGC"
+//	version         uint16, FormatVersion
+//	source checksum uint32, CRC-32 of the source compiled
+//	source path     path of the source compiled
+//	constants       count, then each constant
+//	globals         count, then each name
+//	main            function
+//	checksum        uint32, CRC-32 of everything before it
+//
+// Fixed size integers are big endian, and other integers are varints. A
+// constant starts with its tag, and a function lists its name, instructions,
+// line table, parameters with their names, whether its scopes are on the
+// stack, slots and source.
+const (
+	magic = "DOGC"
+
+	// FormatVersion is the version of the format. It changes with the format
+	// and with the instruction set, so that files built by other versions of
+	// the compiler are rejected.
+	FormatVersion = 5
+)
+
+// ErrChecksum is returned when the contents of a bytecode file do not match
+// their checksum, or the source they were compiled from has changed.
+var ErrChecksum = errors.New("checksum mismatch")
+
+const (
+	tagInteger byte = iota
+	tagString
+	tagBoolean
+	tagFunction
+	tagPattern
+	tagTry
+)
+
+// Source identifies the script bytecode was compiled from, so that a
+// bytecode file can be checked against the script it was built from.
+type Source struct {
+	Path     string // of the script, relative to the bytecode file unless absolute, or "" if unknown
+	Checksum uint32 // of the contents of the script, see SourceChecksum
+}
+
+// SourceChecksum returns the checksum of src recorded by Encode.
+func SourceChecksum(src string) uint32 {
+	return crc32.ChecksumIEEE([]byte(src))
+}
+
+// Encode serializes bytecode compiled from source.
+func Encode(bytecode *Bytecode, source Source) ([]byte, error) {
+	e := &encoder{buf: []byte(magic)}
+	e.buf = binary.BigEndian.AppendUint16(e.buf, FormatVersion)
+	e.buf = binary.BigEndian.AppendUint32(e.buf, source.Checksum)
+	e.bytes([]byte(source.Path))
+
+	e.uvarint(uint64(len(bytecode.Constants)))
+	for _, c := range bytecode.Constants {
+		if err := e.constant(c); err != nil {
+			return nil, err
+		}
+	}
+	e.uvarint(uint64(len(bytecode.Globals)))
+	for _, name := range bytecode.Globals {
+		e.bytes([]byte(name))
+	}
+	e.function(bytecode.Main)
+
+	return binary.BigEndian.AppendUint32(e.buf, crc32.ChecksumIEEE(e.buf)), nil
+}
+
+// Decode deserializes bytecode encoded by Encode, returning it with its
+// source. It rejects data of another format version, and corrupted data with
+// ErrChecksum.
+func Decode(data []byte) (*Bytecode, Source, error) {
+	header := len(magic) + 2 + 4
+	if len(data) < header+4 || string(data[:len(magic)]) != magic {
+		return nil, Source{}, errors.New("not a dog bytecode file")
+	}
+	if version := binary.BigEndian.Uint16(data[len(magic):]); version != FormatVersion {
+		return nil, Source{}, fmt.Errorf("bytecode format version %d is not supported, want %d: rebuild it", version, FormatVersion)
+	}
+	body := data[:len(data)-4]
+	if binary.BigEndian.Uint32(data[len(body):]) != crc32.ChecksumIEEE(body) {
+		return nil, Source{}, fmt.Errorf("corrupted bytecode: %w", ErrChecksum)
+	}
+	source := Source{Checksum: binary.BigEndian.Uint32(data[len(magic)+2:])}
+
+	d := &decoder{buf: body[header:]}
+	source.Path = string(d.bytes())
+	constants := make([]object.Object, d.length())
+	for i := range constants {
+		constants[i] = d.constant()
+	}
+	globals := make([]string, d.length())
+	for i := range globals {
+		globals[i] = string(d.bytes())
+	}
+	main := d.function()
+	if d.err == nil && len(d.buf) > 0 {
+		d.err = errors.New("trailing data")
+	}
+	if d.err != nil {
+		return nil, Source{}, fmt.Errorf("corrupted bytecode: %w", d.err)
+	}
+
+	bytecode := &Bytecode{Main: main, Constants: constants, Globals: globals}
+	if err := validate(bytecode); err != nil {
+		return nil, Source{}, fmt.Errorf("corrupted bytecode: %w", err)
+	}
+	return bytecode, source, nil
+}
+
+// validate checks that the instructions of bytecode are complete, that their
+// constant and global operands, jump targets and stack slots are in range,
+// and that their constants have the types the VM expects. The units of code
+// are checked from the top level through the closures and try blocks they
+// run, as a try block uses the stack slots of the call running it.
+func validate(bytecode *Bytecode) error {
+	v := &validator{bytecode: bytecode, seen: make(map[activation]bool)}
+	return v.unit(bytecode.Main, 0)
+}
+
+type validator struct {
+	bytecode *Bytecode
+	seen     map[activation]bool
+}
+
+// activation is a unit of code run with a number of stack slots.
+type activation struct {
+	fn    *Function
+	slots int
+}
+
+func (v *validator) unit(fn *Function, slots int) error {
+	if v.seen[activation{fn, slots}] {
+		return nil
+	}
+	v.seen[activation{fn, slots}] = true
+
+	ins := fn.Instructions
+	for offset := 0; offset < len(ins); {
+		op := Opcode(ins[offset])
+		def, err := Lookup(op)
+		if err != nil {
+			return err
+		}
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if offset+1+width > len(ins) {
+			return fmt.Errorf("truncated instruction %s at %d", def.Name, offset)
+		}
+
+		operands, _ := ReadOperands(def, ins[offset+1:])
+		if types, ok := constantTypes[op]; ok {
+			if operands[0] >= len(v.bytecode.Constants) {
+				return fmt.Errorf("constant %d of %s at %d out of range", operands[0], def.Name, offset)
+			}
+			if c := v.bytecode.Constants[operands[0]]; !hasType(c, types) {
+				return fmt.Errorf("constant %d of %s at %d is a %s", operands[0], def.Name, offset, c.Type())
+			}
+		}
+		switch op {
+		case OpGetName, OpCheckAssignable, OpAssign:
+			if operands[0] >= len(v.bytecode.Globals) {
+				return fmt.Errorf("global %d of %s at %d out of range", operands[0], def.Name, offset)
+			}
+		case OpGetSlot, OpSlotTarget, OpSetSlot:
+			if operands[1] >= slots {
+				return fmt.Errorf("stack slot %d of %s at %d out of range", operands[1], def.Name, offset)
+			}
+		case OpLet, OpConst, OpBindArgument, OpMatch:
+			if err := checkStackSlots(v.bytecode.Constants[operands[0]].(*Pattern), slots); err != nil {
+				return fmt.Errorf("%w of %s at %d", err, def.Name, offset)
+			}
+		case OpClosure:
+			c := v.bytecode.Constants[operands[0]].(*Function)
+			calleeSlots := 0
+			if c.Stack {
+				calleeSlots = c.Slots
+			}
+			if err := v.unit(c, calleeSlots); err != nil {
+				return err
+			}
+		case OpTry:
+			try := v.bytecode.Constants[operands[0]].(*Try)
+			if try.Block == nil || (try.Catch == nil) != (try.CatchParameter == nil) {
+				return fmt.Errorf("incomplete try constant %d of %s at %d", operands[0], def.Name, offset)
+			}
+			if try.CatchParameter != nil {
+				if err := checkStackSlots(try.CatchParameter, slots); err != nil {
+					return fmt.Errorf("%w of %s at %d", err, def.Name, offset)
+				}
+			}
+			for _, block := range []*Function{try.Block, try.Catch, try.Finally} {
+				if block == nil {
+					continue
+				}
+				if err := v.unit(block, slots); err != nil {
+					return err
+				}
+			}
+		}
+		var target int
+		switch op {
+		case OpJump, OpJumpIfFalse:
+			target = operands[0]
+		case OpArgument, OpMatch:
+			target = operands[1]
+		}
+		if target >= len(ins) {
+			return fmt.Errorf("jump target %d of %s at %d out of range", target, def.Name, offset)
+		}
+		offset += 1 + width
+	}
+	if len(ins) == 0 || Opcode(ins[len(ins)-1]) != OpEnd {
+		return fmt.Errorf("unterminated code of %s", fn.Name)
+	}
+	return nil
+}
+
+// hasType reports whether c is of one of types.
+func hasType(c object.Object, types []object.Type) bool {
+	for _, t := range types {
+		if c.Type() == t {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStackSlots checks that the names of p bound on the stack are bound
+// to one of the given number of stack slots.
+func checkStackSlots(p *Pattern, slots int) error {
+	if p.Stack && p.Slot >= slots {
+		return fmt.Errorf("stack slot %d of pattern %s out of range", p.Slot, p.Source)
+	}
+	for _, element := range p.Elements {
+		if err := checkStackSlots(element, slots); err != nil {
+			return err
+		}
+	}
+	if p.Rest != nil {
+		return checkStackSlots(p.Rest, slots)
+	}
+	return nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uvarint(v uint64) { e.buf = binary.AppendUvarint(e.buf, v) }
+func (e *encoder) varint(v int64)   { e.buf = binary.AppendVarint(e.buf, v) }
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) position(pos token.Position) {
+	e.uvarint(uint64(pos.Line))
+	e.uvarint(uint64(pos.Column))
+}
+
+func (e *encoder) constant(c object.Object) error {
+	switch c := c.(type) {
+	case *object.Integer:
+		e.buf = append(e.buf, tagInteger)
+		e.varint(c.Value)
+	case *object.String:
+		e.buf = append(e.buf, tagString)
+		e.bytes([]byte(c.Value))
+	case *object.Boolean:
+		e.buf = append(e.buf, tagBoolean)
+		e.bool(c.Value)
+	case *Function:
+		e.buf = append(e.buf, tagFunction)
+		e.function(c)
+	case *Pattern:
+		e.buf = append(e.buf, tagPattern)
+		return e.pattern(c)
+	case *Try:
+		e.buf = append(e.buf, tagTry)
+		e.bool(c.CatchParameter != nil)
+		if c.CatchParameter != nil {
+			if err := e.pattern(c.CatchParameter); err != nil {
+				return err
+			}
+		}
+		e.uvarint(uint64(c.CatchSlots))
+		for _, block := range []*Function{c.Block, c.Catch, c.Finally} {
+			e.bool(block != nil)
+			if block != nil {
+				e.function(block)
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode constant %s of type %s", c.Inspect(), c.Type())
+	}
+	return nil
+}
+
+func (e *encoder) function(fn *Function) {
+	e.bytes([]byte(fn.Name))
+	e.bytes(fn.Instructions)
+	e.uvarint(uint64(len(fn.Lines)))
+	for _, l := range fn.Lines {
+		e.uvarint(uint64(l.Offset))
+		e.position(l.Position)
+	}
+	e.uvarint(uint64(fn.Parameters))
+	for _, name := range fn.Names {
+		e.bytes([]byte(name))
+	}
+	e.uvarint(uint64(fn.Required))
+	e.bool(fn.Rest)
+	e.bool(fn.Stack)
+	e.uvarint(uint64(fn.Slots))
+	e.bytes([]byte(fn.Source))
+}
+
+func (e *encoder) pattern(p *Pattern) error {
+	e.uvarint(uint64(p.Kind))
+	e.bytes([]byte(p.Source))
+	switch p.Kind {
+	case NamePattern:
+		e.bytes([]byte(p.Name))
+		e.bool(p.Local)
+		e.bool(p.Stack)
+		e.uvarint(uint64(p.Slot))
+		e.position(p.Position)
+	case LiteralPattern:
+		return e.constant(p.Value)
+	case ArrayPattern:
+		e.uvarint(uint64(len(p.Elements)))
+		for _, element := range p.Elements {
+			if err := e.pattern(element); err != nil {
+				return err
+			}
+		}
+		e.bool(p.Rest != nil)
+		if p.Rest != nil {
+			return e.pattern(p.Rest)
+		}
+	case HashPattern:
+		e.uvarint(uint64(len(p.Keys)))
+		for i, key := range p.Keys {
+			if err := e.constant(key); err != nil {
+				return err
+			}
+			e.bytes([]byte(p.KeySources[i]))
+			if err := e.pattern(p.Elements[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decoder reads the encoded values from buf. After the first error, it reads
+// zero values and keeps the error.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+	d.buf = nil
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail("truncated data")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail("truncated data")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+// length reads a count of items, each taking at least a byte.
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.fail("length %d exceeds data", n)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.length()
+	b := append([]byte(nil), d.buf[:n]...)
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) byte() byte {
+	if len(d.buf) == 0 {
+		d.fail("truncated data")
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *decoder) bool() bool {
+	return d.byte() == 1
+}
+
+func (d *decoder) int() int {
+	v := d.uvarint()
+	if v > maxOperand*maxOperand {
+		d.fail("integer %d out of range", v)
+		return 0
+	}
+	return int(v)
+}
+
+func (d *decoder) position() token.Position {
+	return token.Position{Line: d.int(), Column: d.int()}
+}
+
+func (d *decoder) constant() object.Object {
+	switch tag := d.byte(); tag {
+	case tagInteger:
+		return object.NewInteger(d.varint())
+	case tagString:
+		return object.NewString(string(d.bytes()))
+	case tagBoolean:
+		if d.bool() {
+			return object.TRUE
+		}
+		return object.FALSE
+	case tagFunction:
+		return d.function()
+	case tagPattern:
+		return d.pattern()
+	case tagTry:
+		try := &Try{}
+		if d.bool() {
+			try.CatchParameter = d.pattern()
+		}
+		try.CatchSlots = d.int()
+		for _, block := range []**Function{&try.Block, &try.Catch, &try.Finally} {
+			if d.bool() {
+				*block = d.function()
+			}
+		}
+		return try
+	default:
+		d.fail("unknown constant tag %d", tag)
+		return object.NULL
+	}
+}
+
+func (d *decoder) function() *Function {
+	fn := &Function{Name: string(d.bytes()), Instructions: d.bytes()}
+	lines := d.length()
+	for i := 0; i < lines; i++ {
+		fn.Lines = append(fn.Lines, Line{Offset: d.int(), Position: d.position()})
+	}
+	fn.Parameters = d.int()
+	for i := 0; i < fn.Parameters && d.err == nil; i++ {
+		fn.Names = append(fn.Names, string(d.bytes()))
+	}
+	fn.Required = d.int()
+	fn.Rest = d.bool()
+	fn.Stack = d.bool()
+	fn.Slots = d.int()
+	fn.Source = string(d.bytes())
+	return fn
+}
+
+func (d *decoder) pattern() *Pattern {
+	p := &Pattern{Kind: PatternKind(d.int()), Source: string(d.bytes())}
+	switch p.Kind {
+	case WildcardPattern:
+	case NamePattern:
+		p.Name = string(d.bytes())
+		p.Local = d.bool()
+		p.Stack = d.bool()
+		p.Slot = d.int()
+		p.Position = d.position()
+	case LiteralPattern:
+		p.Value = d.constant()
+	case ArrayPattern:
+		elements := d.length()
+		for i := 0; i < elements; i++ {
+			p.Elements = append(p.Elements, d.pattern())
+		}
+		if d.bool() {
+			p.Rest = d.pattern()
+		}
+	case HashPattern:
+		keys := d.length()
+		for i := 0; i < keys; i++ {
+			key, ok := d.constant().(object.Hashable)
+			if !ok {
+				d.fail("unusable hash key in pattern")
+				return p
+			}
+			p.Keys = append(p.Keys, key)
+			p.KeySources = append(p.KeySources, string(d.bytes()))
+			p.Elements = append(p.Elements, d.pattern())
+		}
+	default:
+		d.fail("unknown pattern kind %d", p.Kind)
+	}
+	return p
+}
@@ -0,0 +1,118 @@
+package compile
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/maiyama18/dog/object"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	input := `let f = fn(n, [a, ..rest], {"k": k, 1: -1} = {"k": 0, 1: -1}, ...xs) {
+  match (n) { 0 => a, x if x > 1 => try { throw k } catch (e) { e } finally { 1 }, _ => f(n - 1, rest) }
+};
+f(3, [1, 2])`
+	bytecode, err := Compile(parseProgram(t, input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := Encode(bytecode, Source{Path: "../f.dog", Checksum: SourceChecksum(input)})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	decoded, source, err := Decode(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if want := (Source{Path: "../f.dog", Checksum: SourceChecksum(input)}); source != want {
+		t.Fatalf("source wrong. want=%+v, got=%+v", want, source)
+	}
+	if got, want := Disassemble(decoded), Disassemble(bytecode); got != want {
+		t.Fatalf("decoded bytecode wrong. want=\n%s\ngot=\n%s", want, got)
+	}
+	for i, c := range bytecode.Constants {
+		if decoded.Constants[i].Inspect() != c.Inspect() {
+			t.Fatalf("constant %d wrong. want=%q, got=%q", i, c.Inspect(), decoded.Constants[i].Inspect())
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	bytecode, err := Compile(parseProgram(t, "let x = 1; x + 2"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	data, err := Encode(bytecode, Source{Path: "x.dog", Checksum: SourceChecksum("let x = 1; x + 2")})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	modified := func(f func(data []byte) []byte) []byte {
+		return f(append([]byte(nil), data...))
+	}
+	tests := []struct {
+		name     string
+		data     []byte
+		want     string
+		checksum bool
+	}{
+		{name: "empty", data: nil, want: "not a dog bytecode file"},
+		{name: "magic", data: modified(func(d []byte) []byte { d[0] = 'X'; return d }), want: "not a dog bytecode file"},
+		{name: "version", data: modified(func(d []byte) []byte { d[5] = 99; return d }), want: "bytecode format version 99 is not supported, want 5: rebuild it"},
+		{name: "flipped bit", data: modified(func(d []byte) []byte { d[len(d)/2] ^= 1; return d }), want: "corrupted bytecode: checksum mismatch", checksum: true},
+		{name: "truncated", data: modified(func(d []byte) []byte { return d[:len(d)-1] }), want: "corrupted bytecode: checksum mismatch", checksum: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := Decode(test.data)
+			if err == nil {
+				t.Fatalf("no error")
+			}
+			if err.Error() != test.want {
+				t.Fatalf("error wrong. want=%q, got=%q", test.want, err.Error())
+			}
+			if errors.Is(err, ErrChecksum) != test.checksum {
+				t.Fatalf("errors.Is(err, ErrChecksum) wrong: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateConstantTypes(t *testing.T) {
+	unit := func(ins ...Instructions) *Function {
+		fn := &Function{}
+		for _, in := range append(ins, Make(OpEnd)) {
+			fn.Instructions = append(fn.Instructions, in...)
+		}
+		return fn
+	}
+	block := unit(Make(OpNull))
+	constants := []object.Object{&object.Integer{Value: 1}, &object.String{Value: "x"}, &Try{}, &Try{Block: block, Catch: block}}
+	tests := []struct {
+		name string
+		main *Function
+		want string
+	}{
+		{name: "closure", main: unit(Make(OpClosure, 0)), want: "constant 0 of OpClosure at 0 is a INTEGER"},
+		{name: "try", main: unit(Make(OpTry, 1)), want: "constant 1 of OpTry at 0 is a STRING"},
+		{name: "name", main: unit(Make(OpGetLocal, 0, 0, 0)), want: "constant 0 of OpGetLocal at 0 is a INTEGER"},
+		{name: "member", main: unit(Make(OpNull), Make(OpMember, 2)), want: "constant 2 of OpMember at 1 is a TRY"},
+		{name: "pattern", main: unit(Make(OpNull), Make(OpLet, 1)), want: "constant 1 of OpLet at 1 is a STRING"},
+		{name: "match", main: unit(Make(OpNull), Make(OpMatch, 0, 0)), want: "constant 0 of OpMatch at 1 is a INTEGER"},
+		{name: "try without block", main: unit(Make(OpTry, 2)), want: "incomplete try constant 2 of OpTry at 0"},
+		{name: "catch without parameter", main: unit(Make(OpTry, 3)), want: "incomplete try constant 3 of OpTry at 0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validate(&Bytecode{Main: test.main, Constants: constants})
+			if err == nil {
+				t.Fatalf("no error")
+			}
+			if err.Error() != test.want {
+				t.Fatalf("error wrong. want=%q, got=%q", test.want, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maiyama18/dog/compile"
+	"github.com/maiyama18/dog/evaluate"
+	"github.com/maiyama18/dog/lex"
+	"github.com/maiyama18/dog/object"
+	"github.com/maiyama18/dog/parse"
+	"github.com/maiyama18/dog/vm"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected results from the evaluator")
+
+func TestConformance(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.dog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no programs in testdata")
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".dog")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parser := parse.NewParser(lex.NewLexer(string(src)))
+			program := parser.ParseProgram()
+			if errs := parser.Errors(); len(errs) > 0 {
+				t.Fatalf("parse error: %s", errors.Join(errs...))
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			evaluated := evaluate.EvalContext(ctx, program, object.NewEnvironment())
+			bytecode, err := compile.Compile(program)
+			if err != nil {
+				t.Fatalf("compile error: %s", err)
+			}
+			ran := vm.Run(ctx, bytecode, object.NewEnvironment())
+
+			base := strings.TrimSuffix(path, ".dog")
+			if *update {
+				writeExpectation(t, base, evaluated)
+			}
+			want, wantErr := readExpectation(t, base)
+
+			for _, result := range []struct {
+				engine string
+				got    object.Object
+			}{
+				{engine: "evaluator", got: evaluated},
+				{engine: "vm", got: ran},
+			} {
+				got, gotErr := describe(result.got)
+				if gotErr != wantErr {
+					t.Errorf("%s: error wanted=%t, got=%t:\n%s", result.engine, wantErr, gotErr, got)
+				} else if got != want {
+					t.Errorf("%s: result wrong. want=\n%s\ngot=\n%s", result.engine, want, got)
+				}
+			}
+			if evaluated.Type() != ran.Type() {
+				t.Errorf("engines disagree on type. evaluator=%s, vm=%s", evaluated.Type(), ran.Type())
+			}
+		})
+	}
+}
+
+// describe returns the traceback of an error, or the Inspect output of any
+// other result, reporting whether result is an error.
+func describe(result object.Object) (string, bool) {
+	if err, ok := result.(*object.Error); ok {
+		return err.Traceback(), true
+	}
+	return result.Inspect(), false
+}
+
+// readExpectation reads the expected result of the program at base.dog from
+// base.want or base.err.
+func readExpectation(t *testing.T, base string) (string, bool) {
+	t.Helper()
+
+	want, wantErr := base+".want", base+".err"
+	_, errWant := os.Stat(want)
+	_, errErr := os.Stat(wantErr)
+	switch {
+	case errWant == nil && errErr == nil:
+		t.Fatalf("both %s and %s exist", want, wantErr)
+	case errWant == nil:
+		return readFile(t, want), false
+	case errErr == nil:
+		return readFile(t, wantErr), true
+	}
+	t.Fatalf("no expectation for %s.dog: write %s or %s, or run with -update", base, want, wantErr)
+	return "", false
+}
+
+func writeExpectation(t *testing.T, base string, result object.Object) {
+	t.Helper()
+
+	got, isErr := describe(result)
+	path, stale := base+".want", base+".err"
+	if isErr {
+		path, stale = stale, path
+	}
+	if err := os.Remove(stale); err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(got+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSuffix(string(b), "\n")
+}
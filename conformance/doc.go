@@ -0,0 +1,12 @@
+// Package conformance holds a corpus of dog programs, in testdata, with their
+// expected results. Its tests run each program on both the tree-walking
+// evaluator and the bytecode VM, and fail if either engine differs from the
+// expectation or from the other.
+//
+// A program NAME.dog expects the value printed in NAME.want, or the error
+// traceback printed in NAME.err. Running
+//
+//	go test ./conformance -update
+//
+// rewrites the expectations from the results of the evaluator.
+package conformance
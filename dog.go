@@ -0,0 +1,181 @@
+// Package dog embeds the dog interpreter in Go programs.
+package dog
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/maiyama18/dog/compile"
+	"github.com/maiyama18/dog/evaluate"
+	"github.com/maiyama18/dog/lex"
+	"github.com/maiyama18/dog/object"
+	"github.com/maiyama18/dog/optimize"
+	"github.com/maiyama18/dog/parse"
+	"github.com/maiyama18/dog/vm"
+)
+
+// Interpreter evaluates dog scripts in a top level environment which persists
+// across calls of Eval, so that later scripts see the bindings of earlier ones.
+type Interpreter struct {
+	env      *object.Environment
+	maxDepth int
+	fuel     int64
+	memory   *evaluate.Memory
+	optimize bool
+}
+
+// Option configures an Interpreter.
+type Option func(*Interpreter)
+
+// WithMaxDepth limits the depth of the call stack of scripts. See evaluate.WithMaxDepth.
+func WithMaxDepth(depth int) Option {
+	return func(i *Interpreter) {
+		i.maxDepth = depth
+	}
+}
+
+// WithFuel limits the steps each call of Eval may take. See evaluate.WithFuel.
+func WithFuel(fuel int64) Option {
+	return func(i *Interpreter) {
+		i.fuel = fuel
+	}
+}
+
+// WithMemoryLimit limits the approximate memory used by scripts to limit
+// bytes. See evaluate.Memory.
+func WithMemoryLimit(limit int64) Option {
+	return func(i *Interpreter) {
+		i.memory = evaluate.NewMemory(limit)
+	}
+}
+
+// WithOptimization optimizes scripts before evaluating them, folding their
+// constant expressions and branches. See optimize.Optimize.
+func WithOptimization() Option {
+	return func(i *Interpreter) {
+		i.optimize = true
+	}
+}
+
+// New returns an Interpreter with an empty top level environment.
+func New(opts ...Option) *Interpreter {
+	i := &Interpreter{env: object.NewEnvironment(), maxDepth: evaluate.DefaultMaxDepth}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Eval parses and evaluates src until ctx is done, returning the value of its
+// last statement. A script that fails to parse or raises an uncaught error
+// returns an *Error.
+func (i *Interpreter) Eval(ctx context.Context, src string) (object.Object, error) {
+	parser := parse.NewParser(lex.NewLexer(src))
+	program := parser.ParseProgram()
+	if len(parser.Errors()) > 0 {
+		return nil, &Error{ParseErrors: parser.Errors()}
+	}
+	if i.optimize {
+		optimize.Optimize(program)
+	}
+
+	opts := []evaluate.Option{evaluate.WithMaxDepth(i.maxDepth), evaluate.WithFuel(i.fuel)}
+	if i.memory != nil {
+		opts = append(opts, evaluate.WithMemory(i.memory))
+	}
+	return i.result(ctx, evaluate.EvalContext(ctx, program, i.env, opts...))
+}
+
+// Run runs bytecode, typically loaded from a file built ahead of time, on the
+// VM until ctx is done. The VM does not meter fuel or memory, so Run fails on
+// an interpreter created WithFuel or WithMemoryLimit.
+func (i *Interpreter) Run(ctx context.Context, bytecode *compile.Bytecode) (object.Object, error) {
+	if i.fuel > 0 || i.memory != nil {
+		return nil, errors.New("fuel and memory limits are not supported for bytecode")
+	}
+	return i.result(ctx, vm.Run(ctx, bytecode, i.env, vm.WithMaxDepth(i.maxDepth)))
+}
+
+// result returns the result of a script, or an *Error if it raised an error.
+func (i *Interpreter) result(ctx context.Context, result object.Object) (object.Object, error) {
+	if err, ok := result.(*object.Error); ok {
+		dogErr := &Error{RuntimeError: err}
+		if err.Kind == object.CanceledErrorKind {
+			dogErr.cause = ctx.Err()
+		}
+		return nil, dogErr
+	}
+	return result, nil
+}
+
+// Compile parses and compiles src to bytecode. A script that fails to parse
+// returns an *Error.
+func Compile(src string) (*compile.Bytecode, error) {
+	parser := parse.NewParser(lex.NewLexer(src))
+	program := parser.ParseProgram()
+	if len(parser.Errors()) > 0 {
+		return nil, &Error{ParseErrors: parser.Errors()}
+	}
+	return compile.Compile(program)
+}
+
+// Set binds name to value in the top level environment. It fails, leaving
+// the binding unchanged, if name is a constant declared by a script.
+func (i *Interpreter) Set(name string, value object.Object) error {
+	return i.env.Define(name, value)
+}
+
+// Get returns the value bound to name in the top level environment.
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// MemoryUsage returns the current and peak memory used by scripts in bytes.
+// Both are zero unless the interpreter was created WithMemoryLimit.
+func (i *Interpreter) MemoryUsage() (current, peak int64) {
+	if i.memory == nil {
+		return 0, 0
+	}
+	return i.memory.Current(), i.memory.Peak()
+}
+
+// Error is the error of a script that failed to parse or raised an uncaught
+// error. Exactly one of ParseErrors and RuntimeError is set.
+type Error struct {
+	ParseErrors  []error
+	RuntimeError *object.Error
+
+	cause error // error of the context which canceled the evaluation, if any
+}
+
+func (e *Error) Error() string {
+	if e.RuntimeError != nil {
+		return e.RuntimeError.Inspect()
+	}
+
+	var errMsgs []string
+	for _, err := range e.ParseErrors {
+		errMsgs = append(errMsgs, err.Error())
+	}
+	return strings.Join(errMsgs, "\n")
+}
+
+// Unwrap returns the parse errors, or the error of the context if the
+// evaluation was canceled, so that errors.Is(err, context.DeadlineExceeded)
+// reports a timeout.
+func (e *Error) Unwrap() []error {
+	if e.cause != nil {
+		return []error{e.cause}
+	}
+	return e.ParseErrors
+}
+
+// Traceback returns the error with the call stack of a runtime error, or the
+// parse errors.
+func (e *Error) Traceback() string {
+	if e.RuntimeError != nil {
+		return e.RuntimeError.Traceback()
+	}
+	return e.Error()
+}
@@ -0,0 +1,216 @@
+package dog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/maiyama18/dog/object"
+)
+
+func TestInterpreterEval(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "1 + 2", want: "3"},
+		{input: "let add = fn(a, b) { a + b }; add(2, 3)", want: "5"},
+		{input: `"dog" + "!"`, want: "dog!"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := New().Eval(context.Background(), test.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got.Inspect() != test.want {
+				t.Fatalf("result wrong. want=%q, got=%q", test.want, got.Inspect())
+			}
+		})
+	}
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		opts    []Option
+		parse   bool
+		want    string
+		wantErr error
+	}{
+		{input: "let = 1", parse: true, want: `1:5: unexpected token "=" in pattern`},
+		{input: "1 / 0", want: "1:3: RuntimeError: division by zero"},
+		{input: `throw "boom"`, want: "1:1: Error: boom"},
+		{input: "let f = fn(n) { 1 + f(n) }; f(0)", opts: []Option{WithMaxDepth(10)}, want: "1:22: RuntimeError: maximum recursion depth exceeded (10)"},
+		{input: "while (true) { 1 }", opts: []Option{WithFuel(10)}, want: "1:8: OutOfFuel: fuel exhausted: consumed 11 of 10"},
+		{input: "let a = []; while (true) { push(a, 1) }", opts: []Option{WithMemoryLimit(1 << 16)}},
+		{input: "while (true) { 1 }", wantErr: context.DeadlineExceeded},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			_, err := New(test.opts...).Eval(ctx, test.input)
+			var dogErr *Error
+			if !errors.As(err, &dogErr) {
+				t.Fatalf("not Error: %+v", err)
+			}
+			if test.parse != (len(dogErr.ParseErrors) > 0) {
+				t.Fatalf("parse errors wrong: %+v", dogErr)
+			}
+			if test.want != "" && err.Error() != test.want {
+				t.Fatalf("error message wrong. want=%q, got=%q", test.want, err.Error())
+			}
+			if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Fatalf("error is not %v: %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestInterpreterGlobals(t *testing.T) {
+	interpreter := New()
+	if err := interpreter.Set("limit", object.NewInteger(10)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := interpreter.Eval(context.Background(), "let double = limit * 2"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, ok := interpreter.Get("double")
+	if !ok {
+		t.Fatalf("double not bound")
+	}
+	if got.Inspect() != "20" {
+		t.Fatalf("double wrong. want=%q, got=%q", "20", got.Inspect())
+	}
+	if _, ok := interpreter.Get("undefined"); ok {
+		t.Fatalf("undefined bound")
+	}
+}
+
+func TestInterpreterSetConstant(t *testing.T) {
+	interpreter := New()
+	if _, err := interpreter.Eval(context.Background(), "let limit = 1; const max = 2"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := interpreter.Set("limit", object.NewInteger(10)); err != nil {
+		t.Fatalf("unexpected error setting a variable: %s", err)
+	}
+	err := interpreter.Set("max", object.NewInteger(20))
+	if want := "cannot redeclare constant max declared at 1:22"; err == nil || err.Error() != want {
+		t.Fatalf("error wrong. want=%q, got=%v", want, err)
+	}
+
+	got, err := interpreter.Eval(context.Background(), "[limit, max]")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.Inspect() != "[10, 2]" {
+		t.Fatalf("result wrong. want=%q, got=%q", "[10, 2]", got.Inspect())
+	}
+}
+
+func TestInterpreterMemoryUsage(t *testing.T) {
+	interpreter := New(WithMemoryLimit(1 << 20))
+	if _, err := interpreter.Eval(context.Background(), "let a = []; let i = 0; while (i < 1000) { push(a, i); i += 1 }"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	current, peak := interpreter.MemoryUsage()
+	if current < 1000*object.SlotSize {
+		t.Fatalf("current usage too small: %d", current)
+	}
+	if peak < current {
+		t.Fatalf("peak usage %d less than current usage %d", peak, current)
+	}
+}
+
+func TestInterpreterGoValues(t *testing.T) {
+	interpreter := New()
+	for name, value := range map[string]interface{}{
+		"user": struct {
+			Name string `dog:"name"`
+		}{Name: "pochi"},
+		"greet": func(name string) string { return "hello, " + name },
+	} {
+		obj, err := object.FromGo(value)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := interpreter.Set(name, obj); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	got, err := interpreter.Eval(context.Background(), `greet(user["name"])`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.Inspect() != "hello, pochi" {
+		t.Fatalf("result wrong. want=%q, got=%q", "hello, pochi", got.Inspect())
+	}
+}
+
+func TestInterpreterRun(t *testing.T) {
+	bytecode, err := Compile("let add = fn(a, b) { a + b }; add(limit, 3)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	interpreter := New()
+	if err := interpreter.Set("limit", object.NewInteger(2)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err := interpreter.Run(context.Background(), bytecode)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.Inspect() != "5" {
+		t.Fatalf("result wrong. want=%q, got=%q", "5", got.Inspect())
+	}
+	if _, ok := interpreter.Get("add"); !ok {
+		t.Fatalf("add not bound")
+	}
+
+	if _, err := New(WithFuel(100)).Run(context.Background(), bytecode); err == nil {
+		t.Fatalf("no error running bytecode with fuel")
+	}
+
+	bytecode, err = Compile("1 / 0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = New().Run(context.Background(), bytecode)
+	if err == nil || err.Error() != "1:3: RuntimeError: division by zero" {
+		t.Fatalf("error wrong: %v", err)
+	}
+}
+
+func TestInterpreterOptimization(t *testing.T) {
+	input := "let x = 1 + 2 * 3 * 4; if (x > 0) { x } else { 0 } + (1 / 0 == 0)"
+	for _, test := range []struct {
+		opts []Option
+		want string
+	}{
+		{opts: []Option{WithFuel(16)}, want: "1:37: OutOfFuel: fuel exhausted: consumed 17 of 16"},
+		{opts: []Option{WithFuel(16), WithOptimization()}, want: "1:57: RuntimeError: division by zero"},
+	} {
+		_, err := New(test.opts...).Eval(context.Background(), input)
+		if err == nil || err.Error() != test.want {
+			t.Fatalf("error wrong. want=%q, got=%v", test.want, err)
+		}
+	}
+
+	got, err := New(WithOptimization()).Eval(context.Background(), "let x = 2 * 3; if (true) { x + 1 } else { x }")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.Inspect() != "7" {
+		t.Fatalf("result wrong. want=%q, got=%q", "7", got.Inspect())
+	}
+}
@@ -0,0 +1,159 @@
+package evaluate
+
+import (
+	"strings"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/object"
+)
+
+// evalAssignExpression evaluates plain ("=") and compound ("+=", "-=", ...)
+// assignments. The value of the expression is the assigned value.
+func (e *evaluator) evalAssignExpression(assign *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := assign.Target.(type) {
+	case *ast.Identifier:
+		return e.evalIdentifierAssignment(assign, target, env)
+	case *ast.IndexExpression:
+		return e.evalIndexAssignment(assign, target, env)
+	case *ast.MemberExpression:
+		return e.evalMemberAssignment(assign, target, env)
+	default:
+		return object.NewError("cannot assign to %s", assign.Target.String())
+	}
+}
+
+// evalIdentifierAssignment updates the binding of target. Assignments to
+// constants of the program are rejected by the parser, so a slot is always
+// assignable once bound; names are checked here.
+func (e *evaluator) evalIdentifierAssignment(assign *ast.AssignExpression, target *ast.Identifier, env *object.Environment) object.Object {
+	if binding := target.Binding; binding != nil {
+		current, ok := env.GetSlot(binding.Depth, binding.Slot)
+		if !ok {
+			return object.NewError("assignment to undeclared identifier: %s", target.Name)
+		}
+		value := e.evalAssignedValue(assign, current, env)
+		if isError(value) {
+			return value
+		}
+		env.SetSlot(binding.Depth, binding.Slot, value)
+		return value
+	}
+
+	if err := env.CheckAssignable(target.Name); err != nil {
+		return object.NewError("%s", err)
+	}
+	current, _ := env.Get(target.Name)
+
+	value := e.evalAssignedValue(assign, current, env)
+	if isError(value) {
+		return value
+	}
+
+	if err := env.Assign(target.Name, value); err != nil {
+		return object.NewError("%s", err)
+	}
+	return value
+}
+
+func (e *evaluator) evalIndexAssignment(assign *ast.AssignExpression, target *ast.IndexExpression, env *object.Environment) object.Object {
+	left := e.evalNode(target.Left, env)
+	if isError(left) {
+		return left
+	}
+	index := e.evalNode(target.Index, env)
+	if isError(index) {
+		return index
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return object.NewError("array index must be INTEGER, got %s", index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return object.NewError("array index out of range: index=%d, length=%d", i.Value, len(left.Elements))
+		}
+
+		value := e.evalAssignedValue(assign, left.Elements[i.Value], env)
+		if isError(value) {
+			return value
+		}
+		left.Elements[i.Value] = value
+		return value
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return object.NewError("unusable as hash key: %s", index.Type())
+		}
+
+		current, ok := left.Get(key)
+		if !ok && assign.Operator != "=" {
+			return object.NewError("key not found in hash: %s", key.Inspect())
+		}
+
+		value := e.evalAssignedValue(assign, current, env)
+		if isError(value) {
+			return value
+		}
+		if !ok {
+			if err := e.Allocate(object.PairSize); err != nil {
+				return err
+			}
+		}
+		left.Set(key, value)
+		return value
+	default:
+		return object.NewError("index assignment not supported: %s", left.Type())
+	}
+}
+
+func (e *evaluator) evalMemberAssignment(assign *ast.AssignExpression, target *ast.MemberExpression, env *object.Environment) object.Object {
+	obj := e.evalNode(target.Object, env)
+	if isError(obj) {
+		return obj
+	}
+	host, ok := obj.(*object.HostObject)
+	if !ok {
+		return object.NewError("member assignment not supported: %s", obj.Type())
+	}
+
+	var current object.Object
+	if assign.Operator != "=" {
+		current = Member(host, target.Member.Name)
+		if isError(current) {
+			return current
+		}
+	}
+
+	value := e.evalAssignedValue(assign, current, env)
+	if isError(value) {
+		return value
+	}
+	if err := host.SetMember(target.Member.Name, value); err != nil {
+		return object.NewError("%s", err)
+	}
+	return value
+}
+
+// evalAssignedValue evaluates the right hand side of assign. For compound
+// assignments it is combined with current using the underlying infix operator.
+func (e *evaluator) evalAssignedValue(assign *ast.AssignExpression, current object.Object, env *object.Environment) object.Object {
+	value := e.evalNode(assign.Value, env)
+	if isError(value) {
+		return value
+	}
+	if assign.Operator == "=" {
+		return value
+	}
+
+	operator := strings.TrimSuffix(assign.Operator, "=")
+	result := Infix(operator, current, value)
+	if result == NULL {
+		return object.NewError("unsupported operands for %s: %s and %s", assign.Operator, current.Type(), value.Type())
+	}
+	if err := e.charge(result); err != nil {
+		return err
+	}
+	return result
+}
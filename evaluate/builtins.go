@@ -0,0 +1,95 @@
+package evaluate
+
+import (
+	"github.com/maiyama18/dog/object"
+)
+
+// Runtime is the state of the engine running a builtin. It lets engines other
+// than the evaluator, such as the bytecode VM, share the builtins.
+type Runtime interface {
+	// Frames returns the call stack, most recent call last, including the
+	// call of the builtin.
+	Frames() []object.Frame
+	// Consume charges cost steps to the fuel of the evaluation.
+	Consume(cost int64) *object.Error
+	// Allocate adds size bytes to the memory used by the evaluation.
+	Allocate(size int64) *object.Error
+}
+
+type builtinFunction func(rt Runtime, args ...object.Object) object.Object
+
+// builtins are available in every environment unless shadowed by a binding.
+var builtins = map[string]builtinFunction{
+	"len":        builtinLen,
+	"push":       builtinPush,
+	"stacktrace": builtinStacktrace,
+}
+
+// Builtin returns the builtin function of the name, bound to rt.
+func Builtin(name string, rt Runtime) (*object.Builtin, bool) {
+	fn, ok := builtins[name]
+	if !ok {
+		return nil, false
+	}
+	return &object.Builtin{Name: name, Fn: func(args ...object.Object) object.Object { return fn(rt, args...) }}, true
+}
+
+// Frames returns the call stack of the evaluation.
+func (e *evaluator) Frames() []object.Frame {
+	return e.frames
+}
+
+// builtinStacktrace returns the frames of the current call stack as strings,
+// most recent call last, excluding the call to stacktrace itself.
+func builtinStacktrace(rt Runtime, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return object.NewError("wrong number of arguments: want=0, got=%d", len(args))
+	}
+
+	frames := rt.Frames()
+	frames = frames[:len(frames)-1]
+	elements := make([]object.Object, 0, len(frames))
+	for _, f := range frames {
+		elements = append(elements, object.NewString(f.String()))
+	}
+	return object.NewArray(elements)
+}
+
+// builtinLen returns the number of bytes of a string, elements of an array or
+// pairs of a hash.
+func builtinLen(rt Runtime, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return object.NewError("wrong number of arguments: want=1, got=%d", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.String:
+		return object.NewInteger(int64(len(arg.Value)))
+	case *object.Array:
+		return object.NewInteger(int64(len(arg.Elements)))
+	case *object.Hash:
+		return object.NewInteger(int64(arg.Len()))
+	default:
+		return object.NewError("argument to len not supported: %s", arg.Type())
+	}
+}
+
+// builtinPush appends a value to an array in place and returns the array.
+func builtinPush(rt Runtime, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return object.NewError("wrong number of arguments: want=2, got=%d", len(args))
+	}
+
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return object.NewError("first argument to push must be ARRAY, got %s", args[0].Type())
+	}
+	if err := rt.Consume(1); err != nil {
+		return err
+	}
+	if err := rt.Allocate(object.SlotSize); err != nil {
+		return err
+	}
+	array.Elements = append(array.Elements, args[1])
+	return array
+}
@@ -1,14 +1,18 @@
 package evaluate
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/maiyama18/dog/ast"
 	"github.com/maiyama18/dog/object"
+	"github.com/maiyama18/dog/token"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
 func booleanObject(b bool) object.Object {
@@ -19,60 +23,467 @@ func booleanObject(b bool) object.Object {
 	}
 }
 
-func Eval(node ast.Node) object.Object {
+// DefaultMaxDepth is the default limit of the depth of the call stack. It
+// is well below the depth at which the Go stack of the evaluator overflows.
+const DefaultMaxDepth = 10000
+
+// evaluator holds the state of a single evaluation.
+type evaluator struct {
+	ctx      context.Context
+	frames   []object.Frame // call stack, most recent call last
+	maxDepth int
+	fuel     int64 // budget of steps, or 0 for no limit
+	consumed int64
+	memory   *Memory
+	scopes   []*object.Environment // environments in use, from which live objects are reachable
+	temps    []object.Object       // values evaluated but not yet consumed, e.g. operands, which are live too
+}
+
+// Option configures an evaluation.
+type Option func(*evaluator)
+
+// WithMaxDepth limits the depth of the call stack. Calls beyond the limit raise
+// a "maximum recursion depth exceeded" error instead of exhausting the Go
+// stack. A non-positive depth removes the limit.
+func WithMaxDepth(depth int) Option {
+	return func(e *evaluator) {
+		e.maxDepth = depth
+	}
+}
+
+// WithFuel limits the steps an evaluation may take. Evaluating a node costs
+// one step, and creating a string, array or hash costs one more step per byte,
+// element or pair. An evaluation exceeding the budget stops with an error of
+// kind object.OutOfFuelErrorKind, which try expressions do not catch. Unlike a
+// timeout, the outcome is the same on every run. A non-positive budget removes
+// the limit.
+func WithFuel(fuel int64) Option {
+	return func(e *evaluator) {
+		e.fuel = fuel
+	}
+}
+
+// Eval evaluates node in a new top level environment.
+func Eval(node ast.Node, opts ...Option) object.Object {
+	return EvalWithEnvironment(node, object.NewEnvironment(), opts...)
+}
+
+// EvalWithEnvironment evaluates node in env, so that bindings persist across
+// calls sharing the same environment (e.g. lines of the REPL).
+func EvalWithEnvironment(node ast.Node, env *object.Environment, opts ...Option) object.Object {
+	return EvalContext(context.Background(), node, env, opts...)
+}
+
+// EvalContext evaluates node in env until ctx is done. Cancellation is checked
+// at every call and loop iteration, and stops the evaluation with an error of
+// kind object.CanceledErrorKind, which try expressions do not catch.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, opts ...Option) object.Object {
+	e := &evaluator{ctx: ctx, maxDepth: DefaultMaxDepth}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	e.pushScope(env)
+	result := e.evalNode(node, env)
+	e.popScope()
+	if returnValue, ok := result.(*object.ReturnValue); ok {
+		result = returnValue.Value
+	}
+	if e.memory != nil {
+		e.memory.collect([]*object.Environment{env}, result)
+	}
+	return result
+}
+
+// evalNode evaluates node, attributing the errors it raises to its position
+// and to the current call stack.
+func (e *evaluator) evalNode(node ast.Node, env *object.Environment) object.Object {
+	// the values of the children of node stay live until node is evaluated
+	mark := len(e.temps)
+	var result object.Object
+	if err := e.Consume(1); err != nil {
+		result = err
+	} else {
+		result = e.dispatch(node, env)
+	}
+	if creates(node) {
+		if err := e.charge(result); err != nil {
+			result = err
+		}
+	}
+	if err, ok := result.(*object.Error); ok {
+		if err.Position == (token.Position{}) {
+			err.Position = node.Pos()
+		}
+		if err.Stack == nil && len(e.frames) > 0 {
+			err.Stack = append([]object.Frame(nil), e.frames...)
+		}
+	}
+	e.temps = append(e.temps[:mark], result)
+	return result
+}
+
+// Consume charges cost steps to the fuel of the evaluation, returning an error
+// if the fuel is exhausted.
+func (e *evaluator) Consume(cost int64) *object.Error {
+	e.consumed += cost
+	if e.fuel > 0 && e.consumed > e.fuel {
+		return &object.Error{Kind: object.OutOfFuelErrorKind, Message: fmt.Sprintf("fuel exhausted: consumed %d of %d", e.consumed, e.fuel)}
+	}
+	return nil
+}
+
+// creates reports whether evaluating node creates a new object.
+func creates(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.StringLiteral, *ast.InfixExpression, *ast.ArrayLiteral, *ast.HashLiteral, *ast.FunctionLiteral:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *evaluator) dispatch(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalStatements(node.Statements)
+		return e.evalStatements(node.Statements, env)
 	case *ast.BlockStatement:
-		return evalStatements(node.Statements)
+		return e.evalStatements(node.Statements, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression)
+		return e.evalNode(node.Expression, env)
+	case *ast.LetStatement:
+		return e.evalLetStatement(node, env)
+	case *ast.ReturnStatement:
+		value := e.evalNode(node.Expression, env)
+		if isError(value) {
+			return value
+		}
+		return &object.ReturnValue{Value: value}
+	case *ast.ThrowStatement:
+		return e.evalThrowStatement(node, env)
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(node, env)
+	case *ast.TryExpression:
+		return e.evalTryExpression(node, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node)
+		return e.evalIfExpression(node, env, false)
+	case *ast.MatchExpression:
+		return e.evalMatchExpression(node, env, false)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right)
-		return evalPrefixExpression(node.Operator, right)
+		right := e.evalNode(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return Prefix(node.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(node.Left)
-		right := Eval(node.Right)
-		return evalInfixExpression(node.Operator, left, right)
+		left := e.evalNode(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := e.evalNode(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return Infix(node.Operator, left, right)
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(node, env)
+	case *ast.MemberExpression:
+		obj := e.evalNode(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return Member(obj, node.Member.Name)
+	case *ast.IndexExpression:
+		left := e.evalNode(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := e.evalNode(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return Index(left, index)
+	case *ast.CallExpression:
+		function := e.evalNode(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args, err := e.evalArguments(function, node.Arguments, env)
+		if err != nil {
+			return err
+		}
+		return e.applyFunction(function, args, node.Pos())
+	case *ast.FunctionLiteral:
+		return &object.Function{Name: node.Name, Parameters: node.Parameters, Rest: node.Rest, Body: node.Body, Slots: node.Slots, Env: env}
+	case *ast.Identifier:
+		return e.evalIdentifier(node, env)
 	case *ast.IntegerLiteral:
 		return object.NewInteger(node.Value)
 	case *ast.BooleanLiteral:
 		return booleanObject(node.Value)
+	case *ast.StringLiteral:
+		return object.NewString(node.Value)
+	case *ast.ArrayLiteral:
+		elements, err := e.evalExpressions(node.Elements, env)
+		if err != nil {
+			return err
+		}
+		return object.NewArray(elements)
+	case *ast.HashLiteral:
+		return e.evalHashLiteral(node, env)
 	default:
 		return NULL
 	}
 }
 
-func evalStatements(stmts []ast.Statement) object.Object {
-	var result object.Object
+func (e *evaluator) evalStatements(stmts []ast.Statement, env *object.Environment) object.Object {
+	var result object.Object = NULL
 	for _, s := range stmts {
-		result = Eval(s)
+		result = e.evalNode(s, env)
+		switch result.(type) {
+		case *object.ReturnValue, *object.Error:
+			return result
+		}
 	}
 	return result
 }
 
-func evalIfExpression(ifExp *ast.IfExpression) object.Object {
-	cond := Eval(ifExp.Condition)
-	if truthy(cond) {
-		return Eval(ifExp.Consequence)
+// evalExpressions evaluates exps from left to right, stopping at the first error.
+func (e *evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) ([]object.Object, *object.Error) {
+	var results []object.Object
+	for _, exp := range exps {
+		result := e.evalNode(exp, env)
+		if err, ok := result.(*object.Error); ok {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (e *evaluator) evalLetStatement(let *ast.LetStatement, env *object.Environment) object.Object {
+	value := e.evalNode(let.Expression, env)
+	if isError(value) {
+		return value
+	}
+
+	mismatch, err := e.destructure(let.Pattern, value, env, func(ident *ast.Identifier, value object.Object) *object.Error {
+		if ident.Binding != nil {
+			env.SetSlot(0, ident.Binding.Slot, value)
+			return nil
+		}
+
+		var err error
+		if let.Constant() {
+			err = env.DefineConstant(ident.Name, value, ident.Token.Position)
+		} else {
+			err = env.Define(ident.Name, value)
+		}
+		if err != nil {
+			return object.NewError("%s", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if mismatch != "" {
+		return object.NewError("cannot destructure %s: %s", value.Inspect(), mismatch)
+	}
+	return NULL
+}
+
+// evalArguments evaluates the arguments of a call of function, expanding
+// spread arrays in place and placing named arguments at the positions of
+// their parameters.
+func (e *evaluator) evalArguments(function object.Object, args []ast.Expression, env *object.Environment) ([]object.Object, *object.Error) {
+	var results []object.Object
+	var names []string
+	var values []object.Object
+	for _, a := range args {
+		if named, ok := a.(*ast.NamedArgument); ok {
+			result := e.evalNode(named.Value, env)
+			if err, ok := result.(*object.Error); ok {
+				return nil, err
+			}
+			names = append(names, named.Name)
+			values = append(values, result)
+			continue
+		}
+		spread, ok := a.(*ast.SpreadExpression)
+		if !ok {
+			result := e.evalNode(a, env)
+			if err, ok := result.(*object.Error); ok {
+				return nil, err
+			}
+			results = append(results, result)
+			continue
+		}
+
+		result := e.evalNode(spread.Value, env)
+		if err, ok := result.(*object.Error); ok {
+			return nil, err
+		}
+		array, ok := result.(*object.Array)
+		if !ok {
+			return nil, object.NewError("cannot spread %s, want ARRAY", result.Type())
+		}
+		results = append(results, array.Elements...)
+	}
+	if names == nil {
+		return results, nil
+	}
+
+	switch function := function.(type) {
+	case *object.Function:
+		var parameters []string
+		required := 0
+		for _, p := range function.Parameters {
+			name := ""
+			if ident, ok := p.Pattern.(*ast.Identifier); ok {
+				name = ident.Name
+			}
+			parameters = append(parameters, name)
+			if p.Default == nil {
+				required++
+			}
+		}
+		return NamedArguments(parameters, required, results, names, values)
+	case *object.Builtin:
+		return nil, object.NewError("%s does not take named arguments", function.Name)
+	default:
+		return nil, object.NewError("not a function: %s", function.Type())
+	}
+}
+
+// NamedArguments returns the arguments of a call passing args by position
+// and values by the parameter names, to a function whose parameters are
+// named parameters ("" for destructuring ones) and whose first required
+// parameters have no default. The parameters left without argument are nil
+// in the result, to be bound to their defaults.
+func NamedArguments(parameters []string, required int, args []object.Object, names []string, values []object.Object) ([]object.Object, *object.Error) {
+	results := append([]object.Object(nil), args...)
+	for i, name := range names {
+		index := -1
+		for j, parameter := range parameters {
+			if parameter == name {
+				index = j
+				break
+			}
+		}
+		switch {
+		case index < 0:
+			return nil, object.NewError("no parameter named %s", name)
+		case index < len(args):
+			return nil, object.NewError("argument %s passed by position and by name", name)
+		}
+		for len(results) <= index {
+			results = append(results, nil)
+		}
+		results[index] = values[i]
+	}
+	for i := 0; i < required; i++ {
+		if i >= len(results) || results[i] == nil {
+			if parameters[i] == "" {
+				return nil, object.NewError("missing argument %d", i+1)
+			}
+			return nil, object.NewError("missing argument %s", parameters[i])
+		}
+	}
+	return results, nil
+}
+
+// evalIdentifier looks up ident in its slot if the parser resolved it to one,
+// and by name otherwise.
+func (e *evaluator) evalIdentifier(ident *ast.Identifier, env *object.Environment) object.Object {
+	if ident.Binding != nil {
+		if value, ok := env.GetSlot(ident.Binding.Depth, ident.Binding.Slot); ok {
+			return value
+		}
+		return object.NewError("identifier not found: %s", ident.Name)
+	}
+
+	value, ok := env.Get(ident.Name)
+	if ok {
+		return value
+	}
+	if builtin, ok := Builtin(ident.Name, e); ok {
+		return builtin
+	}
+	return object.NewError("identifier not found: %s", ident.Name)
+}
+
+// evalWhileStatement evaluates the body in a new environment for each
+// iteration, so that its bindings do not leak into the next one.
+func (e *evaluator) evalWhileStatement(while *ast.WhileStatement, env *object.Environment) object.Object {
+	mark := len(e.temps)
+	for {
+		e.temps = e.temps[:mark]
+		if err := e.checkCanceled(); err != nil {
+			return err
+		}
+
+		cond := e.evalNode(while.Condition, env)
+		if isError(cond) {
+			return cond
+		}
+		if !Truthy(cond) {
+			return NULL
+		}
+
+		bodyEnv := object.NewLocalEnvironment(env, while.Slots)
+		e.pushScope(bodyEnv)
+		result := e.evalNode(while.Body, bodyEnv)
+		e.popScope()
+		switch result.(type) {
+		case *object.ReturnValue, *object.Error:
+			return result
+		}
+	}
+}
+
+// checkCanceled returns an error if the context of the evaluation is done.
+func (e *evaluator) checkCanceled() *object.Error {
+	if err := e.ctx.Err(); err != nil {
+		return &object.Error{Kind: object.CanceledErrorKind, Message: err.Error()}
+	}
+	return nil
+}
+
+// evalIfExpression evaluates the branch chosen by the condition, in tail
+// position if the if expression itself is.
+func (e *evaluator) evalIfExpression(ifExp *ast.IfExpression, env *object.Environment, tail bool) object.Object {
+	cond := e.evalNode(ifExp.Condition, env)
+	if isError(cond) {
+		return cond
+	}
+	if Truthy(cond) {
+		return e.evalBranch(ifExp.Consequence, env, tail)
 	}
 
 	if ifExp.Alternative == nil {
 		return NULL
 	}
-	return Eval(ifExp.Alternative)
+	return e.evalBranch(ifExp.Alternative, env, tail)
 }
 
-func truthy(cond object.Object) bool {
+// Truthy reports whether cond counts as true in a condition.
+func Truthy(cond object.Object) bool {
 	if cond == FALSE || cond == NULL {
 		return false
 	}
 	return true
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+// isError reports whether obj is an error propagating through the evaluation.
+// A CaughtError is an ordinary value and is not.
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
+
+// Prefix applies the prefix operator to right.
+func Prefix(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangExpression(right)
@@ -95,19 +506,30 @@ func evalBangExpression(right object.Object) object.Object {
 }
 
 func evalMinusExpression(right object.Object) object.Object {
-	integer, ok := right.(*object.Integer)
-	if !ok {
+	switch right := right.(type) {
+	case *object.Integer:
+		return object.NewInteger(-right.Value)
+	case *object.Float:
+		return object.NewFloat(-right.Value)
+	default:
 		return NULL
 	}
-	return object.NewInteger(-integer.Value)
 }
 
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+// Infix applies the infix operator to left and right. It returns NULL for
+// operands the operator does not support. Like Prefix, Index, Member and
+// Truthy, it is exported so that the bytecode VM shares the semantics of the
+// evaluator.
+func Infix(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.IntegerType && right.Type() == object.IntegerType:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right) && (left.Type() == object.FloatType || right.Type() == object.FloatType):
+		return evalFloatInfixExpression(operator, toFloat(left), toFloat(right))
 	case left.Type() == object.BooleanType && right.Type() == object.BooleanType:
 		return evalBooleanInfixExpression(operator, left, right)
+	case left.Type() == object.StringType && right.Type() == object.StringType:
+		return evalStringInfixExpression(operator, left, right)
 	default:
 		return NULL
 	}
@@ -128,7 +550,15 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return object.NewInteger(intLeft.Value * intRight.Value)
 	case "/":
+		if intRight.Value == 0 {
+			return object.NewError("division by zero")
+		}
 		return object.NewInteger(intLeft.Value / intRight.Value)
+	case "%":
+		if intRight.Value == 0 {
+			return object.NewError("division by zero")
+		}
+		return object.NewInteger(intLeft.Value % intRight.Value)
 	case "==":
 		return booleanObject(intLeft.Value == intRight.Value)
 	case "!=":
@@ -142,6 +572,43 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
+// evalFloatInfixExpression evaluates arithmetic on floats, and on integers
+// mixed with floats. Division by zero follows IEEE 754 rather than raising an
+// error.
+func evalFloatInfixExpression(operator string, left, right float64) object.Object {
+	switch operator {
+	case "+":
+		return object.NewFloat(left + right)
+	case "-":
+		return object.NewFloat(left - right)
+	case "*":
+		return object.NewFloat(left * right)
+	case "/":
+		return object.NewFloat(left / right)
+	case "==":
+		return booleanObject(left == right)
+	case "!=":
+		return booleanObject(left != right)
+	case ">":
+		return booleanObject(left > right)
+	case "<":
+		return booleanObject(left < right)
+	default:
+		return NULL
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.IntegerType || obj.Type() == object.FloatType
+}
+
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
 func evalBooleanInfixExpression(operator string, left, right object.Object) object.Object {
 	boolLeft, ok1 := left.(*object.Boolean)
 	boolRight, ok2 := right.(*object.Boolean)
@@ -158,3 +625,243 @@ func evalBooleanInfixExpression(operator string, left, right object.Object) obje
 		return NULL
 	}
 }
+
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	strLeft, ok1 := left.(*object.String)
+	strRight, ok2 := right.(*object.String)
+	if !ok1 || !ok2 {
+		return NULL
+	}
+
+	switch operator {
+	case "+":
+		return object.NewString(strLeft.Value + strRight.Value)
+	case "==":
+		return booleanObject(strLeft.Value == strRight.Value)
+	case "!=":
+		return booleanObject(strLeft.Value != strRight.Value)
+	default:
+		return NULL
+	}
+}
+
+func (e *evaluator) evalHashLiteral(hashLiteral *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+	for _, p := range hashLiteral.Pairs {
+		key := e.evalNode(p.Key, env)
+		if isError(key) {
+			return key
+		}
+		hashableKey, ok := key.(object.Hashable)
+		if !ok {
+			return object.NewError("unusable as hash key: %s", key.Type())
+		}
+
+		value := e.evalNode(p.Value, env)
+		if isError(value) {
+			return value
+		}
+		hash.Set(hashableKey, value)
+	}
+	return hash
+}
+
+// Index returns the element of left at index.
+func Index(left, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return object.NewError("array index must be INTEGER, got %s", index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return NULL
+		}
+		return left.Elements[i.Value]
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return object.NewError("unusable as hash key: %s", index.Type())
+		}
+		value, ok := left.Get(key)
+		if !ok {
+			return NULL
+		}
+		return value
+	case *object.CaughtError:
+		return evalErrorIndexExpression(left.Error, index)
+	default:
+		return object.NewError("index operator not supported: %s", left.Type())
+	}
+}
+
+// Member returns the member name of a host object.
+func Member(obj object.Object, name string) object.Object {
+	host, ok := obj.(*object.HostObject)
+	if !ok {
+		return object.NewError("member access not supported: %s", obj.Type())
+	}
+
+	member, err := host.GetMember(name)
+	if err != nil {
+		return object.NewError("%s", err)
+	}
+	return member
+}
+
+// applyFunction calls obj with args, recording a frame for the call made at pos.
+func (e *evaluator) applyFunction(obj object.Object, args []object.Object, pos token.Position) object.Object {
+	if err := e.checkCanceled(); err != nil {
+		return err
+	}
+	if e.maxDepth > 0 && len(e.frames) >= e.maxDepth {
+		return object.NewError("maximum recursion depth exceeded (%d)", e.maxDepth)
+	}
+
+	switch function := obj.(type) {
+	case *object.Function:
+		if err := checkArity(function, len(args)); err != nil {
+			return err
+		}
+
+		e.pushFrame(function.Name, pos)
+		defer e.popFrame()
+		return e.callFunction(function, args)
+	case *object.Builtin:
+		e.pushFrame(function.Name, pos)
+		defer e.popFrame()
+		result := function.Fn(args...)
+		if err := e.chargeResult(result, args); err != nil {
+			return err
+		}
+		return result
+	default:
+		return object.NewError("not a function: %s", obj.Type())
+	}
+}
+
+func (e *evaluator) pushFrame(name string, pos token.Position) {
+	if name == "" {
+		name = "<anonymous>"
+	}
+	e.frames = append(e.frames, object.Frame{Function: name, Position: pos})
+}
+
+func (e *evaluator) popFrame() {
+	e.frames = e.frames[:len(e.frames)-1]
+}
+
+// replaceFrame replaces the frame of the current call by the frame of a tail
+// call it makes, counting the replaced frames to show them in tracebacks.
+func (e *evaluator) replaceFrame(name string, pos token.Position) {
+	elided := e.frames[len(e.frames)-1].Elided + 1
+	e.popFrame()
+	e.pushFrame(name, pos)
+	e.frames[len(e.frames)-1].Elided = elided
+}
+
+// callFunction evaluates the body of function with args bound to its
+// parameters. Tail calls made by the body are run in a loop here rather than
+// recursively, replacing the frame of the current call.
+func (e *evaluator) callFunction(function *object.Function, args []object.Object) object.Object {
+	mark := len(e.temps)
+	for {
+		env, err := e.bindArguments(function, args)
+		if err != nil {
+			return err
+		}
+
+		e.pushScope(env)
+		result := e.evalTail(function.Body, env)
+		e.popScope()
+		if returnValue, ok := result.(*object.ReturnValue); ok {
+			return returnValue.Value
+		}
+		call, ok := result.(*tailCall)
+		if !ok {
+			return result
+		}
+
+		if err := e.checkCanceled(); err != nil {
+			return err
+		}
+		function, args = call.function, call.args
+		e.temps = append(append(e.temps[:mark], function), args...)
+		e.replaceFrame(function.Name, call.position)
+	}
+}
+
+// bindArguments returns a new environment for the body of function, with args
+// bound to its parameters.
+func (e *evaluator) bindArguments(function *object.Function, args []object.Object) (*object.Environment, *object.Error) {
+	env := object.NewLocalEnvironment(function.Env, function.Slots)
+	bindParameter := func(ident *ast.Identifier, value object.Object) *object.Error {
+		bind(env, ident, value)
+		return nil
+	}
+	for i, p := range function.Parameters {
+		var arg object.Object
+		if i < len(args) && args[i] != nil {
+			arg = args[i]
+		} else {
+			// defaults are evaluated at each call, seeing the preceding parameters
+			arg = e.evalNode(p.Default, env)
+			if err, ok := arg.(*object.Error); ok {
+				return nil, err
+			}
+		}
+
+		mismatch, err := e.destructure(p.Pattern, arg, env, bindParameter)
+		if err != nil {
+			return nil, err
+		}
+		if mismatch != "" {
+			return nil, object.NewError("cannot destructure argument %d: %s", i+1, mismatch)
+		}
+	}
+	if function.Rest != nil {
+		var rest []object.Object
+		if len(args) > len(function.Parameters) {
+			rest = append(rest, args[len(function.Parameters):]...)
+		}
+		bind(env, function.Rest, object.NewArray(rest))
+	}
+	return env, nil
+}
+
+// bind binds ident, declared in the scope of env, to value.
+func bind(env *object.Environment, ident *ast.Identifier, value object.Object) {
+	if ident.Binding != nil {
+		env.SetSlot(0, ident.Binding.Slot, value)
+	} else {
+		env.Set(ident.Name, value)
+	}
+}
+
+// checkArity returns an error describing the expected number of arguments if
+// function cannot be called with n arguments.
+func checkArity(function *object.Function, n int) *object.Error {
+	required := 0
+	for _, p := range function.Parameters {
+		if p.Default == nil {
+			required++
+		}
+	}
+	max := len(function.Parameters)
+
+	switch {
+	case function.Rest != nil:
+		if n < required {
+			return object.NewError("wrong number of arguments: want at least %d, got %d", required, n)
+		}
+	case required == max:
+		if n != required {
+			return object.NewError("wrong number of arguments: want=%d, got=%d", required, n)
+		}
+	default:
+		if n < required || n > max {
+			return object.NewError("wrong number of arguments: want %d to %d, got %d", required, max, n)
+		}
+	}
+	return nil
+}
@@ -1,7 +1,11 @@
 package evaluate
 
 import (
+	"context"
+	"runtime/debug"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/maiyama18/dog/lex"
 	"github.com/maiyama18/dog/object"
@@ -31,6 +35,35 @@ func TestEvalInteger(t *testing.T) {
 	}
 }
 
+func TestEvalFloat(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "x", want: "1.5"},
+		{input: "-x", want: "-1.5"},
+		{input: "x + x", want: "3"},
+		{input: "x * 2", want: "3"},
+		{input: "3 / x", want: "2"},
+		{input: "x / 0", want: "+Inf"},
+		{input: "x - 1", want: "0.5"},
+		{input: "x > 1", want: "true"},
+		{input: "x == x * 1", want: "true"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			env := object.NewEnvironment()
+			env.Set("x", object.NewFloat(1.5))
+
+			program := parse.NewParser(lex.NewLexer(test.input)).ParseProgram()
+			got := EvalWithEnvironment(program, env)
+			if got.Inspect() != test.want {
+				t.Fatalf("result wrong. want=%q, got=%q", test.want, got.Inspect())
+			}
+		})
+	}
+}
+
 func TestEvalBoolean(t *testing.T) {
 	tests := []struct {
 		input string
@@ -91,6 +124,825 @@ func TestEvalIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestEvalAssignExpression(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{input: "let x = 1; x = 5; x", want: 5},
+		{input: "let x = 1; x = 5", want: 5},
+		{input: "let x = 1; let y = 2; x = y = 3; x + y", want: 6},
+		{input: "let x = 10; x += 5; x", want: 15},
+		{input: "let x = 10; x -= 5; x", want: 5},
+		{input: "let x = 10; x *= 5; x", want: 50},
+		{input: "let x = 10; x /= 5; x", want: 2},
+		{input: "let x = 10; x %= 4; x", want: 2},
+		{input: `let s = "foo"; s += "bar"; s`, want: "foobar"},
+		{input: "let x = 1; let inc = fn() { x += 1 }; inc(); inc(); x", want: 3},
+		{input: "let x = 1; let f = fn() { let x = 10; x = 20 }; f(); x", want: 1},
+		{input: "let a = [1, 2, 3]; a[1] = 20; a[1]", want: 20},
+		{input: "let a = [1, 2, 3]; a[2] *= 10; a[2]", want: 30},
+		{input: `let h = {"k": 1}; h["k"] = 2; h["k"]`, want: 2},
+		{input: `let h = {}; h["k"] = 2; h["k"]`, want: 2},
+		{input: `let h = {"k": 1}; h["k"] += 41; h["k"]`, want: 42},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := eval(test.input)
+			switch want := test.want.(type) {
+			case int:
+				testInteger(t, got, int64(want))
+			case string:
+				testString(t, got, want)
+			}
+		})
+	}
+}
+
+func TestEvalAssignExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "y = 1", want: "assignment to undeclared identifier: y"},
+		{input: "y += 1", want: "assignment to undeclared identifier: y"},
+		{input: "let x = 1; x /= 0", want: "division by zero"},
+		{input: `let x = 1; x += "a"`, want: "unsupported operands for +=: INTEGER and STRING"},
+		{input: "let a = [1]; a[1] = 2", want: "array index out of range: index=1, length=1"},
+		{input: `let h = {}; h["k"] += 1`, want: "key not found in hash: k"},
+		{input: "let x = 1; x[0] = 1", want: "index assignment not supported: INTEGER"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := eval(test.input)
+			testError(t, got, test.want)
+		})
+	}
+}
+
+func TestEvalConstStatement(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{input: "const x = 5; x", want: 5},
+		{input: "const x = 5; let f = fn() { let x = 1; x += 1; x }; f() + x", want: 7},
+		{input: "let f = fn() { x = 2 }; const x = 1; f()", want: "cannot assign to constant x declared at 1:31"},
+		{input: "let f = fn() { x += 2 }; const x = 1; f(); x", want: "cannot assign to constant x declared at 1:32"},
+		{input: "let f = fn() { const y = 1; y }; f(); let y = 2; y = 3", want: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := eval(test.input)
+			switch want := test.want.(type) {
+			case int:
+				testInteger(t, got, int64(want))
+			case string:
+				testError(t, got, want)
+			}
+		})
+	}
+}
+
+func TestEvalMatchExpression(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{input: `match (1) { 1 => "one", _ => "other" }`, want: "one"},
+		{input: `match (2) { 1 => "one", _ => "other" }`, want: "other"},
+		{input: `match (-1) { -1 => "minus one", _ => "other" }`, want: "minus one"},
+		{input: `match ("a") { 1 => "int", "a" => "string" }`, want: "string"},
+		{input: `match (true) { false => "f", true => "t" }`, want: "t"},
+		{input: `match (1) { "1" => "string", x => "bound" }`, want: "bound"},
+		{input: "match (5) { x => x * 2 }", want: 10},
+		{input: "match ([]) { [] => 0, [h, ..t] => h }", want: 0},
+		{input: "match ([1, 2, 3]) { [] => 0, [h, ..t] => h }", want: 1},
+		{input: "match ([1, 2, 3]) { [h, ..t] => t[1] }", want: 3},
+		{input: "match ([1, 2]) { [a] => 1, [a, b, c] => 3, [a, b] => 2 }", want: 2},
+		{input: "match ([1, [2, 3]]) { [_, [a, b]] => a + b }", want: 5},
+		{input: "match ([1, 2]) { [a, 3] => a, [a, 2] => a * 10 }", want: 10},
+		{input: `match ({"name": "dog", "age": 3}) { {"name": "cat"} => "cat", {name, age} => name }`, want: "dog"},
+		{input: `match ({"age": 3}) { {name} => 1, {"age": a} => a }`, want: 3},
+		{input: `match ([1, 2]) { {} => "hash", [] => "empty", _ => "array" }`, want: "array"},
+		{input: "match (5) { x if x > 10 => 1, x if x > 3 => 2, _ => 3 }", want: 2},
+		{input: "let x = 1; match (5) { x => x }; x", want: 1},
+		{input: "let n = 0; match (5) { x => n = x }; n", want: 5},
+		{input: "match (5) { 1 => 1, 2 => 2 }", want: "no match arm matched value: 5"},
+		{input: "match (y) { _ => 1 }", want: "identifier not found: y"},
+		{input: "match (5) { x if y => 1 }", want: "identifier not found: y"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := eval(test.input)
+			switch want := test.want.(type) {
+			case int:
+				testInteger(t, got, int64(want))
+			case string:
+				if _, ok := got.(*object.Error); ok {
+					testError(t, got, want)
+				} else {
+					testString(t, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEvalDestructuring(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{input: "let [a, b] = [1, 2]; a * 10 + b", want: 12},
+		{input: "let [a, [b, c]] = [1, [2, 3]]; a + b + c", want: 6},
+		{input: "let [h, ..t] = [1, 2, 3]; h + t[0] + t[1]", want: 6},
+		{input: "let [_, b] = [1, 2]; b", want: 2},
+		{input: `let {name, age} = {"name": "dog", "age": 3}; name`, want: "dog"},
+		{input: `let {"age": a, "tags": [t]} = {"age": 3, "tags": [4]}; a + t`, want: 7},
+		{input: "const [a, b] = [1, 2]; a + b", want: 3},
+		{input: "let f = fn([x, y]) { x + y }; f([1, 2])", want: 3},
+		{input: `let f = fn(n, {name}) { name }; f(1, {"name": "dog"})`, want: "dog"},
+		{input: "let [a, b] = [1];", want: "cannot destructure [1]: array pattern [a, b] expects 2 elements, got 1"},
+		{input: "let [a, ..b] = [];", want: "cannot destructure []: array pattern [a, ..b] expects at least 1 elements, got 0"},
+		{input: "let [a, b] = 1;", want: "cannot destructure 1: array pattern [a, b] cannot match INTEGER"},
+		{input: `let {name} = {"age": 1};`, want: `cannot destructure {age: 1}: hash pattern {"name": name} expects key "name"`},
+		{input: "let {name} = [1];", want: `cannot destructure [1]: hash pattern {"name": name} cannot match ARRAY`},
+		{input: "const [a, b] = [1, 2]; let f = fn() { a = 3 }; f()", want: "cannot assign to constant a declared at 1:8"},
+		{input: "let f = fn([x, y]) { x + y }; f([1, 2, 3])", want: "cannot destructure argument 1: array pattern [x, y] expects 2 elements, got 3"},
+		{input: "let f = fn(a, {b}) { b }; f(1, 2)", want: `cannot destructure argument 2: hash pattern {"b": b} cannot match INTEGER`},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := eval(test.input)
+			switch want := test.want.(type) {
+			case int:
+				testInteger(t, got, int64(want))
+			case string:
+				if _, ok := got.(*object.Error); ok {
+					testError(t, got, want)
+				} else {
+					testString(t, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEvalFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{input: "let f = fn(x, y = 10) { x + y }; f(1)", want: 11},
+		{input: "let f = fn(x, y = 10) { x + y }; f(1, 2)", want: 3},
+		{input: "let f = fn(x, y = x * 2) { x + y }; f(5)", want: 15},
+		{input: "let n = 1; let f = fn(x = n) { x }; n = 2; f()", want: 2},
+		{input: "let f = fn([a, b] = [1, 2]) { a + b }; f()", want: 3},
+		{input: "let f = fn(first, ...rest) { rest }; f(1)[0]", want: nil},
+		{input: "let f = fn(first, ...rest) { first + rest[0] + rest[1] }; f(1, 2, 3)", want: 6},
+		{input: "let f = fn(x, y = 2, ...rest) { y }; f(1)", want: 2},
+		{input: "let f = fn(x, y) { x - y }; let args = [10, 3]; f(...args)", want: 7},
+		{input: "let f = fn(x, y, z) { x * 100 + y * 10 + z }; f(1, ...[2, 3])", want: 123},
+		{input: "let f = fn(...xs) { xs[2] }; f(...[1], 2, ...[3, 4])", want: 3},
+		{input: "let f = fn(x, y) { x }; f(1)", want: "wrong number of arguments: want=2, got=1"},
+		{input: "let f = fn(x, y = 1) { x }; f()", want: "wrong number of arguments: want 1 to 2, got 0"},
+		{input: "let f = fn(x, y = 1) { x }; f(1, 2, 3)", want: "wrong number of arguments: want 1 to 2, got 3"},
+		{input: "let f = fn(x, ...rest) { x }; f()", want: "wrong number of arguments: want at least 1, got 0"},
+		{input: "let f = fn(x, y) { x }; f(...[1, 2, 3])", want: "wrong number of arguments: want=2, got=3"},
+		{input: "let f = fn(x) { x }; f(...1)", want: "cannot spread INTEGER, want ARRAY"},
+		{input: "let f = fn(x = y) { x }; f()", want: "identifier not found: y"},
+		{input: "let f = fn(x, y = 2, z = 3) { x * 100 + y * 10 + z }; f(1, z: 5)", want: 125},
+		{input: "let f = fn(x, y = 2, z = 3) { x * 100 + y * 10 + z }; f(z: 5, x: 4)", want: 425},
+		{input: "let f = fn(x, y = x + 1) { y }; f(x: 1)", want: 2},
+		{input: "let f = fn(x, y = 2, ...rest) { len(rest) * 10 + y }; f(...[1], y: 5)", want: 5},
+		{input: "let f = fn() { fn(x, y) { x - y } }; f()(y: 1, x: 3)", want: 2},
+		{input: "let f = fn(x, y = 2) { x }; f(1, z: 3)", want: "no parameter named z"},
+		{input: "let f = fn(x, y = 2) { x }; f(1, x: 3)", want: "argument x passed by position and by name"},
+		{input: "let f = fn(x, y) { x }; f(y: 3)", want: "missing argument x"},
+		{input: "let f = fn([x], y) { x }; f(y: 3)", want: "missing argument 1"},
+		{input: "len(x: [1])", want: "len does not take named arguments"},
+		{input: "let f = fn(x, y = 2, z = 3) { x + y + z }; let g = fn() { f(1, z: 10) }; g()", want: 13},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := eval(test.input)
+			switch want := test.want.(type) {
+			case int:
+				testInteger(t, got, int64(want))
+			case string:
+				testError(t, got, want)
+			case nil:
+				testNull(t, got)
+			}
+		})
+	}
+}
+
+func TestEvalResolvedScopes(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{input: "let make = fn() { let n = 0; fn() { n += 1 } }; let c = make(); c(); c()", want: 2},
+		{input: "let f = fn() { let g = fn() { h }; let h = 5; g() }; f()", want: 5},
+		{input: "let f = fn(n) { let even = fn(n) { if (n == 0) { 1 } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { 0 } else { even(n - 1) } }; even(n) }; f(7)", want: 0},
+		{input: "let f = fn(x) { let [a, b] = x; match (b) { [c, ..d] => a + c + d[0] } }; f([1, [2, 3]])", want: 6},
+		{input: "let f = fn() { let i = 0; let s = 0; while (i < 4) { let j = i; i += 1; s += j }; s }; f()", want: 6},
+		{input: "let f = fn() { try { throw 1 } catch (e) { let v = e[\"value\"]; v + 1 } }; f()", want: 2},
+		{input: "let x = 1; let f = fn() { let g = fn() { x }; g() }; x = 3; f()", want: 3},
+		{input: "let f = fn() { let g = fn() { h }; let r = g(); let h = 1; r }; f()", want: "identifier not found: h"},
+		{input: "let f = fn() { if (false) { let y = 1 }; y }; f()", want: "identifier not found: y"},
+		{input: "let f = fn() { if (false) { let y = 1 }; y = 2 }; f()", want: "assignment to undeclared identifier: y"},
+		{input: "let f = fn(c) { if (c) { let x = 1; x } else { let x = 2; x } }; f(true)", want: 1},
+		{input: "let f = fn(c) { if (c) { let x = 1; x } else { let x = 2; x } }; f(false)", want: 2},
+		{input: "let f = fn() { let x = 1; if (true) { let x = 2 }; x }; f()", want: 1},
+		{input: "let f = fn() { let n = 0; try { let x = 1; n += x } finally { let x = 2; n += x }; n }; f()", want: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := eval(test.input)
+			switch want := test.want.(type) {
+			case int:
+				testInteger(t, got, int64(want))
+			case string:
+				testError(t, got, want)
+			}
+		})
+	}
+}
+
+func TestEvalTryExpression(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{input: "try { 1 } catch (e) { 2 }", want: 1},
+		{input: "try { throw 1; 2 } catch (e) { 3 }", want: 3},
+		{input: `try { throw "boom" } catch (e) { e["message"] }`, want: "boom"},
+		{input: `try { throw "boom" } catch (e) { e["kind"] }`, want: "Error"},
+		{input: `try { throw 42 } catch (e) { e["value"] }`, want: 42},
+		{input: `try { throw {"kind": "ValueError", "message": "bad"} } catch (e) { e["kind"] + ": " + e["message"] }`, want: "ValueError: bad"},
+		{input: `try { 1 / 0 } catch (e) { e["kind"] + ": " + e["message"] }`, want: "RuntimeError: division by zero"},
+		{input: `try { x } catch (e) { e["message"] }`, want: "identifier not found: x"},
+		{input: "try {\n  1 +\n    x\n} catch (e) { e[\"line\"] * 10 + e[\"column\"] }", want: 35},
+		{input: "try {\n  throw 1\n} catch (e) { e[\"line\"] * 10 + e[\"column\"] }", want: 23},
+		{input: `let f = fn() { throw "inner" }; try { f() } catch (e) { e["message"] }`, want: "inner"},
+		{input: `try { try { throw "a" } catch (e) { throw e } } catch (e) { e["message"] }`, want: "a"},
+		{input: `try { try { throw "a" } finally { 1 } } catch (e) { e["message"] }`, want: "a"},
+		{input: `let e = 1; try { throw "a" } catch (e) { 2 }; e`, want: 1},
+		{input: `let caught = try { throw "a" } catch (e) { e }; caught["message"]`, want: "a"},
+		{input: "let n = 0; try { n = 1 } finally { n += 10 }; n", want: 11},
+		{input: "let n = 0; try { throw 1 } catch (e) { n = 1 } finally { n += 10 }; n", want: 11},
+		{input: "let n = 0; try { try { throw 1 } finally { n = 5 } } catch (e) { n += 1 }; n", want: 6},
+		{input: "let n = 0; let f = fn() { try { return 1 } finally { n = 7 } }; f() + n", want: 8},
+		{input: "let f = fn() { try { return 1 } finally { return 2 } }; f()", want: 2},
+		{input: "let f = fn() { try { throw 1 } finally { return 2 } }; f()", want: 2},
+		{input: "try { 1 } finally { 2 }", want: 1},
+		{input: `throw "boom"`, want: "boom"},
+		{input: "try { throw 1 } catch (e) { throw 2 }", want: "2"},
+		{input: "try { 1 } finally { throw 3 }", want: "3"},
+		{input: `try { throw "a" } catch (e) { e["nope"] }`, want: "unknown error field: nope"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := eval(test.input)
+			switch want := test.want.(type) {
+			case int:
+				testInteger(t, got, int64(want))
+			case string:
+				if _, ok := got.(*object.Error); ok {
+					testError(t, got, want)
+				} else {
+					testString(t, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEvalErrorPosition(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "1 + x", want: "1:5: RuntimeError: identifier not found: x"},
+		{input: "let a = 1;\nlet b = a / 0;", want: "2:11: RuntimeError: division by zero"},
+		{input: "let f = fn() {\n  throw \"boom\"\n};\nf()", want: "2:3: Error: boom"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := eval(test.input)
+			if _, ok := got.(*object.Error); !ok {
+				t.Fatalf("not Error: %+v", got)
+			}
+			if got.Inspect() != test.want {
+				t.Fatalf("error wrong. want=%q, got=%q", test.want, got.Inspect())
+			}
+		})
+	}
+}
+
+func TestEvalErrorStack(t *testing.T) {
+	input := `let divide = fn(a, b) {
+  a / b
+};
+let half = fn(n) { let h = divide(n, 0); h };
+let apply = fn(f, x) { let y = f(x); y };
+apply(half, 1)`
+
+	got := eval(input)
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("not Error: %+v", got)
+	}
+
+	want := `Traceback (most recent call last):
+  apply (called at 6:6)
+  half (called at 5:33)
+  divide (called at 4:34)
+2:5: RuntimeError: division by zero`
+	if err.Traceback() != want {
+		t.Fatalf("traceback wrong. want=\n%s\ngot=\n%s", want, err.Traceback())
+	}
+}
+
+func TestEvalErrorStackTailCalls(t *testing.T) {
+	input := `let c = fn(n) { n / 0 };
+let b = fn(n) { c(n) };
+let a = fn(n) { b(n) };
+let main = fn() { let r = a(1); r };
+main()`
+
+	got := eval(input)
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("not Error: %+v", got)
+	}
+
+	want := `Traceback (most recent call last):
+  main (called at 5:5)
+  ... 2 tail calls elided
+  c (called at 2:18)
+1:19: RuntimeError: division by zero`
+	if err.Traceback() != want {
+		t.Fatalf("traceback wrong. want=\n%s\ngot=\n%s", want, err.Traceback())
+	}
+}
+
+func TestEvalErrorStackRecursion(t *testing.T) {
+	got := eval("let f = fn(n) { f(n + 1) + 1 };\nf(0)")
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("not Error: %+v", got)
+	}
+
+	want := `Traceback (most recent call last):
+  f (called at 2:2)
+  f (called at 1:18)
+  f (called at 1:18)
+  f (called at 1:18)
+  [previous frame repeated 9996 more times]
+1:18: RuntimeError: maximum recursion depth exceeded (10000)`
+	if err.Traceback() != want {
+		t.Fatalf("traceback wrong. want=\n%s\ngot=\n%s", want, err.Traceback())
+	}
+
+	got = eval("let f = fn(n) { g(n) + 1 };\nlet g = fn(n) { f(n) + 1 };\nf(0)")
+	err, ok = got.(*object.Error)
+	if !ok {
+		t.Fatalf("not Error: %+v", got)
+	}
+	lines := strings.Split(err.Traceback(), "\n")
+	if len(lines) != 103 || lines[51] != "  ... 9900 frames omitted" {
+		t.Fatalf("traceback of mutual recursion wrong: %d lines, line 52 %q", len(lines), lines[51])
+	}
+}
+
+func TestEvalStacktrace(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{
+			input: "stacktrace()",
+			want:  []string{},
+		},
+		{
+			input: "let f = fn() { stacktrace() }; let g = fn() { let s = f(); s }; g()",
+			want:  []string{"g (called at 1:66)", "f (called at 1:56)"},
+		},
+		{
+			input: "fn() { stacktrace() }()",
+			want:  []string{"<anonymous> (called at 1:22)"},
+		},
+		{
+			input: `let f = fn() { throw "x" }; try { f() } catch (e) { e["stack"] }`,
+			want:  []string{"f (called at 1:36)"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := eval(test.input)
+			array, ok := got.(*object.Array)
+			if !ok {
+				t.Fatalf("not Array: %+v", got)
+			}
+			if len(array.Elements) != len(test.want) {
+				t.Fatalf("frames length wrong. want=%d, got=%d (%s)", len(test.want), len(array.Elements), array.Inspect())
+			}
+			for i, w := range test.want {
+				testString(t, array.Elements[i], w)
+			}
+		})
+	}
+}
+
+func TestEvalTailCalls(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{
+			input: "let loop = fn(n, acc) { if (n == 0) { acc } else { loop(n - 1, acc + n) } }; loop(100000, 0)",
+			want:  5000050000,
+		},
+		{
+			input: "let loop = fn(n, acc) { if (n == 0) { return acc; } return loop(n - 1, acc + 1); }; loop(100000, 0)",
+			want:  100000,
+		},
+		{
+			input: "let loop = fn(n, acc) { match (n) { 0 => acc, _ => loop(n - 1, acc + 2) } }; loop(100000, 0)",
+			want:  200000,
+		},
+		{
+			input: `let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+if (even(100001)) { 1 } else { 0 }`,
+			want: 0,
+		},
+		{
+			input: "let count = fn(n, ...acc) { if (n == 0) { acc[0] } else { count(n - 1, ...[acc[0] + 1]) } }; count(100000, 0)",
+			want:  100000,
+		},
+		{
+			input: "let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } }; fact(10)",
+			want:  3628800,
+		},
+	}
+	// without tail call optimization, recursing this deep would overflow the limited stack
+	defer debug.SetMaxStack(debug.SetMaxStack(8 << 20))
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := eval(test.input)
+			testInteger(t, got, test.want)
+		})
+	}
+}
+
+func TestEvalTailCallFrames(t *testing.T) {
+	input := `let f = fn(n) { if (n == 0) { stacktrace() } else { f(n - 1) } };
+let g = fn() { let s = f(3); s };
+g()`
+
+	got := eval(input)
+	array, ok := got.(*object.Array)
+	if !ok {
+		t.Fatalf("not Array: %+v", got)
+	}
+
+	// the frames of the tail calls to f replace each other
+	want := []string{"g (called at 3:2)", "f (called at 1:54)"}
+	if len(array.Elements) != len(want) {
+		t.Fatalf("frames length wrong. want=%d, got=%d (%s)", len(want), len(array.Elements), array.Inspect())
+	}
+	for i, w := range want {
+		testString(t, array.Elements[i], w)
+	}
+}
+
+func TestEvalMaxDepth(t *testing.T) {
+	tests := []struct {
+		input string
+		opts  []Option
+		want  interface{}
+	}{
+		{
+			input: "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100)",
+			want:  100,
+		},
+		{
+			input: "let f = fn(n) { 1 + f(n + 1) }; f(0)",
+			want:  "maximum recursion depth exceeded (10000)",
+		},
+		{
+			input: "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100)",
+			opts:  []Option{WithMaxDepth(50)},
+			want:  "maximum recursion depth exceeded (50)",
+		},
+		{
+			input: "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(50)",
+			opts:  []Option{WithMaxDepth(51)},
+			want:  50,
+		},
+		{
+			input: "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(20000)",
+			opts:  []Option{WithMaxDepth(0)},
+			want:  20000,
+		},
+		{
+			input: "let f = fn(n) { 1 + f(n + 1) }; try { f(0) } catch (e) { 42 }",
+			opts:  []Option{WithMaxDepth(10)},
+			want:  42,
+		},
+		{
+			input: "let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } }; loop(1000)",
+			opts:  []Option{WithMaxDepth(10)},
+			want:  0,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			program := parse.NewParser(lex.NewLexer(test.input)).ParseProgram()
+			got := Eval(program, test.opts...)
+			switch want := test.want.(type) {
+			case int:
+				testInteger(t, got, int64(want))
+			case string:
+				testError(t, got, want)
+			}
+		})
+	}
+}
+
+func TestEvalWhileStatement(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{input: "let i = 0; let sum = 0; while (i < 5) { i += 1; sum += i }; sum", want: 15},
+		{input: "let i = 0; while (false) { i = 1 }; i", want: 0},
+		{input: "let i = 0; while (i < 3) { const x = i; i = x + 1 }; i", want: 3},
+		{input: "let f = fn() { let i = 0; while (true) { i += 1; if (i == 4) { return i } } }; f()", want: 4},
+		{input: "while (true) { throw \"boom\" }", want: "boom"},
+		{input: "while (x) { 1 }", want: "identifier not found: x"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := eval(test.input)
+			switch want := test.want.(type) {
+			case int:
+				testInteger(t, got, int64(want))
+			case string:
+				testError(t, got, want)
+			}
+		})
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	tests := []struct {
+		input   string
+		timeout time.Duration
+		want    string
+	}{
+		{input: "while (true) { 1 }", timeout: 0, want: "context canceled"},
+		{input: "let f = fn() { 1 }; f()", timeout: 0, want: "context canceled"},
+		{input: "while (true) { 1 }", timeout: 10 * time.Millisecond, want: "context deadline exceeded"},
+		{input: "let f = fn(n) { f(n + 1) }; f(0)", timeout: 10 * time.Millisecond, want: "context deadline exceeded"},
+		{input: "try { while (true) { 1 } } catch (e) { 1 }", timeout: 10 * time.Millisecond, want: "context deadline exceeded"},
+		{input: "try { while (true) { 1 } } finally { while (true) { 1 } }", timeout: 10 * time.Millisecond, want: "context deadline exceeded"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if test.timeout > 0 {
+				ctx, cancel = context.WithTimeout(context.Background(), test.timeout)
+			} else {
+				cancel()
+			}
+			defer cancel()
+
+			program := parse.NewParser(lex.NewLexer(test.input)).ParseProgram()
+			got := EvalContext(ctx, program, object.NewEnvironment())
+			testError(t, got, test.want)
+			if kind := got.(*object.Error).Kind; kind != object.CanceledErrorKind {
+				t.Fatalf("error kind wrong. want=%q, got=%q", object.CanceledErrorKind, kind)
+			}
+		})
+	}
+}
+
+func TestEvalFuel(t *testing.T) {
+	tests := []struct {
+		input string
+		fuel  int64
+		want  interface{}
+	}{
+		// program, statement, infix and two integers
+		{input: "1 + 2", fuel: 5, want: 3},
+		{input: "1 + 2", fuel: 4, want: "fuel exhausted: consumed 5 of 4"},
+		// and one step per byte of the string
+		{input: `"abc"`, fuel: 6, want: "abc"},
+		{input: `"abc"`, fuel: 5, want: "fuel exhausted: consumed 6 of 5"},
+		{input: "let i = 0; while (true) { i += 1 }", fuel: 1000, want: "fuel exhausted: consumed 1001 of 1000"},
+		{input: "let f = fn(n) { f(n + 1) }; f(0)", fuel: 1000, want: "fuel exhausted: consumed 1001 of 1000"},
+		{input: "try { while (true) { 1 } } catch (e) { 1 } finally { 2 }", fuel: 100, want: "fuel exhausted: consumed 101 of 100"},
+		{input: "let i = 0; while (i < 100) { i += 1 }; i", fuel: 0, want: 100},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			program := parse.NewParser(lex.NewLexer(test.input)).ParseProgram()
+			got := Eval(program, WithFuel(test.fuel))
+			switch want := test.want.(type) {
+			case int:
+				testInteger(t, got, int64(want))
+			case string:
+				if str, ok := got.(*object.String); ok {
+					testString(t, str, want)
+					return
+				}
+				testError(t, got, want)
+				if kind := got.(*object.Error).Kind; kind != object.OutOfFuelErrorKind {
+					t.Fatalf("error kind wrong. want=%q, got=%q", object.OutOfFuelErrorKind, kind)
+				}
+			}
+		})
+	}
+}
+
+func TestEvalMemoryLimit(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{input: "let a = []; let i = 0; while (i < 10) { push(a, i); i += 1 }; len(a)", want: 10},
+		{input: "let a = []; while (true) { push(a, 1) }", want: "memory limit exceeded"},
+		{input: `let s = "x"; while (true) { s += s }`, want: "memory limit exceeded"},
+		{input: "let h = {}; let i = 0; while (true) { h[i] = i; i += 1 }", want: "memory limit exceeded"},
+		{input: "let i = 0; while (i < 100000) { let s = \"abcdefghij\" + \"abcdefghij\"; i += 1 }; i", want: 100000},
+		{input: "let a = []; try { while (true) { push(a, 1) } } catch (e) { a = 0; len(\"ok\") }", want: 2},
+		{
+			// the arrays are only intermediate values until the outermost is returned
+			input: "let f = fn(n) { if (n == 0) { [] } else { [f(n - 1), f(n - 1)] } }; len(f(16))",
+			want:  "memory limit exceeded: allocating",
+		},
+		{input: "let f = fn(n) { if (n == 0) { [] } else { [f(n - 1), f(n - 1)] } }; len(f(10))", want: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			program := parse.NewParser(lex.NewLexer(test.input)).ParseProgram()
+			got := Eval(program, WithMemory(NewMemory(1<<20)))
+			switch want := test.want.(type) {
+			case int:
+				testInteger(t, got, int64(want))
+			case string:
+				err, ok := got.(*object.Error)
+				if !ok {
+					t.Fatalf("not Error: %+v", got)
+				}
+				if !strings.HasPrefix(err.Message, want) {
+					t.Fatalf("error message wrong. want prefix %q, got=%q", want, err.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestEvalMemoryLimitBuiltins(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Define("repeat", &object.Builtin{Name: "repeat", Fn: func(args ...object.Object) object.Object {
+		return object.NewString(strings.Repeat("x", int(args[0].(*object.Integer).Value)))
+	}})
+	env.Define("chunks", &object.Builtin{Name: "chunks", Fn: func(args ...object.Object) object.Object {
+		var elements []object.Object
+		for i := int64(0); i < args[0].(*object.Integer).Value; i++ {
+			elements = append(elements, object.NewString(strings.Repeat("x", 1000)))
+		}
+		return object.NewArray(elements)
+	}})
+
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{input: "len(repeat(1000))", want: 1000},
+		{input: "len(repeat(2000000))", want: "memory limit exceeded"},
+		{input: "len(chunks(100))", want: 100},
+		{input: "len(chunks(2000))", want: "memory limit exceeded"},
+		{
+			// push returns its argument, which is not charged again
+			input: "let a = chunks(500); let i = 0; while (i < 1000) { push(a, 1); i += 1 }; len(a)",
+			want:  1500,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			program := parse.NewParser(lex.NewLexer(test.input)).ParseProgram()
+			got := EvalWithEnvironment(program, object.NewEnclosedEnvironment(env), WithMemory(NewMemory(1<<20)))
+			switch want := test.want.(type) {
+			case int:
+				testInteger(t, got, int64(want))
+			case string:
+				err, ok := got.(*object.Error)
+				if !ok {
+					t.Fatalf("not Error: %+v", got)
+				}
+				if !strings.HasPrefix(err.Message, want) {
+					t.Fatalf("error message wrong. want prefix %q, got=%q", want, err.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestEvalMemoryUsage(t *testing.T) {
+	env := object.NewEnvironment()
+	memory := NewMemory(0)
+	evalInput := func(input string) {
+		program := parse.NewParser(lex.NewLexer(input)).ParseProgram()
+		if result := EvalWithEnvironment(program, env, WithMemory(memory)); isError(result) {
+			t.Fatalf("unexpected error: %s", result.Inspect())
+		}
+	}
+
+	evalInput(`let s = "` + strings.Repeat("x", 10000) + `"; let f = fn() { s }`)
+	if memory.Current() < 10000 {
+		t.Fatalf("current usage too small: %d", memory.Current())
+	}
+
+	evalInput(`s = ""`)
+	if memory.Current() >= 10000 {
+		t.Fatalf("current usage too large after releasing string: %d", memory.Current())
+	}
+	if memory.Peak() < 10000 {
+		t.Fatalf("peak usage too small: %d", memory.Peak())
+	}
+}
+
+type request struct {
+	Path    string
+	Status  int
+	headers map[string]string
+	secret  string
+}
+
+func (r *request) Header(name string) string {
+	return r.headers[name]
+}
+
+func (r *request) Secret() string {
+	return r.secret
+}
+
+func TestEvalHostObject(t *testing.T) {
+	tests := []struct {
+		input      string
+		want       interface{}
+		wantStatus int
+	}{
+		{input: "req.path", want: "/dogs", wantStatus: 200},
+		{input: `req.header("X-Dog")`, want: "pochi", wantStatus: 200},
+		{input: "let h = req.header; h(\"X-Dog\")", want: "pochi", wantStatus: 200},
+		{input: "req.status = 404", want: 404, wantStatus: 404},
+		{input: "req.status += 1; req.status", want: 201, wantStatus: 201},
+		{input: "req.secret()", want: "unknown member secret of host *evaluate.request", wantStatus: 200},
+		{input: "req.Path", want: "unknown member Path of host *evaluate.request", wantStatus: 200},
+		{input: `req.status = "ok"`, want: "cannot convert STRING to Go int", wantStatus: 200},
+		{input: `req.header = 1`, want: "cannot assign to member header of host *evaluate.request", wantStatus: 200},
+		{input: `req.header(1)`, want: "argument 1: cannot convert INTEGER to Go string", wantStatus: 200},
+		{input: `{"a": 1}.a`, want: "member access not supported: HASH", wantStatus: 200},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			req := &request{Path: "/dogs", Status: 200, headers: map[string]string{"X-Dog": "pochi"}, secret: "bone"}
+			host, err := object.NewHostObject(req, map[string]string{"path": "Path", "status": "Status", "header": "Header"})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			env := object.NewEnvironment()
+			env.Set("req", host)
+
+			program := parse.NewParser(lex.NewLexer(test.input)).ParseProgram()
+			got := EvalWithEnvironment(program, env)
+			switch want := test.want.(type) {
+			case int:
+				testInteger(t, got, int64(want))
+			case string:
+				if str, ok := got.(*object.String); ok {
+					testString(t, str, want)
+					break
+				}
+				testError(t, got, want)
+			}
+			if req.Status != test.wantStatus {
+				t.Fatalf("status wrong. want=%d, got=%d", test.wantStatus, req.Status)
+			}
+		})
+	}
+}
+
 func testInteger(t *testing.T, got object.Object, want int64) {
 	t.Helper()
 
@@ -117,6 +969,32 @@ func testBoolean(t *testing.T, got object.Object, want bool) {
 	}
 }
 
+func testString(t *testing.T, got object.Object, want string) {
+	t.Helper()
+
+	str, ok := got.(*object.String)
+	if !ok {
+		t.Fatalf("not String: %+v", got)
+	}
+
+	if str.Value != want {
+		t.Fatalf("string value wrong. want=%q, got=%q", want, str.Value)
+	}
+}
+
+func testError(t *testing.T, got object.Object, want string) {
+	t.Helper()
+
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("not Error: %+v", got)
+	}
+
+	if err.Message != want {
+		t.Fatalf("error message wrong. want=%q, got=%q", want, err.Message)
+	}
+}
+
 func testNull(t *testing.T, got object.Object) {
 	t.Helper()
 
@@ -0,0 +1,112 @@
+package evaluate
+
+import (
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/object"
+)
+
+func (e *evaluator) evalThrowStatement(throw *ast.ThrowStatement, env *object.Environment) object.Object {
+	value := e.evalNode(throw.Expression, env)
+	if isError(value) {
+		return value
+	}
+	return Throw(value)
+}
+
+// Throw returns the error raised by throwing value. Throwing a caught error
+// rethrows it unchanged, and throwing a hash with a "message" (and optionally
+// a "kind") string raises an error with that message and kind.
+func Throw(value object.Object) *object.Error {
+	err := &object.Error{Kind: object.ThrownErrorKind, Message: value.Inspect(), Value: value}
+	switch value := value.(type) {
+	case *object.CaughtError:
+		return value.Error
+	case *object.String:
+		err.Message = value.Value
+	case *object.Hash:
+		if message, ok := value.Get(object.NewString("message")); ok && message.Type() == object.StringType {
+			err.Message = message.Inspect()
+		}
+		if kind, ok := value.Get(object.NewString("kind")); ok && kind.Type() == object.StringType {
+			err.Kind = kind.Inspect()
+		}
+	}
+	return err
+}
+
+// evalTryExpression evaluates the try block and, if it raised an error, the
+// catch block with the error bound to its parameter. The finally block runs
+// afterwards in every case, including when the try or catch block returned
+// or raised an error, but not when the evaluation was canceled or ran
+// out of fuel. A return or error of the finally block takes over the
+// result; otherwise the result of the try or catch block is kept.
+func (e *evaluator) evalTryExpression(try *ast.TryExpression, env *object.Environment) object.Object {
+	result := e.evalNode(try.Block, env)
+	if Uncatchable(result) {
+		return result
+	}
+
+	if err, ok := result.(*object.Error); ok && try.Catch != nil {
+		catchEnv := object.NewLocalEnvironment(env, try.CatchSlots)
+		bind(catchEnv, try.CatchParameter, &object.CaughtError{Error: err})
+		e.pushScope(catchEnv)
+		result = e.evalNode(try.Catch, catchEnv)
+		e.popScope()
+		if Uncatchable(result) {
+			return result
+		}
+	}
+
+	if try.Finally != nil {
+		finally := e.evalNode(try.Finally, env)
+		switch finally.(type) {
+		case *object.ReturnValue, *object.Error:
+			return finally
+		}
+	}
+
+	return result
+}
+
+// Uncatchable reports whether obj is an error raised by cancellation of the
+// evaluation or exhaustion of its fuel. It is not caught, and no finally block
+// runs for it, so that the script stops as soon as possible.
+func Uncatchable(obj object.Object) bool {
+	err, ok := obj.(*object.Error)
+	if !ok || err.Value != nil {
+		return false
+	}
+	return err.Kind == object.CanceledErrorKind || err.Kind == object.OutOfFuelErrorKind
+}
+
+// evalErrorIndexExpression gives scripts access to the fields of a caught error.
+func evalErrorIndexExpression(err *object.Error, index object.Object) object.Object {
+	key, ok := index.(*object.String)
+	if !ok {
+		return object.NewError("error index must be STRING, got %s", index.Type())
+	}
+
+	switch key.Value {
+	case "kind":
+		return object.NewString(err.Kind)
+	case "message":
+		return object.NewString(err.Message)
+	case "line":
+		return object.NewInteger(int64(err.Position.Line))
+	case "column":
+		return object.NewInteger(int64(err.Position.Column))
+	case "value":
+		if err.Value == nil {
+			return NULL
+		}
+		return err.Value
+	case "stack":
+		elements := make([]object.Object, 0, len(err.Stack))
+		for _, f := range err.Stack {
+			elements = append(elements, object.NewString(f.String()))
+		}
+		return object.NewArray(elements)
+	default:
+		return object.NewError("unknown error field: %s", key.Value)
+	}
+}
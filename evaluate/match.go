@@ -0,0 +1,56 @@
+package evaluate
+
+import (
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/object"
+)
+
+// evalMatchExpression evaluates the body of the first arm whose pattern matches
+// the subject and whose guard, if any, is Truthy. The names bound by the
+// pattern live in a scope enclosed by env. The body is in tail position if the
+// match expression itself is.
+func (e *evaluator) evalMatchExpression(match *ast.MatchExpression, env *object.Environment, tail bool) object.Object {
+	subject := e.evalNode(match.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range match.Arms {
+		armEnv := object.NewLocalEnvironment(env, arm.Slots)
+		e.pushScope(armEnv)
+		result, matched := e.evalMatchArm(arm, subject, armEnv, tail)
+		e.popScope()
+		if matched {
+			return result
+		}
+	}
+
+	return object.NewError("no match arm matched value: %s", subject.Inspect())
+}
+
+// evalMatchArm evaluates the body of arm in env if arm matches subject. It
+// reports whether arm matched, or raised an error trying.
+func (e *evaluator) evalMatchArm(arm ast.MatchArm, subject object.Object, env *object.Environment, tail bool) (object.Object, bool) {
+	mismatch, err := e.destructure(arm.Pattern, subject, env, func(ident *ast.Identifier, value object.Object) *object.Error {
+		bind(env, ident, value)
+		return nil
+	})
+	if err != nil {
+		return err, true
+	}
+	if mismatch != "" {
+		return nil, false
+	}
+
+	if arm.Guard != nil {
+		guard := e.evalNode(arm.Guard, env)
+		if isError(guard) {
+			return guard, true
+		}
+		if !Truthy(guard) {
+			return nil, false
+		}
+	}
+
+	return e.evalBranch(arm.Body, env, tail), true
+}
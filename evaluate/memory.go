@@ -0,0 +1,135 @@
+package evaluate
+
+import (
+	"github.com/maiyama18/dog/object"
+)
+
+// minCollection is the usage below which Memory does not look for garbage.
+const minCollection = 64 << 10
+
+// Memory accounts for the approximate size in bytes of the strings, arrays,
+// hashes and closures created by scripts, and enforces a limit on it. A
+// Memory is meant to be shared by the evaluations in one environment, so
+// that the bindings kept across them stay accounted for.
+//
+// Creating an object adds its size to the current usage, as does a builtin
+// or host function returning a value it did not receive. When the usage
+// doubles since the last collection, or would exceed the limit, it is
+// recomputed as the size of the objects still reachable from the environments
+// of the evaluation and from the intermediate values of the expressions under
+// evaluation, dropping the garbage.
+type Memory struct {
+	limit   int64
+	current int64
+	peak    int64
+	next    int64 // usage at which the next collection is due
+}
+
+// NewMemory returns a Memory limited to limit bytes. A non-positive limit
+// only accounts for the usage without limiting it.
+func NewMemory(limit int64) *Memory {
+	return &Memory{limit: limit, next: minCollection}
+}
+
+// Current returns the usage in bytes.
+func (m *Memory) Current() int64 {
+	return m.current
+}
+
+// Peak returns the highest usage in bytes seen so far.
+func (m *Memory) Peak() int64 {
+	return m.peak
+}
+
+// collect recomputes the usage as the size of the objects reachable from envs and objs.
+func (m *Memory) collect(envs []*object.Environment, objs ...object.Object) {
+	m.current = object.ReachableSize(envs, objs...)
+	if m.current > m.peak {
+		m.peak = m.current
+	}
+	m.next = 2 * m.current
+	if m.next < minCollection {
+		m.next = minCollection
+	}
+}
+
+// WithMemory accounts for the memory used by the evaluation in m. Exceeding
+// its limit raises a "memory limit exceeded" error.
+func WithMemory(m *Memory) Option {
+	return func(e *evaluator) {
+		e.memory = m
+	}
+}
+
+// Allocate adds size bytes to the memory usage of the evaluation, returning
+// an error instead if that would exceed the limit.
+func (e *evaluator) Allocate(size int64) *object.Error {
+	m := e.memory
+	if m == nil {
+		return nil
+	}
+
+	exceeds := m.limit > 0 && m.current+size > m.limit
+	if exceeds || m.current+size > m.next {
+		m.collect(e.scopes, e.temps...)
+		exceeds = m.limit > 0 && m.current+size > m.limit
+	}
+	if exceeds {
+		return object.NewError("memory limit exceeded: allocating %d bytes would use %d bytes, over the limit of %d", size, m.current+size, m.limit)
+	}
+
+	m.current += size
+	if m.current > m.peak {
+		m.peak = m.current
+	}
+	return nil
+}
+
+// charge accounts for obj, just created by the script, in the fuel and the
+// memory of the evaluation.
+func (e *evaluator) charge(obj object.Object) *object.Error {
+	switch obj := obj.(type) {
+	case *object.String:
+		if err := e.Consume(int64(len(obj.Value))); err != nil {
+			return err
+		}
+	case *object.Array:
+		if err := e.Consume(int64(len(obj.Elements))); err != nil {
+			return err
+		}
+	case *object.Hash:
+		if err := e.Consume(int64(obj.Len())); err != nil {
+			return err
+		}
+	case *object.Function:
+	default:
+		return nil
+	}
+	return e.Allocate(object.Size(obj))
+}
+
+// chargeResult accounts for the memory of result, returned by a builtin or a
+// host function called with args, which may have created it along with the
+// values it holds. A result passed as an argument, as the array returned by
+// push, is not new.
+func (e *evaluator) chargeResult(result object.Object, args []object.Object) *object.Error {
+	switch result.(type) {
+	case *object.String, *object.Array, *object.Hash:
+	default:
+		return nil
+	}
+	for _, arg := range args {
+		if arg == result {
+			return nil
+		}
+	}
+	return e.Allocate(object.ReachableSize(nil, result))
+}
+
+func (e *evaluator) pushScope(env *object.Environment) {
+	e.scopes = append(e.scopes, env)
+}
+
+func (e *evaluator) popScope() {
+	e.scopes = e.scopes[:len(e.scopes)-1]
+}
@@ -0,0 +1,116 @@
+package evaluate
+
+import (
+	"fmt"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/object"
+)
+
+// bindFunc binds a name of a pattern to the part of the value it matched.
+type bindFunc func(ident *ast.Identifier, value object.Object) *object.Error
+
+// destructure matches value against pattern, calling bind for every name of
+// the pattern. If value does not have the shape of pattern, it returns a
+// description of the mismatch. Names bound before a mismatch is found stay
+// bound, so callers should bind into a scope they can discard.
+func (e *evaluator) destructure(pattern ast.Pattern, value object.Object, env *object.Environment, bind bindFunc) (string, *object.Error) {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return "", nil
+	case *ast.Identifier:
+		return "", bind(pattern, value)
+	case *ast.LiteralPattern:
+		literal := e.evalNode(pattern.Value, env)
+		if err, ok := literal.(*object.Error); ok {
+			return "", err
+		}
+		if !objectsEqual(literal, value) {
+			return fmt.Sprintf("expected %s, got %s", literal.Inspect(), value.Inspect()), nil
+		}
+		return "", nil
+	case *ast.ArrayPattern:
+		return e.destructureArray(pattern, value, env, bind)
+	case *ast.HashPattern:
+		return e.destructureHash(pattern, value, env, bind)
+	default:
+		return "", object.NewError("unknown pattern: %s", pattern.String())
+	}
+}
+
+func (e *evaluator) destructureArray(pattern *ast.ArrayPattern, value object.Object, env *object.Environment, bind bindFunc) (string, *object.Error) {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return fmt.Sprintf("array pattern %s cannot match %s", pattern.String(), value.Type()), nil
+	}
+
+	if pattern.Rest == nil && len(array.Elements) != len(pattern.Elements) {
+		return fmt.Sprintf("array pattern %s expects %d elements, got %d", pattern.String(), len(pattern.Elements), len(array.Elements)), nil
+	}
+	if len(array.Elements) < len(pattern.Elements) {
+		return fmt.Sprintf("array pattern %s expects at least %d elements, got %d", pattern.String(), len(pattern.Elements), len(array.Elements)), nil
+	}
+
+	for i, elem := range pattern.Elements {
+		mismatch, err := e.destructure(elem, array.Elements[i], env, bind)
+		if mismatch != "" || err != nil {
+			return mismatch, err
+		}
+	}
+
+	if pattern.Rest != nil {
+		rest := make([]object.Object, len(array.Elements)-len(pattern.Elements))
+		copy(rest, array.Elements[len(pattern.Elements):])
+		if err := bind(pattern.Rest, object.NewArray(rest)); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+func (e *evaluator) destructureHash(pattern *ast.HashPattern, value object.Object, env *object.Environment, bind bindFunc) (string, *object.Error) {
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return fmt.Sprintf("hash pattern %s cannot match %s", pattern.String(), value.Type()), nil
+	}
+
+	for _, pair := range pattern.Pairs {
+		key := e.evalNode(pair.Key, env)
+		if err, ok := key.(*object.Error); ok {
+			return "", err
+		}
+		hashableKey, ok := key.(object.Hashable)
+		if !ok {
+			return "", object.NewError("unusable as hash key: %s", key.Type())
+		}
+
+		v, ok := hash.Get(hashableKey)
+		if !ok {
+			return fmt.Sprintf("hash pattern %s expects key %s", pattern.String(), pair.Key.String()), nil
+		}
+
+		mismatch, err := e.destructure(pair.Value, v, env, bind)
+		if mismatch != "" || err != nil {
+			return mismatch, err
+		}
+	}
+	return "", nil
+}
+
+// objectsEqual reports whether left and right are integers, strings or
+// booleans of the same value.
+func objectsEqual(left, right object.Object) bool {
+	if left.Type() != right.Type() {
+		return false
+	}
+	switch left := left.(type) {
+	case *object.Integer:
+		return left.Value == right.(*object.Integer).Value
+	case *object.String:
+		return left.Value == right.(*object.String).Value
+	case *object.Boolean:
+		return left.Value == right.(*object.Boolean).Value
+	default:
+		return left == right
+	}
+}
@@ -0,0 +1,100 @@
+package evaluate
+
+import (
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/object"
+	"github.com/maiyama18/dog/token"
+)
+
+// tailCall is returned instead of the result of a call in tail position, for
+// callFunction to make the call without growing the Go stack. It never
+// escapes callFunction.
+type tailCall struct {
+	function *object.Function
+	args     []object.Object
+	position token.Position
+}
+
+func (t *tailCall) Type() object.Type { return "TAIL_CALL" }
+func (t *tailCall) Inspect() string   { return "tail call of " + t.function.Inspect() }
+
+// evalTail evaluates node in tail position of a function body: the last
+// statement of the body, the returned expression of that statement, and
+// recursively the branches of if and match expressions found there. Calls of
+// dog functions in tail position are not made but returned as *tailCall.
+func (e *evaluator) evalTail(node ast.Node, env *object.Environment) object.Object {
+	var result object.Object
+	if err := e.Consume(1); err != nil {
+		result = err
+	} else {
+		result = e.dispatchTail(node, env)
+	}
+	if err, ok := result.(*object.Error); ok {
+		if err.Position == (token.Position{}) {
+			err.Position = node.Pos()
+		}
+		if err.Stack == nil && len(e.frames) > 0 {
+			err.Stack = append([]object.Frame(nil), e.frames...)
+		}
+	}
+	return result
+}
+
+func (e *evaluator) dispatchTail(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		var result object.Object = NULL
+		for i, s := range node.Statements {
+			if i == len(node.Statements)-1 {
+				return e.evalTail(s, env)
+			}
+			result = e.evalNode(s, env)
+			switch result.(type) {
+			case *object.ReturnValue, *object.Error:
+				return result
+			}
+		}
+		return result
+	case *ast.ExpressionStatement:
+		return e.evalTail(node.Expression, env)
+	case *ast.ReturnStatement:
+		value := e.evalTail(node.Expression, env)
+		switch value.(type) {
+		case *object.Error, *tailCall:
+			return value
+		}
+		return &object.ReturnValue{Value: value}
+	case *ast.IfExpression:
+		return e.evalIfExpression(node, env, true)
+	case *ast.MatchExpression:
+		return e.evalMatchExpression(node, env, true)
+	case *ast.CallExpression:
+		function := e.evalNode(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args, err := e.evalArguments(function, node.Arguments, env)
+		if err != nil {
+			return err
+		}
+
+		dogFunction, ok := function.(*object.Function)
+		if !ok {
+			return e.applyFunction(function, args, node.Pos())
+		}
+		if err := checkArity(dogFunction, len(args)); err != nil {
+			return err
+		}
+		return &tailCall{function: dogFunction, args: args, position: node.Pos()}
+	default:
+		return e.evalNode(node, env)
+	}
+}
+
+// evalBranch evaluates a branch of an if or match expression, in tail position if tail.
+func (e *evaluator) evalBranch(node ast.Node, env *object.Environment, tail bool) object.Object {
+	if tail {
+		return e.evalTail(node, env)
+	}
+	return e.evalNode(node, env)
+}
@@ -0,0 +1,524 @@
+// Package format prints dog programs as canonical source, as `dog fmt` does.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/lex"
+	"github.com/maiyama18/dog/parse"
+	"github.com/maiyama18/dog/token"
+)
+
+const (
+	indentation = "  "
+	maxWidth    = 80 // width beyond which calls and literals are wrapped
+)
+
+// primary is the precedence of expressions which never need parentheses.
+const primary = parse.INDEX + 1
+
+// Source formats the dog program src. Its statements are printed one per
+// line, ending with a semicolon except for while loops, with blocks indented
+// by two spaces and only the parentheses the parser needs. Calls, arrays and
+// hashes too wide for a line are split one element per line. Blank lines
+// between statements are kept, but not repeated.
+//
+// Comments are kept on their own lines, or at the end of the line of a
+// statement, of an item of a list or of an arm of a match, lists with
+// comments between their items being split one item per line. Other comments
+// within an expression are moved after the statement. Formatting the result
+// gives the same result again.
+func Source(src []byte) ([]byte, error) {
+	p := parse.NewParser(lex.NewLexer(string(src)))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	pr := &printer{lines: strings.Split(string(src), "\n"), comments: program.Comments}
+	pr.program(program)
+	return pr.buf.Bytes(), nil
+}
+
+// Node formats a statement, an expression or a pattern as Source does,
+// without comments nor blank lines and without a trailing newline.
+func Node(node ast.Node) string {
+	pr := &printer{}
+	switch node := node.(type) {
+	case *ast.Program:
+		pr.statements(node.Statements, token.Position{})
+	case ast.Statement:
+		pr.statement(node)
+	case ast.Pattern:
+		pr.pattern(node)
+	case ast.Expression:
+		pr.expression(node, parse.LOWEST)
+	}
+	return pr.buf.String()
+}
+
+type printer struct {
+	buf    bytes.Buffer
+	indent int
+	flat   bool // whether lists are printed on one line whatever their width
+
+	lines    []string      // source lines, to find blank lines and trailing comments
+	comments []token.Token // comments of the source
+	next     int           // index of the first comment not printed yet
+}
+
+func (p *printer) write(s string) {
+	p.buf.WriteString(s)
+}
+
+// beginLine starts a line at the current indentation for an item at line
+// line of the source, keeping a blank line preceding it unless it is the
+// first item of its block.
+func (p *printer) beginLine(line int, first bool) {
+	if p.buf.Len() > 0 {
+		p.write("\n")
+		if !first && p.blank(line-1) {
+			p.write("\n")
+		}
+	}
+	p.write(strings.Repeat(indentation, p.indent))
+}
+
+// blank reports whether line of the source is blank.
+func (p *printer) blank(line int) bool {
+	return line >= 1 && line <= len(p.lines) && strings.TrimSpace(p.lines[line-1]) == ""
+}
+
+// pending reports whether a comment not printed yet precedes pos.
+func (p *printer) pending(pos token.Position) bool {
+	return p.next < len(p.comments) && before(p.comments[p.next].Position, pos)
+}
+
+func before(a, b token.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// leadingComments prints the comments preceding pos on their own lines. It
+// returns whether the next item is still the first one of its block.
+func (p *printer) leadingComments(pos token.Position, first bool) bool {
+	for p.pending(pos) {
+		c := p.comments[p.next]
+		p.next++
+		p.beginLine(c.Position.Line, first)
+		p.write(strings.TrimRightFunc(c.Literal, unicode.IsSpace))
+		first = false
+	}
+	return first
+}
+
+// trailingComment prints the next comment at the end of the current line if
+// it precedes pos and follows some code on its line in the source.
+func (p *printer) trailingComment(pos token.Position) {
+	if !p.pending(pos) {
+		return
+	}
+	c := p.comments[p.next]
+	if c.Position.Line > len(p.lines) {
+		return
+	}
+	line := []rune(p.lines[c.Position.Line-1])
+	if c.Position.Column-1 > len(line) || strings.TrimSpace(string(line[:c.Position.Column-1])) == "" {
+		return
+	}
+	p.next++
+	p.write(" " + strings.TrimRightFunc(c.Literal, unicode.IsSpace))
+}
+
+func (p *printer) program(program *ast.Program) {
+	p.statements(program.Statements, token.Position{Line: int(^uint(0) >> 1)})
+	if p.buf.Len() > 0 {
+		p.write("\n")
+	}
+}
+
+// statements prints stmts one per line, with the comments preceding end.
+func (p *printer) statements(stmts []ast.Statement, end token.Position) {
+	first := true
+	for i, s := range stmts {
+		first = p.leadingComments(s.Pos(), first)
+		p.beginLine(s.Pos().Line, first)
+		first = false
+		p.statement(s)
+
+		next := end
+		if i+1 < len(stmts) {
+			next = stmts[i+1].Pos()
+		}
+		p.trailingComment(next)
+	}
+	p.leadingComments(end, first)
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		if stmt.Constant() {
+			p.write("const ")
+		} else {
+			p.write("let ")
+		}
+		p.pattern(stmt.Pattern)
+		p.write(" = ")
+		p.expression(stmt.Expression, parse.LOWEST)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.keywordStatement("return", stmt.Expression)
+	case *ast.ThrowStatement:
+		p.keywordStatement("throw", stmt.Expression)
+	case *ast.WhileStatement:
+		p.write("while (")
+		p.expression(stmt.Condition, parse.LOWEST)
+		p.write(") ")
+		p.block(stmt.Body)
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, parse.LOWEST)
+		p.write(";")
+	case *ast.BlockStatement:
+		p.block(stmt)
+	}
+}
+
+func (p *printer) keywordStatement(keyword string, exp ast.Expression) {
+	p.write(keyword)
+	if exp != nil {
+		p.write(" ")
+		p.expression(exp, parse.LOWEST)
+	}
+	p.write(";")
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 && !p.pending(block.End) {
+		p.write("{}")
+		return
+	}
+	p.write("{")
+	p.indent++
+	p.statements(block.Statements, block.End)
+	p.indent--
+	p.beginLine(block.End.Line, true)
+	p.write("}")
+}
+
+// precedence returns the precedence of the operator applied last by exp, or
+// primary if exp starts and ends with its own delimiters.
+func precedence(exp ast.Expression) parse.Precedence {
+	switch exp := exp.(type) {
+	case *ast.AssignExpression:
+		return parse.ASSIGN
+	case *ast.InfixExpression:
+		return parse.OperatorPrecedence(exp.Operator)
+	case *ast.PrefixExpression:
+		return parse.PREFIX
+	case *ast.IntegerLiteral:
+		if exp.Value < 0 {
+			// produced by the optimizer, printed with a minus sign
+			return parse.PREFIX
+		}
+	case *ast.CallExpression:
+		return parse.CALL
+	case *ast.IndexExpression, *ast.MemberExpression:
+		return parse.INDEX
+	}
+	return primary
+}
+
+// expression prints exp, in parentheses if its precedence is less than min.
+func (p *printer) expression(exp ast.Expression, min parse.Precedence) {
+	if precedence(exp) < min {
+		p.write("(")
+		defer p.write(")")
+	}
+
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.write(exp.Name)
+	case *ast.IntegerLiteral:
+		p.write(strconv.FormatInt(exp.Value, 10))
+	case *ast.BooleanLiteral:
+		p.write(strconv.FormatBool(exp.Value))
+	case *ast.StringLiteral:
+		p.write(`"` + exp.Value + `"`)
+	case *ast.PrefixExpression:
+		p.write(exp.Operator)
+		p.expression(exp.Right, parse.PREFIX)
+	case *ast.InfixExpression:
+		// infix operators are left associative
+		prec := parse.OperatorPrecedence(exp.Operator)
+		p.expression(exp.Left, prec)
+		p.write(" " + exp.Operator + " ")
+		p.expression(exp.Right, prec+1)
+	case *ast.AssignExpression:
+		// assignments are right associative, their targets never need parentheses
+		p.expression(exp.Target, parse.LOWEST)
+		p.write(" " + exp.Operator + " ")
+		p.expression(exp.Value, parse.ASSIGN)
+	case *ast.CallExpression:
+		p.expression(exp.Function, parse.CALL)
+		p.list("(", ")", exp.Token.Position, exp.End, len(exp.Arguments), func(i int) token.Position {
+			return exp.Arguments[i].Pos()
+		}, func(i int) {
+			p.expression(exp.Arguments[i], parse.LOWEST)
+		})
+	case *ast.SpreadExpression:
+		p.write("...")
+		p.expression(exp.Value, parse.LOWEST)
+	case *ast.NamedArgument:
+		p.write(exp.Name + ": ")
+		p.expression(exp.Value, parse.LOWEST)
+	case *ast.IndexExpression:
+		p.expression(exp.Left, parse.CALL)
+		p.write("[")
+		p.expression(exp.Index, parse.LOWEST)
+		p.write("]")
+	case *ast.MemberExpression:
+		p.expression(exp.Object, parse.CALL)
+		p.write("." + exp.Member.Name)
+	case *ast.ArrayLiteral:
+		p.list("[", "]", exp.Token.Position, exp.End, len(exp.Elements), func(i int) token.Position {
+			return exp.Elements[i].Pos()
+		}, func(i int) {
+			p.expression(exp.Elements[i], parse.LOWEST)
+		})
+	case *ast.HashLiteral:
+		p.list("{", "}", exp.Token.Position, exp.End, len(exp.Pairs), func(i int) token.Position {
+			return exp.Pairs[i].Key.Pos()
+		}, func(i int) {
+			p.expression(exp.Pairs[i].Key, parse.LOWEST)
+			p.write(": ")
+			p.expression(exp.Pairs[i].Value, parse.LOWEST)
+		})
+	case *ast.FunctionLiteral:
+		p.write("fn(")
+		for i, param := range exp.Parameters {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.pattern(param.Pattern)
+			if param.Default != nil {
+				p.write(" = ")
+				p.expression(param.Default, parse.LOWEST)
+			}
+		}
+		if exp.Rest != nil {
+			if len(exp.Parameters) > 0 {
+				p.write(", ")
+			}
+			p.write("..." + exp.Rest.Name)
+		}
+		p.write(") ")
+		p.block(exp.Body)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(exp.Condition, parse.LOWEST)
+		p.write(") ")
+		p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			p.write(" else ")
+			p.block(exp.Alternative)
+		}
+	case *ast.TryExpression:
+		p.write("try ")
+		p.block(exp.Block)
+		if exp.Catch != nil {
+			p.write(" catch (" + exp.CatchParameter.Name + ") ")
+			p.block(exp.Catch)
+		}
+		if exp.Finally != nil {
+			p.write(" finally ")
+			p.block(exp.Finally)
+		}
+	case *ast.MatchExpression:
+		p.match(exp)
+	default:
+		panic(fmt.Sprintf("format: unexpected expression %T", exp))
+	}
+}
+
+// list prints n items between open and close, at from and to in the source,
+// separated by commas. If they do not fit on the line, or if there are
+// comments between them, they are printed one per line instead, with their
+// comments. pos returns the position of the ith item.
+//
+// Whether they fit is decided on the items printed with all their lists on
+// one line, so that outer lists are wrapped before the lists they contain,
+// and every list is printed flat once per enclosing list rather than once per
+// combination of the decisions of the enclosing lists.
+func (p *printer) list(open, close string, from, to token.Position, n int, pos func(i int) token.Position, item func(i int)) {
+	start, next := p.buf.Len(), p.next
+
+	flat := p.flat
+	p.flat = true
+	commented := p.items(open, close, from, to, n, pos, item)
+	p.flat = flat
+	if flat || n == 0 && !commented {
+		return
+	}
+	if !commented && p.fits(start) {
+		if bytes.IndexByte(p.buf.Bytes()[start:], '\n') < 0 {
+			return
+		}
+		// the lists of the blocks among the items may need wrapping
+		p.buf.Truncate(start)
+		p.next = next
+		p.items(open, close, from, to, n, pos, item)
+		return
+	}
+
+	p.buf.Truncate(start)
+	p.next = next
+	p.write(open)
+	p.indent++
+	for i := 0; i < n; i++ {
+		if p.commentWithin(from, pos(i)) {
+			p.leadingComments(pos(i), true)
+		}
+		p.beginLine(0, true)
+		item(i)
+		if i < n-1 {
+			p.write(",")
+		}
+		end := to
+		if i < n-1 {
+			end = pos(i + 1)
+		}
+		if p.commentWithin(from, end) {
+			p.trailingComment(end)
+		}
+	}
+	if p.commentWithin(from, to) {
+		p.leadingComments(to, true)
+	}
+	p.indent--
+	p.beginLine(0, true)
+	p.write(close)
+}
+
+// items prints n items between open and close on one line, as list does. It
+// returns whether there are comments between them, which it does not print.
+func (p *printer) items(open, close string, from, to token.Position, n int, pos func(i int) token.Position, item func(i int)) bool {
+	commented := false
+	p.write(open)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			p.write(", ")
+		}
+		commented = commented || p.commentWithin(from, pos(i))
+		item(i)
+	}
+	p.write(close)
+	return commented || p.commentWithin(from, to)
+}
+
+// commentWithin reports whether the next comment not printed yet follows
+// from and precedes to, so that it belongs between them rather than to the
+// code preceding from.
+func (p *printer) commentWithin(from, to token.Position) bool {
+	return p.pending(to) && !before(p.comments[p.next].Position, from)
+}
+
+// fits reports whether the first and the last line of the text printed
+// since offset start are at most maxWidth wide.
+func (p *printer) fits(start int) bool {
+	out := p.buf.Bytes()
+	lineStart := bytes.LastIndexByte(out[:start], '\n') + 1
+	text := out[lineStart:]
+	firstEnd := bytes.IndexByte(text, '\n')
+	if firstEnd < 0 {
+		return utf8.RuneCount(text) <= maxWidth
+	}
+	lastStart := bytes.LastIndexByte(text, '\n') + 1
+	return utf8.RuneCount(text[:firstEnd]) <= maxWidth && utf8.RuneCount(text[lastStart:]) <= maxWidth
+}
+
+func (p *printer) match(exp *ast.MatchExpression) {
+	p.write("match (")
+	p.expression(exp.Subject, parse.LOWEST)
+	p.write(") {")
+	if len(exp.Arms) == 0 && !p.pending(exp.End) {
+		p.write("}")
+		return
+	}
+
+	p.indent++
+	first := true
+	for i, arm := range exp.Arms {
+		first = p.leadingComments(arm.Pattern.Pos(), first)
+		p.beginLine(arm.Pattern.Pos().Line, first)
+		first = false
+
+		p.pattern(arm.Pattern)
+		if arm.Guard != nil {
+			p.write(" if ")
+			p.expression(arm.Guard, parse.LOWEST)
+		}
+		p.write(" => ")
+		p.expression(arm.Body, parse.LOWEST)
+		if i < len(exp.Arms)-1 {
+			p.write(",")
+			p.trailingComment(exp.Arms[i+1].Pattern.Pos())
+		} else {
+			p.trailingComment(exp.End)
+		}
+	}
+	p.leadingComments(exp.End, first)
+	p.indent--
+	p.beginLine(0, true)
+	p.write("}")
+}
+
+func (p *printer) pattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		p.write(pattern.Name)
+	case *ast.WildcardPattern:
+		p.write("_")
+	case *ast.LiteralPattern:
+		p.expression(pattern.Value, parse.LOWEST)
+	case *ast.ArrayPattern:
+		p.write("[")
+		for i, e := range pattern.Elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.pattern(e)
+		}
+		if pattern.Rest != nil {
+			if len(pattern.Elements) > 0 {
+				p.write(", ")
+			}
+			p.write(".." + pattern.Rest.Name)
+		}
+		p.write("]")
+	case *ast.HashPattern:
+		p.write("{")
+		for i, pair := range pattern.Pairs {
+			if i > 0 {
+				p.write(", ")
+			}
+			// shorthand `{name}` for `{"name": name}`
+			key, ok := pair.Key.(*ast.StringLiteral)
+			if ident, isIdent := pair.Value.(*ast.Identifier); ok && isIdent && key.Value == ident.Name {
+				p.write(ident.Name)
+				continue
+			}
+			p.expression(pair.Key, parse.LOWEST)
+			p.write(": ")
+			p.pattern(pair.Value)
+		}
+		p.write("}")
+	default:
+		panic(fmt.Sprintf("format: unexpected pattern %T", pattern))
+	}
+}
@@ -0,0 +1,248 @@
+package format
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/lex"
+	"github.com/maiyama18/dog/parse"
+	"github.com/maiyama18/dog/token"
+)
+
+var sourceTests = []struct {
+	input string
+	want  string
+}{
+	{input: "", want: ""},
+	{input: "let   x=1", want: "let x = 1;\n"},
+	{input: "const x = 1;;", want: "const x = 1;\n"},
+	{input: "a; b", want: "a;\nb;\n"},
+	{input: "(a + b) * c", want: "(a + b) * c;\n"},
+	{input: "a + (b * c)", want: "a + b * c;\n"},
+	{input: "(a - b) - c", want: "a - b - c;\n"},
+	{input: "a - (b - c)", want: "a - (b - c);\n"},
+	{input: "(a < b) == (c > d)", want: "a < b == c > d;\n"},
+	{input: "-(a + b)", want: "-(a + b);\n"},
+	{input: "!(-a)", want: "!-a;\n"},
+	{input: "(-a)[0]; -(a[0])", want: "(-a)[0];\n-a[0];\n"},
+	{input: "(f(x))(y); (a.b)[0].c", want: "f(x)(y);\na.b[0].c;\n"},
+	{input: "(a + b).c", want: "(a + b).c;\n"},
+	{input: "a = (b = c)", want: "a = b = c;\n"},
+	{input: "(a = b) + 1", want: "(a = b) + 1;\n"},
+	{input: "x += (1 + 2)", want: "x += 1 + 2;\n"},
+	{input: "f(...(xs))", want: "f(...xs);\n"},
+	{input: `{"a": [1,2], 3: {}}`, want: "{\"a\": [1, 2], 3: {}};\n"},
+	{
+		input: "let f = fn(a, b = 1, ...c) { return a }",
+		want:  "let f = fn(a, b = 1, ...c) {\n  return a;\n};\n",
+	},
+	{input: "fn(...xs) {}", want: "fn(...xs) {};\n"},
+	{input: "f(1, ...xs, b:2, c : x+1)", want: "f(1, ...xs, b: 2, c: x + 1);\n"},
+	{
+		input: "if (a) { b } else { if (c) { d } }",
+		want:  "if (a) {\n  b;\n} else {\n  if (c) {\n    d;\n  };\n};\n",
+	},
+	{
+		input: "while (i < 3) { i += 1 }; i",
+		want:  "while (i < 3) {\n  i += 1;\n}\ni;\n",
+	},
+	{
+		input: "try { f() } catch (e) { throw e } finally {}",
+		want:  "try {\n  f();\n} catch (e) {\n  throw e;\n} finally {};\n",
+	},
+	{
+		input: `match (x) { 0 => "zero", -1 => "minus", [h, ..t] if h > 0 => h, {"k": _, name} => name, _ => x }`,
+		want:  "match (x) {\n  0 => \"zero\",\n  -1 => \"minus\",\n  [h, ..t] if h > 0 => h,\n  {\"k\": _, name} => name,\n  _ => x\n};\n",
+	},
+	{
+		input: "let [a, [b], ..rest] = xs",
+		want:  "let [a, [b], ..rest] = xs;\n",
+	},
+	{
+		input: "let result = someFunction(firstArgument, secondArgument, thirdArgument, fourthArgument)",
+		want:  "let result = someFunction(\n  firstArgument,\n  secondArgument,\n  thirdArgument,\n  fourthArgument\n);\n",
+	},
+	{
+		input: "let xs = [first(aaaaaaaaaa, bbbbbbbbbb), second(cccccccccc, dddddddddd), eeeeeeeeee]",
+		want:  "let xs = [\n  first(aaaaaaaaaa, bbbbbbbbbb),\n  second(cccccccccc, dddddddddd),\n  eeeeeeeeee\n];\n",
+	},
+	{
+		// outer lists are wrapped first
+		input: "let result = outerFunction(innerFunction(firstArgument, secondArgument), thirdArgument, fourth)",
+		want:  "let result = outerFunction(\n  innerFunction(firstArgument, secondArgument),\n  thirdArgument,\n  fourth\n);\n",
+	},
+	{
+		input: "let f = fn() { g(fn() { let result = someFunction(firstArgument, secondArgument, thirdArgument, fourth) }) }",
+		want:  "let f = fn() {\n  g(fn() {\n    let result = someFunction(\n      firstArgument,\n      secondArgument,\n      thirdArgument,\n      fourth\n    );\n  });\n};\n",
+	},
+	{
+		input: "map(xs, fn(x) { x * 2 })",
+		want:  "map(xs, fn(x) {\n  x * 2;\n});\n",
+	},
+	{
+		input: "// leading\n\n\n// second\nlet x = 1; // trailing\n\n\nx\n// last\n",
+		want:  "// leading\n\n// second\nlet x = 1; // trailing\n\nx;\n// last\n",
+	},
+	{
+		input: "let f = fn() { // opening\n  a; // a\n\n  // before b\n  b\n  // closing\n}",
+		want:  "let f = fn() {\n  // opening\n  a; // a\n\n  // before b\n  b;\n  // closing\n};\n",
+	},
+	{
+		input: "if (a) {\n  // nothing\n}",
+		want:  "if (a) {\n  // nothing\n};\n",
+	},
+	{
+		input: "f(a, // first\n  b,\n  // second\n  c)\nd",
+		want:  "f(\n  a, // first\n  b,\n  // second\n  c\n);\nd;\n",
+	},
+	{
+		input: "let h = {\n \"a\": 1, // c\n \"b\": 2\n}",
+		want:  "let h = {\n  \"a\": 1, // c\n  \"b\": 2\n};\n",
+	},
+	{
+		input: "let xs = [1, [2, // two\n 3], 4 // four\n  // closing\n]",
+		want:  "let xs = [\n  1,\n  [\n    2, // two\n    3\n  ],\n  4 // four\n  // closing\n];\n",
+	},
+	{
+		// comments preceding a list stay out of it
+		input: "let xs = // xs\n  [1, 2]",
+		want:  "let xs = [1, 2]; // xs\n",
+	},
+	{
+		input: "f(fn() {\n  // body\n  x\n}, y // y\n)",
+		want:  "f(\n  fn() {\n    // body\n    x;\n  },\n  y // y\n);\n",
+	},
+	{
+		input: "f(fn() {\n  // body\n  x\n})",
+		want:  "f(fn() {\n  // body\n  x;\n});\n",
+	},
+	{
+		input: "match (x) {\n  // zero\n  0 => a, // a\n  _ => b\n}",
+		want:  "match (x) {\n  // zero\n  0 => a, // a\n  _ => b\n};\n",
+	},
+	{
+		input: "match (x) {\n  0 => a,\n  _ => b // b\n  // closing\n}",
+		want:  "match (x) {\n  0 => a,\n  _ => b // b\n  // closing\n};\n",
+	},
+	{
+		input: "match (x) {\n  // nothing\n}",
+		want:  "match (x) {\n  // nothing\n};\n",
+	},
+}
+
+func TestSource(t *testing.T) {
+	for _, tt := range sourceTests {
+		got, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Source(%q) wrong.\nwant:\n%s\ngot:\n%s", tt.input, tt.want, got)
+		}
+	}
+}
+
+// TestSourceIdempotent checks that formatting keeps the meaning of programs,
+// as printed by String, and gives the same result when repeated.
+func TestSourceIdempotent(t *testing.T) {
+	type input struct{ name, src string }
+	var inputs []input
+	for i, tt := range sourceTests {
+		inputs = append(inputs, input{name: fmt.Sprintf("sourceTests[%d]", i), src: tt.input})
+	}
+	paths, err := filepath.Glob(filepath.Join("..", "conformance", "testdata", "*.dog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, input{name: path, src: string(src)})
+	}
+
+	for _, in := range inputs {
+		name := in.name
+		once, err := Source([]byte(in.src))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		twice, err := Source(once)
+		if err != nil {
+			t.Errorf("%s: formatted source does not parse: %v\n%s", name, err, once)
+			continue
+		}
+		if string(twice) != string(once) {
+			t.Errorf("%s: formatting is not idempotent.\nonce:\n%s\ntwice:\n%s", name, once, twice)
+		}
+		if got, want := parseProgram(t, string(once)).String(), parseProgram(t, in.src).String(); got != want {
+			t.Errorf("%s: formatting changed the program.\nwant: %s\ngot:  %s", name, want, got)
+		}
+	}
+}
+
+// TestSourceNested checks that deeply nested lists are formatted in time
+// linear in their depth rather than exponential.
+func TestSourceNested(t *testing.T) {
+	const depth = 40
+	src := strings.Repeat("f(", depth) + "firstArgument, secondArgument" + strings.Repeat(")", depth)
+	got, err := Source([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "f(\n  f(\n    f(\n"
+	if !strings.HasPrefix(string(got), want) {
+		t.Errorf("Source wrong. want prefix %q, got:\n%s", want, got)
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	if _, err := Source([]byte("let = 1")); err == nil {
+		t.Errorf("Source returned no error for a syntax error")
+	}
+}
+
+func TestNode(t *testing.T) {
+	minus := &ast.InfixExpression{
+		Token:    token.Token{Type: token.MINUS, Literal: "-"},
+		Operator: "-",
+		Left:     &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "-2"}, Value: -2},
+		Right: &ast.InfixExpression{
+			Operator: "*",
+			Left:     &ast.Identifier{Name: "a"},
+			Right:    &ast.Identifier{Name: "b"},
+		},
+	}
+	tests := []struct {
+		node ast.Node
+		want string
+	}{
+		{node: minus, want: "-2 - a * b"},
+		{node: &ast.IndexExpression{Left: minus.Left, Index: minus.Right}, want: "(-2)[a * b]"},
+		{node: &ast.ExpressionStatement{Expression: minus}, want: "-2 - a * b;"},
+		{node: parseProgram(t, "let {name} = x; if (name) { 1 }"), want: "let {name} = x;\nif (name) {\n  1;\n};"},
+	}
+
+	for _, tt := range tests {
+		if got := Node(tt.node); got != tt.want {
+			t.Errorf("Node(%s) wrong. want=%q, got=%q", tt.node.String(), tt.want, got)
+		}
+	}
+}
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parse.NewParser(lex.NewLexer(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse error in %q: %s", input, strings.TrimSpace(errs[0].Error()))
+	}
+	return program
+}
@@ -11,10 +11,16 @@ type Lexer struct {
 	position     int
 	nextPosition int
 	currentRune  rune
+
+	// line and column of currentRune
+	line   int
+	column int
+
+	comments []token.Token
 }
 
 func NewLexer(input string) *Lexer {
-	l := &Lexer{input: []rune(input)}
+	l := &Lexer{input: []rune(input), line: 1}
 	l.consumeRune()
 	return l
 }
@@ -23,12 +29,16 @@ func (l *Lexer) NextToken() token.Token {
 	var t token.Token
 
 	l.skipSpaces()
+	pos := token.Position{Line: l.line, Column: l.column}
 
 	switch l.currentRune {
 	case '=':
 		if l.peekRune() == '=' {
 			t = token.Token{Type: token.EQ, Literal: "=="}
 			l.consumeRune()
+		} else if l.peekRune() == '>' {
+			t = token.Token{Type: token.ARROW, Literal: "=>"}
+			l.consumeRune()
 		} else {
 			t = newToken(token.ASSIGN, l.currentRune)
 		}
@@ -40,13 +50,15 @@ func (l *Lexer) NextToken() token.Token {
 			t = newToken(token.BANG, l.currentRune)
 		}
 	case '+':
-		t = newToken(token.PLUS, l.currentRune)
+		t = l.readOperator(token.PLUS, token.PLUSASSIGN)
 	case '-':
-		t = newToken(token.MINUS, l.currentRune)
+		t = l.readOperator(token.MINUS, token.MINUSASSIGN)
 	case '*':
-		t = newToken(token.ASTERISK, l.currentRune)
+		t = l.readOperator(token.ASTERISK, token.ASTERISKASSIGN)
 	case '/':
-		t = newToken(token.SLASH, l.currentRune)
+		t = l.readOperator(token.SLASH, token.SLASHASSIGN)
+	case '%':
+		t = l.readOperator(token.PERCENT, token.PERCENTASSIGN)
 	case '<':
 		t = newToken(token.LT, l.currentRune)
 	case '>':
@@ -59,10 +71,34 @@ func (l *Lexer) NextToken() token.Token {
 		t = newToken(token.LBRACE, l.currentRune)
 	case '}':
 		t = newToken(token.RBRACE, l.currentRune)
+	case '[':
+		t = newToken(token.LBRACKET, l.currentRune)
+	case ']':
+		t = newToken(token.RBRACKET, l.currentRune)
 	case ',':
 		t = newToken(token.COMMA, l.currentRune)
 	case ';':
 		t = newToken(token.SEMICOLON, l.currentRune)
+	case ':':
+		t = newToken(token.COLON, l.currentRune)
+	case '.':
+		if l.peekRune() == '.' {
+			l.consumeRune()
+			if l.peekRune() == '.' {
+				t = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+				l.consumeRune()
+			} else {
+				t = token.Token{Type: token.DOTDOT, Literal: ".."}
+			}
+		} else {
+			t = newToken(token.DOT, l.currentRune)
+		}
+	case '"':
+		if literal, ok := l.readString(); ok {
+			t = token.Token{Type: token.STRING, Literal: literal}
+		} else {
+			t = token.Token{Type: token.ILLEGAL, Literal: `"` + literal}
+		}
 	case 0:
 		t = newToken(token.EOF, ' ')
 	default:
@@ -73,14 +109,30 @@ func (l *Lexer) NextToken() token.Token {
 		} else if isDigit(l.currentRune) {
 			literal := l.readNumber()
 			t = token.Token{Type: token.INT, Literal: literal}
+		} else {
+			t = newToken(token.ILLEGAL, l.currentRune)
 		}
 	}
 
+	t.Position = pos
 	l.consumeRune()
 	return t
 }
 
+// Comments returns the `//` line comments skipped so far, in source order.
+// Their literal is the whole comment, `//` included.
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
 func (l *Lexer) consumeRune() {
+	if l.currentRune == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+
 	if l.nextPosition >= len(l.input) {
 		l.currentRune = 0
 	} else {
@@ -113,10 +165,53 @@ func (l *Lexer) readNumber() string {
 	return string(l.input[start : l.position+1])
 }
 
+// readOperator reads an arithmetic operator, or its compound assignment form
+// (e.g. "+=") if the operator is immediately followed by '='.
+func (l *Lexer) readOperator(operatorType, assignType token.Type) token.Token {
+	if l.peekRune() == '=' {
+		literal := string(l.currentRune) + "="
+		l.consumeRune()
+		return token.Token{Type: assignType, Literal: literal}
+	}
+	return newToken(operatorType, l.currentRune)
+}
+
+// readString reads the contents of a double-quoted string literal, leaving the
+// closing quote as the current rune. It reports false if the input ends
+// before the closing quote.
+func (l *Lexer) readString() (string, bool) {
+	start := l.position + 1
+	for {
+		l.consumeRune()
+		if l.currentRune == '"' || l.currentRune == 0 {
+			break
+		}
+	}
+	return string(l.input[start:l.position]), l.currentRune == '"'
+}
+
+// skipSpaces skips white space and comments, recording the comments.
 func (l *Lexer) skipSpaces() {
-	for unicode.IsSpace(l.currentRune) {
+	for {
+		for unicode.IsSpace(l.currentRune) {
+			l.consumeRune()
+		}
+		if l.currentRune != '/' || l.peekRune() != '/' {
+			return
+		}
+		l.comments = append(l.comments, l.readComment())
+	}
+}
+
+// readComment reads a line comment up to the end of the line, leaving the
+// newline as the current rune.
+func (l *Lexer) readComment() token.Token {
+	pos := token.Position{Line: l.line, Column: l.column}
+	start := l.position
+	for l.currentRune != '\n' && l.currentRune != 0 {
 		l.consumeRune()
 	}
+	return token.Token{Type: token.COMMENT, Literal: string(l.input[start:l.position]), Position: pos}
 }
 
 func newToken(tokenType token.Type, literal rune) token.Token {
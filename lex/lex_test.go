@@ -25,6 +25,14 @@ if (5 < 10) {
 
 10 == 10;
 10 != 9;
+x += 1 -= 2 *= 3 /= 4 %= 5 % 6;
+"foo bar"
+[1, 2];
+{"a": 1}
+const while
+match (x) { [h, ..t] => h }
+f(...a)
+req.url
 `
 
 	expectedTokens := []token.Token{
@@ -96,6 +104,57 @@ if (5 < 10) {
 		{Type: token.NOTEQ, Literal: "!="},
 		{Type: token.INT, Literal: "9"},
 		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.PLUSASSIGN, Literal: "+="},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.MINUSASSIGN, Literal: "-="},
+		{Type: token.INT, Literal: "2"},
+		{Type: token.ASTERISKASSIGN, Literal: "*="},
+		{Type: token.INT, Literal: "3"},
+		{Type: token.SLASHASSIGN, Literal: "/="},
+		{Type: token.INT, Literal: "4"},
+		{Type: token.PERCENTASSIGN, Literal: "%="},
+		{Type: token.INT, Literal: "5"},
+		{Type: token.PERCENT, Literal: "%"},
+		{Type: token.INT, Literal: "6"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.STRING, Literal: "foo bar"},
+		{Type: token.LBRACKET, Literal: "["},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.COMMA, Literal: ","},
+		{Type: token.INT, Literal: "2"},
+		{Type: token.RBRACKET, Literal: "]"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.LBRACE, Literal: "{"},
+		{Type: token.STRING, Literal: "a"},
+		{Type: token.COLON, Literal: ":"},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.RBRACE, Literal: "}"},
+		{Type: token.CONST, Literal: "const"},
+		{Type: token.WHILE, Literal: "while"},
+		{Type: token.MATCH, Literal: "match"},
+		{Type: token.LPAREN, Literal: "("},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.RPAREN, Literal: ")"},
+		{Type: token.LBRACE, Literal: "{"},
+		{Type: token.LBRACKET, Literal: "["},
+		{Type: token.IDENT, Literal: "h"},
+		{Type: token.COMMA, Literal: ","},
+		{Type: token.DOTDOT, Literal: ".."},
+		{Type: token.IDENT, Literal: "t"},
+		{Type: token.RBRACKET, Literal: "]"},
+		{Type: token.ARROW, Literal: "=>"},
+		{Type: token.IDENT, Literal: "h"},
+		{Type: token.RBRACE, Literal: "}"},
+		{Type: token.IDENT, Literal: "f"},
+		{Type: token.LPAREN, Literal: "("},
+		{Type: token.ELLIPSIS, Literal: "..."},
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.RPAREN, Literal: ")"},
+		{Type: token.IDENT, Literal: "req"},
+		{Type: token.DOT, Literal: "."},
+		{Type: token.IDENT, Literal: "url"},
+		{Type: token.EOF, Literal: " "},
 	}
 
 	l := NewLexer(input)
@@ -108,3 +167,91 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x += "ab";
+`
+
+	expectedPositions := []token.Position{
+		{Line: 1, Column: 1},
+		{Line: 1, Column: 5},
+		{Line: 1, Column: 7},
+		{Line: 1, Column: 9},
+		{Line: 1, Column: 10},
+		{Line: 2, Column: 3},
+		{Line: 2, Column: 5},
+		{Line: 2, Column: 8},
+		{Line: 2, Column: 12},
+	}
+
+	l := NewLexer(input)
+
+	for i, expected := range expectedPositions {
+		actual := l.NextToken()
+
+		if actual.Position != expected {
+			t.Fatalf("[%d] position of %+v wrong. want=%s, got=%s", i, actual, expected, actual.Position)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let x = 10 / 2; // trailing
+  //indented
+x`
+
+	expectedTokens := []token.Token{
+		{Type: token.LET, Literal: "let"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.INT, Literal: "10"},
+		{Type: token.SLASH, Literal: "/"},
+		{Type: token.INT, Literal: "2"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.EOF, Literal: " "},
+	}
+	expectedComments := []token.Token{
+		{Type: token.COMMENT, Literal: "// leading", Position: token.Position{Line: 1, Column: 1}},
+		{Type: token.COMMENT, Literal: "// trailing", Position: token.Position{Line: 2, Column: 17}},
+		{Type: token.COMMENT, Literal: "//indented", Position: token.Position{Line: 3, Column: 3}},
+	}
+
+	l := NewLexer(input)
+
+	for i, expected := range expectedTokens {
+		actual := l.NextToken()
+
+		if actual.Type != expected.Type || actual.Literal != expected.Literal {
+			t.Fatalf("[%d] token wrong. want=%+v, got=%+v", i, expected, actual)
+		}
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("comments wrong. want=%+v, got=%+v", expectedComments, comments)
+	}
+	for i, expected := range expectedComments {
+		if comments[i] != expected {
+			t.Errorf("[%d] comment wrong. want=%+v, got=%+v", i, expected, comments[i])
+		}
+	}
+}
+
+func TestUnterminatedString(t *testing.T) {
+	l := NewLexer("let s = \"ab\nc")
+
+	for _, expected := range []token.Token{
+		{Type: token.LET, Literal: "let", Position: token.Position{Line: 1, Column: 1}},
+		{Type: token.IDENT, Literal: "s", Position: token.Position{Line: 1, Column: 5}},
+		{Type: token.ASSIGN, Literal: "=", Position: token.Position{Line: 1, Column: 7}},
+		{Type: token.ILLEGAL, Literal: "\"ab\nc", Position: token.Position{Line: 1, Column: 9}},
+		{Type: token.EOF, Literal: " ", Position: token.Position{Line: 2, Column: 3}},
+	} {
+		if actual := l.NextToken(); actual != expected {
+			t.Fatalf("token wrong. want=%+v, got=%+v", expected, actual)
+		}
+	}
+}
@@ -0,0 +1,515 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+var (
+	objectType         = reflect.TypeOf((*Object)(nil)).Elem()
+	errorType          = reflect.TypeOf((*error)(nil)).Elem()
+	emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// FromGo converts a Go value to a dog object:
+//
+//   - nil, and nil pointers, slices, maps and funcs, to NULL
+//   - bool to Boolean, integers to Integer, floats to Float and strings to String
+//   - slices and arrays to Array
+//   - maps with string or integer keys to Hash, ordered by key
+//   - structs to Hash, keyed by the `dog` tag of each exported field or else
+//     its name; fields tagged `dog:"-"` are skipped
+//   - funcs to Builtin, see WrapFunc
+//
+// Pointers and interfaces are converted by the value they point to, and
+// Objects are returned as is. Values containing themselves, such as a
+// struct pointing to itself, cannot be converted.
+func FromGo(v interface{}) (Object, error) {
+	if v == nil {
+		return NULL, nil
+	}
+	return fromValue(reflect.ValueOf(v))
+}
+
+func fromValue(v reflect.Value) (Object, error) {
+	return (&converter{}).fromValue(v)
+}
+
+// visit identifies a pointer, map or slice, by its type, address and length.
+type visit struct {
+	typ     reflect.Type
+	pointer uintptr
+	len     int
+}
+
+// converter converts Go values to dog objects, failing on cyclic values
+// which would otherwise be converted forever.
+type converter struct {
+	visiting map[visit]bool // pointers, maps and slices containing the value converted
+}
+
+// enter records that v, a pointer, map or slice, is being converted. It fails
+// if v is already being converted, that is if v contains itself.
+func (c *converter) enter(v reflect.Value) (visit, error) {
+	key := visit{typ: v.Type(), pointer: v.Pointer()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+	if c.visiting[key] {
+		return key, fmt.Errorf("cannot convert Go %s to dog object: it contains itself", v.Type())
+	}
+	if c.visiting == nil {
+		c.visiting = make(map[visit]bool)
+	}
+	c.visiting[key] = true
+	return key, nil
+}
+
+func (c *converter) fromValue(v reflect.Value) (Object, error) {
+	if v.Type().Implements(objectType) && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return TRUE, nil
+		}
+		return FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewInteger(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("cannot convert %d to INTEGER: out of range", v.Uint())
+		}
+		return NewInteger(int64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return NewFloat(v.Float()), nil
+	case reflect.String:
+		return NewString(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return NULL, nil
+			}
+			visit, err := c.enter(v)
+			if err != nil {
+				return nil, err
+			}
+			defer delete(c.visiting, visit)
+		}
+		elements := make([]Object, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			element, err := c.fromValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+		return NewArray(elements), nil
+	case reflect.Map:
+		return c.fromMap(v)
+	case reflect.Struct:
+		return c.fromStruct(v)
+	case reflect.Func:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return WrapFunc(funcName(v), v.Interface())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		visit, err := c.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(c.visiting, visit)
+		return c.fromValue(v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return c.fromValue(v.Elem())
+	default:
+		return nil, fmt.Errorf("cannot convert Go %s to dog object", v.Type())
+	}
+}
+
+func (c *converter) fromMap(v reflect.Value) (Object, error) {
+	if v.IsNil() {
+		return NULL, nil
+	}
+	visit, err := c.enter(v)
+	if err != nil {
+		return nil, err
+	}
+	defer delete(c.visiting, visit)
+
+	keys := v.MapKeys()
+	switch v.Type().Key().Kind() {
+	case reflect.String:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Int() < keys[j].Int() })
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Uint() < keys[j].Uint() })
+	default:
+		return nil, fmt.Errorf("cannot convert Go %s to dog object: keys must be strings or integers", v.Type())
+	}
+
+	hash := NewHash()
+	for _, k := range keys {
+		key, err := c.fromValue(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := c.fromValue(v.MapIndex(k))
+		if err != nil {
+			return nil, err
+		}
+		hash.Set(key.(Hashable), value)
+	}
+	return hash, nil
+}
+
+func (c *converter) fromStruct(v reflect.Value) (Object, error) {
+	hash := NewHash()
+	for i := 0; i < v.NumField(); i++ {
+		name, ok := fieldName(v.Type().Field(i))
+		if !ok {
+			continue
+		}
+		value, err := c.fromValue(v.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		hash.Set(NewString(name), value)
+	}
+	return hash, nil
+}
+
+// fieldName returns the key of a struct field in a Hash, or false if the
+// field is not converted.
+func fieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	tag := field.Tag.Get("dog")
+	if tag == "-" {
+		return "", false
+	}
+	if tag != "" {
+		return tag, true
+	}
+	return field.Name, true
+}
+
+// funcName returns the name of a Go func, e.g. "strings.ToUpper".
+func funcName(v reflect.Value) string {
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return ""
+	}
+	name := fn.Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// ToGo converts a dog object to a Go value of type t, the reverse of FromGo.
+// To the empty interface, Integer converts to int64, Float to float64, Array
+// to []interface{}, Hash to map[string]interface{} if all its keys are
+// strings and map[interface{}]interface{} otherwise, and NULL to nil. Objects
+// are converted to an interface type implemented by Object as is.
+func ToGo(obj Object, t reflect.Type) (interface{}, error) {
+	v, err := toValue(obj, t)
+	if err != nil {
+		return nil, err
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+	return v.Interface(), nil
+}
+
+// toValue converts obj to a value of type t. It returns the zero Value for nil
+// converted to the empty interface.
+func toValue(obj Object, t reflect.Type) (reflect.Value, error) {
+	if host, ok := obj.(*HostObject); ok && host.value.Type().AssignableTo(t) {
+		return host.value, nil
+	}
+
+	if t.Kind() == reflect.Interface {
+		if t.NumMethod() == 0 {
+			return toInterface(obj)
+		}
+		if reflect.TypeOf(obj).Implements(t) {
+			return reflect.ValueOf(obj).Convert(t), nil
+		}
+		return reflect.Value{}, convertError(obj, t)
+	}
+
+	if obj == NULL {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Func:
+			return reflect.Zero(t), nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := obj.(*Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(t), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if integer, ok := obj.(*Integer); ok {
+			v := reflect.New(t).Elem()
+			if v.OverflowInt(integer.Value) {
+				return reflect.Value{}, fmt.Errorf("cannot convert %d to Go %s: out of range", integer.Value, t)
+			}
+			v.SetInt(integer.Value)
+			return v, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if integer, ok := obj.(*Integer); ok {
+			v := reflect.New(t).Elem()
+			if integer.Value < 0 || v.OverflowUint(uint64(integer.Value)) {
+				return reflect.Value{}, fmt.Errorf("cannot convert %d to Go %s: out of range", integer.Value, t)
+			}
+			v.SetUint(uint64(integer.Value))
+			return v, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch number := obj.(type) {
+		case *Float:
+			return reflect.ValueOf(number.Value).Convert(t), nil
+		case *Integer:
+			return reflect.ValueOf(float64(number.Value)).Convert(t), nil
+		}
+	case reflect.String:
+		if str, ok := obj.(*String); ok {
+			return reflect.ValueOf(str.Value).Convert(t), nil
+		}
+	case reflect.Slice, reflect.Array:
+		if array, ok := obj.(*Array); ok {
+			return toSlice(array, t)
+		}
+	case reflect.Map:
+		if hash, ok := obj.(*Hash); ok {
+			return toMap(hash, t)
+		}
+	case reflect.Struct:
+		if hash, ok := obj.(*Hash); ok {
+			return toStruct(hash, t)
+		}
+	case reflect.Ptr:
+		v, err := toValue(obj, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		setValue(ptr.Elem(), v)
+		return ptr, nil
+	}
+	return reflect.Value{}, convertError(obj, t)
+}
+
+func toInterface(obj Object) (reflect.Value, error) {
+	var v interface{}
+	switch obj := obj.(type) {
+	case *Null:
+		return reflect.Value{}, nil
+	case *Boolean:
+		v = obj.Value
+	case *Integer:
+		v = obj.Value
+	case *Float:
+		v = obj.Value
+	case *String:
+		v = obj.Value
+	case *Array:
+		elements := make([]interface{}, 0, len(obj.Elements))
+		for _, e := range obj.Elements {
+			element, err := ToGo(e, emptyInterfaceType)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			elements = append(elements, element)
+		}
+		v = elements
+	case *Hash:
+		t := reflect.TypeOf(map[interface{}]interface{}(nil))
+		for _, p := range obj.Pairs() {
+			if p.Key.Type() != StringType {
+				return toMap(obj, t)
+			}
+		}
+		return toMap(obj, reflect.TypeOf(map[string]interface{}(nil)))
+	default:
+		v = obj
+	}
+	return reflect.ValueOf(v), nil
+}
+
+func toSlice(array *Array, t reflect.Type) (reflect.Value, error) {
+	var v reflect.Value
+	if t.Kind() == reflect.Array {
+		if len(array.Elements) != t.Len() {
+			return reflect.Value{}, fmt.Errorf("cannot convert array of %d elements to Go %s", len(array.Elements), t)
+		}
+		v = reflect.New(t).Elem()
+	} else {
+		v = reflect.MakeSlice(t, len(array.Elements), len(array.Elements))
+	}
+
+	for i, e := range array.Elements {
+		element, err := toValue(e, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		setValue(v.Index(i), element)
+	}
+	return v, nil
+}
+
+func toMap(hash *Hash, t reflect.Type) (reflect.Value, error) {
+	v := reflect.MakeMapWithSize(t, hash.Len())
+	for _, p := range hash.Pairs() {
+		key, err := toValue(p.Key, t.Key())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		value, err := toValue(p.Value, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if !value.IsValid() {
+			value = reflect.Zero(t.Elem())
+		}
+		v.SetMapIndex(key, value)
+	}
+	return v, nil
+}
+
+// toStruct sets the fields of a new struct of type t from the pairs of hash
+// keyed by their names. Fields missing from hash are left zero.
+func toStruct(hash *Hash, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := fieldName(t.Field(i))
+		if !ok {
+			continue
+		}
+		obj, ok := hash.Get(NewString(name))
+		if !ok {
+			continue
+		}
+		field, err := toValue(obj, t.Field(i).Type)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("field %s: %w", name, err)
+		}
+		setValue(v.Field(i), field)
+	}
+	return v, nil
+}
+
+// setValue sets dst to v, leaving it zero if v is the zero Value converted from NULL.
+func setValue(dst, v reflect.Value) {
+	if v.IsValid() {
+		dst.Set(v)
+	}
+}
+
+func convertError(obj Object, t reflect.Type) error {
+	return fmt.Errorf("cannot convert %s to Go %s", obj.Type(), t)
+}
+
+// WrapFunc wraps a Go func as a builtin. The arguments of a call are converted
+// with ToGo to the parameter types of fn, and its results with FromGo: no
+// result to NULL, one to its object and several to an Array. A trailing error
+// result is not converted but raised as a runtime error if not nil, as is a
+// panic of fn.
+func WrapFunc(name string, fn interface{}) (*Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return nil, fmt.Errorf("cannot wrap Go %T as builtin: not a func", fn)
+	}
+	t := v.Type()
+
+	builtin := &Builtin{Name: name}
+	builtin.Fn = func(args ...Object) (result Object) {
+		defer func() {
+			if r := recover(); r != nil {
+				result = NewError("panic in %s: %v", name, r)
+			}
+		}()
+
+		in, err := funcArguments(t, args)
+		if err != nil {
+			return err
+		}
+		return funcResults(t, v.Call(in))
+	}
+	return builtin, nil
+}
+
+// funcArguments converts args to the parameter types of the func type t.
+func funcArguments(t reflect.Type, args []Object) ([]reflect.Value, *Error) {
+	if t.IsVariadic() && len(args) < t.NumIn()-1 {
+		return nil, NewError("wrong number of arguments: want at least %d, got %d", t.NumIn()-1, len(args))
+	}
+	if !t.IsVariadic() && len(args) != t.NumIn() {
+		return nil, NewError("wrong number of arguments: want=%d, got=%d", t.NumIn(), len(args))
+	}
+
+	in := make([]reflect.Value, 0, len(args))
+	for i, arg := range args {
+		var paramType reflect.Type
+		if t.IsVariadic() && i >= t.NumIn()-1 {
+			paramType = t.In(t.NumIn() - 1).Elem()
+		} else {
+			paramType = t.In(i)
+		}
+
+		v, err := toValue(arg, paramType)
+		if err != nil {
+			return nil, NewError("argument %d: %s", i+1, err)
+		}
+		if !v.IsValid() {
+			v = reflect.Zero(paramType)
+		}
+		in = append(in, v)
+	}
+	return in, nil
+}
+
+// funcResults converts the results of a call of the func type t.
+func funcResults(t reflect.Type, out []reflect.Value) Object {
+	if len(out) > 0 && t.Out(len(out)-1) == errorType {
+		if err := out[len(out)-1]; !err.IsNil() {
+			return NewError("%s", err.Interface())
+		}
+		out = out[:len(out)-1]
+	}
+
+	results := make([]Object, 0, len(out))
+	for _, o := range out {
+		result, err := fromValue(o)
+		if err != nil {
+			return NewError("%s", err)
+		}
+		results = append(results, result)
+	}
+
+	switch len(results) {
+	case 0:
+		return NULL
+	case 1:
+		return results[0]
+	default:
+		return NewArray(results)
+	}
+}
@@ -0,0 +1,205 @@
+package object
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type person struct {
+	Name    string `dog:"name"`
+	Age     int    `dog:"age"`
+	Email   string
+	Secret  string `dog:"-"`
+	private int
+}
+
+func TestFromGo(t *testing.T) {
+	shared := &person{Name: "pochi"}
+	tests := []struct {
+		input interface{}
+		want  string
+	}{
+		{input: nil, want: "null"},
+		{input: true, want: "true"},
+		{input: int8(-3), want: "-3"},
+		{input: uint16(7), want: "7"},
+		{input: 1.5, want: "1.5"},
+		{input: "dog", want: "dog"},
+		{input: []int{1, 2, 3}, want: "[1, 2, 3]"},
+		{input: [2]string{"a", "b"}, want: "[a, b]"},
+		{input: []int(nil), want: "null"},
+		{input: map[string]int{"b": 2, "a": 1}, want: "{a: 1, b: 2}"},
+		{input: map[int]bool{3: true, -1: false}, want: "{-1: false, 3: true}"},
+		{input: person{Name: "pochi", Age: 3, Email: "p@example.com", Secret: "x"}, want: "{name: pochi, age: 3, Email: p@example.com}"},
+		{input: &person{Name: "pochi"}, want: "{name: pochi, age: 0, Email: }"},
+		{input: (*person)(nil), want: "null"},
+		{input: []interface{}{1, "a", nil}, want: "[1, a, null]"},
+		{input: NewString("object"), want: "object"},
+		{input: []*person{shared, shared}, want: "[{name: pochi, age: 0, Email: }, {name: pochi, age: 0, Email: }]"},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			got, err := FromGo(test.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got.Inspect() != test.want {
+				t.Fatalf("object wrong. want=%q, got=%q", test.want, got.Inspect())
+			}
+		})
+	}
+}
+
+func TestFromGoErrors(t *testing.T) {
+	type node struct {
+		Value int
+		Next  *node
+	}
+	loop := &node{Value: 1}
+	loop.Next = &node{Value: 2, Next: loop}
+	hash := map[string]interface{}{}
+	hash["self"] = hash
+	list := []interface{}{1, nil}
+	list[1] = list
+
+	tests := []struct {
+		input interface{}
+		want  string
+	}{
+		{input: uint64(1 << 63), want: "cannot convert 9223372036854775808 to INTEGER: out of range"},
+		{input: map[float64]int{1: 1}, want: "cannot convert Go map[float64]int to dog object: keys must be strings or integers"},
+		{input: make(chan int), want: "cannot convert Go chan int to dog object"},
+		{input: 1 + 2i, want: "cannot convert Go complex128 to dog object"},
+		{input: loop, want: "cannot convert Go *object.node to dog object: it contains itself"},
+		{input: hash, want: "cannot convert Go map[string]interface {} to dog object: it contains itself"},
+		{input: list, want: "cannot convert Go []interface {} to dog object: it contains itself"},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			_, err := FromGo(test.input)
+			if err == nil || err.Error() != test.want {
+				t.Fatalf("error wrong. want=%q, got=%v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestToGo(t *testing.T) {
+	hash := NewHash()
+	hash.Set(NewString("name"), NewString("pochi"))
+	hash.Set(NewString("age"), NewInteger(3))
+	hash.Set(NewString("Secret"), NewString("x"))
+
+	tests := []struct {
+		input Object
+		typ   reflect.Type
+		want  interface{}
+	}{
+		{input: TRUE, typ: reflect.TypeOf(false), want: true},
+		{input: NewInteger(3), typ: reflect.TypeOf(int8(0)), want: int8(3)},
+		{input: NewInteger(3), typ: reflect.TypeOf(uint(0)), want: uint(3)},
+		{input: NewInteger(3), typ: reflect.TypeOf(0.0), want: 3.0},
+		{input: NewFloat(0.5), typ: reflect.TypeOf(float32(0)), want: float32(0.5)},
+		{input: NewString("dog"), typ: reflect.TypeOf(""), want: "dog"},
+		{input: NewArray([]Object{NewInteger(1), NewInteger(2)}), typ: reflect.TypeOf([]int(nil)), want: []int{1, 2}},
+		{input: NewArray([]Object{NewInteger(1), NewInteger(2)}), typ: reflect.TypeOf([2]int{}), want: [2]int{1, 2}},
+		{input: hash, typ: reflect.TypeOf(person{}), want: person{Name: "pochi", Age: 3}},
+		{input: hash, typ: reflect.TypeOf(&person{}), want: &person{Name: "pochi", Age: 3}},
+		{input: NULL, typ: reflect.TypeOf(&person{}), want: (*person)(nil)},
+		{input: NULL, typ: reflect.TypeOf([]int(nil)), want: []int(nil)},
+		{input: NewArray([]Object{NewInteger(1), NewString("a"), NULL}), typ: emptyInterfaceType, want: []interface{}{int64(1), "a", nil}},
+		{input: hash, typ: emptyInterfaceType, want: map[string]interface{}{"name": "pochi", "age": int64(3), "Secret": "x"}},
+		{input: NewString("object"), typ: objectType, want: NewString("object")},
+	}
+	for _, test := range tests {
+		t.Run(test.input.Inspect()+" to "+test.typ.String(), func(t *testing.T) {
+			got, err := ToGo(test.input, test.typ)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("value wrong. want=%#v, got=%#v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestToGoErrors(t *testing.T) {
+	tests := []struct {
+		input Object
+		typ   reflect.Type
+		want  string
+	}{
+		{input: NewInteger(300), typ: reflect.TypeOf(int8(0)), want: "cannot convert 300 to Go int8: out of range"},
+		{input: NewInteger(-1), typ: reflect.TypeOf(uint(0)), want: "cannot convert -1 to Go uint: out of range"},
+		{input: NewString("1"), typ: reflect.TypeOf(0), want: "cannot convert STRING to Go int"},
+		{input: NewArray([]Object{NewInteger(1)}), typ: reflect.TypeOf([2]int{}), want: "cannot convert array of 1 elements to Go [2]int"},
+		{input: NewArray([]Object{TRUE}), typ: reflect.TypeOf([]string(nil)), want: "cannot convert BOOLEAN to Go string"},
+		{input: NULL, typ: reflect.TypeOf(0), want: "cannot convert NULL to Go int"},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			_, err := ToGo(test.input, test.typ)
+			if err == nil || err.Error() != test.want {
+				t.Fatalf("error wrong. want=%q, got=%v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestWrapFunc(t *testing.T) {
+	tests := []struct {
+		fn   interface{}
+		args []Object
+		want string
+	}{
+		{fn: strings.ToUpper, args: []Object{NewString("dog")}, want: "DOG"},
+		{fn: func(a, b int) int { return a + b }, args: []Object{NewInteger(1), NewInteger(2)}, want: "3"},
+		{fn: func(xs ...int) int { return len(xs) }, args: []Object{NewInteger(1), NewInteger(2)}, want: "2"},
+		{fn: func(p person) string { return p.Name }, args: []Object{hashOf("name", NewString("pochi"))}, want: "pochi"},
+		{fn: func() {}, want: "null"},
+		{fn: func() (int, string) { return 1, "a" }, want: "[1, a]"},
+		{fn: func() (int, error) { return 1, nil }, want: "1"},
+		{fn: func() (int, error) { return 0, errors.New("failed") }, want: "RuntimeError: failed"},
+		{fn: func(a int) int { return a }, want: "RuntimeError: wrong number of arguments: want=1, got=0"},
+		{fn: func(s string, xs ...int) int { return 0 }, want: "RuntimeError: wrong number of arguments: want at least 1, got 0"},
+		{fn: func(a int) int { return a }, args: []Object{NewString("1")}, want: "RuntimeError: argument 1: cannot convert STRING to Go int"},
+		{fn: func() int { panic("boom") }, want: "RuntimeError: panic in f: boom"},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			builtin, err := WrapFunc("f", test.fn)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			got := builtin.Fn(test.args...)
+			if got.Inspect() != test.want {
+				t.Fatalf("result wrong. want=%q, got=%q", test.want, got.Inspect())
+			}
+		})
+	}
+}
+
+func TestFromGoFunc(t *testing.T) {
+	got, err := FromGo(strings.Repeat)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	builtin, ok := got.(*Builtin)
+	if !ok {
+		t.Fatalf("not Builtin: %+v", got)
+	}
+	if builtin.Name != "strings.Repeat" {
+		t.Fatalf("builtin name wrong. want=%q, got=%q", "strings.Repeat", builtin.Name)
+	}
+}
+
+func hashOf(key string, value Object) *Hash {
+	hash := NewHash()
+	hash.Set(NewString(key), value)
+	return hash
+}
@@ -0,0 +1,146 @@
+package object
+
+import (
+	"fmt"
+
+	"github.com/maiyama18/dog/token"
+)
+
+// Environment holds the bindings of a scope. Scopes are chained through outer,
+// so that a function body can see the bindings of the scope it was defined in.
+// Bindings are kept by name, or in slots for the names resolved by the parser.
+type Environment struct {
+	store map[string]*Cell
+	slots []Object // nil for slots not bound yet
+	outer *Environment
+}
+
+// Cell is the binding of a name in a scope. A name keeps its cell once
+// bound, so that the cell can be looked up once and read many times.
+type Cell struct {
+	value    Object
+	constant bool
+	position token.Position // of the declaration of a constant
+}
+
+// Value returns the value bound to the name of c.
+func (c *Cell) Value() Object {
+	return c.value
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]*Cell)}
+}
+
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// NewLocalEnvironment returns an environment enclosed by outer with the given
+// number of slots. Its map of bindings by name is only allocated if used.
+func NewLocalEnvironment(outer *Environment, slots int) *Environment {
+	return &Environment{slots: make([]Object, slots), outer: outer}
+}
+
+// Outer returns the scope enclosing this one, or nil at the top level.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Get looks up name from the innermost scope outwards.
+func (e *Environment) Get(name string) (Object, bool) {
+	for ; e != nil; e = e.outer {
+		if cell, ok := e.store[name]; ok {
+			return cell.value, true
+		}
+	}
+	return nil, false
+}
+
+// Cell returns the cell binding name in this scope, not looking in the outer
+// scopes, or nil if name is not bound here.
+func (e *Environment) Cell(name string) *Cell {
+	return e.store[name]
+}
+
+// Set binds name in this scope, shadowing any binding of the outer scopes.
+func (e *Environment) Set(name string, value Object) Object {
+	if cell, ok := e.store[name]; ok {
+		cell.value = value
+		return value
+	}
+	if e.store == nil {
+		e.store = make(map[string]*Cell)
+	}
+	e.store[name] = &Cell{value: value}
+	return value
+}
+
+// GetSlot returns the value in slot of the scope depth scopes out from this
+// one. It reports false if the slot is not bound yet.
+func (e *Environment) GetSlot(depth, slot int) (Object, bool) {
+	for ; depth > 0; depth-- {
+		e = e.outer
+	}
+	value := e.slots[slot]
+	return value, value != nil
+}
+
+// SetSlot binds slot of the scope depth scopes out from this one.
+func (e *Environment) SetSlot(depth, slot int, value Object) {
+	for ; depth > 0; depth-- {
+		e = e.outer
+	}
+	e.slots[slot] = value
+}
+
+// Define binds name in this scope like Set, but fails if name is a constant of this scope.
+func (e *Environment) Define(name string, value Object) error {
+	if cell, ok := e.store[name]; ok && cell.constant {
+		return fmt.Errorf("cannot redeclare constant %s declared at %s", name, cell.position)
+	}
+	e.Set(name, value)
+	return nil
+}
+
+// DefineConstant binds name in this scope as a constant declared at pos.
+func (e *Environment) DefineConstant(name string, value Object, pos token.Position) error {
+	if err := e.Define(name, value); err != nil {
+		return err
+	}
+	cell := e.store[name]
+	cell.constant, cell.position = true, pos
+	return nil
+}
+
+// CheckAssignable reports why the nearest binding of name cannot be updated by
+// Assign, or returns nil if it can.
+func (e *Environment) CheckAssignable(name string) error {
+	if cell, ok := e.store[name]; ok {
+		if cell.constant {
+			return fmt.Errorf("cannot assign to constant %s declared at %s", name, cell.position)
+		}
+		return nil
+	}
+	if e.outer != nil {
+		return e.outer.CheckAssignable(name)
+	}
+	return fmt.Errorf("assignment to undeclared identifier: %s", name)
+}
+
+// Assign updates the nearest existing binding of name. It fails if name is
+// not declared in any enclosing scope or is bound to a constant.
+func (e *Environment) Assign(name string, value Object) error {
+	if err := e.CheckAssignable(name); err != nil {
+		return err
+	}
+	for env := e; env != nil; env = env.outer {
+		if cell, ok := env.store[name]; ok {
+			cell.value = value
+			break
+		}
+	}
+	return nil
+}
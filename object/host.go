@@ -0,0 +1,99 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// HostObject is a live Go value handed to scripts by the host. Scripts access
+// the fields and methods of the value with member expressions such as
+// `req.header("X")`, but only those in its allowlist of members.
+type HostObject struct {
+	value   reflect.Value
+	members map[string]string // names in scripts to names of Go fields and methods
+}
+
+// NewHostObject wraps v, exposing to scripts the fields and methods of v
+// named by the values of members under the names given by its keys. Fields
+// can be assigned if v is a pointer to a struct.
+func NewHostObject(v interface{}, members map[string]string) (*HostObject, error) {
+	h := &HostObject{value: reflect.ValueOf(v), members: members}
+	for name, goName := range members {
+		if !h.method(goName).IsValid() && !h.field(goName).IsValid() {
+			return nil, fmt.Errorf("cannot expose %s as %s: %T has no field or method %s", goName, name, v, goName)
+		}
+	}
+	return h, nil
+}
+
+func (h *HostObject) Type() Type      { return HostType }
+func (h *HostObject) Inspect() string { return fmt.Sprintf("host %s", h.value.Type()) }
+
+// Value returns the wrapped Go value.
+func (h *HostObject) Value() interface{} {
+	return h.value.Interface()
+}
+
+// GetMember returns the value of the exposed field name converted by FromGo,
+// or the exposed method name as a builtin.
+func (h *HostObject) GetMember(name string) (Object, error) {
+	goName, ok := h.members[name]
+	if !ok {
+		return nil, h.unknownMember(name)
+	}
+
+	if method := h.method(goName); method.IsValid() {
+		return WrapFunc(name, method.Interface())
+	}
+	return fromValue(h.field(goName))
+}
+
+// SetMember sets the exposed field name to value converted by ToGo.
+func (h *HostObject) SetMember(name string, value Object) error {
+	goName, ok := h.members[name]
+	if !ok {
+		return h.unknownMember(name)
+	}
+
+	field := h.field(goName)
+	if !field.IsValid() || !field.CanSet() {
+		return fmt.Errorf("cannot assign to member %s of %s", name, h.Inspect())
+	}
+	v, err := toValue(value, field.Type())
+	if err != nil {
+		return err
+	}
+	if !v.IsValid() {
+		v = reflect.Zero(field.Type())
+	}
+	field.Set(v)
+	return nil
+}
+
+func (h *HostObject) unknownMember(name string) error {
+	return fmt.Errorf("unknown member %s of %s", name, h.Inspect())
+}
+
+// method returns the exported method goName of the value, or the zero Value.
+func (h *HostObject) method(goName string) reflect.Value {
+	if m, ok := h.value.Type().MethodByName(goName); !ok || m.PkgPath != "" {
+		return reflect.Value{}
+	}
+	return h.value.MethodByName(goName)
+}
+
+// field returns the exported field goName of the value, or of the struct the
+// value points to, or the zero Value.
+func (h *HostObject) field(goName string) reflect.Value {
+	v := h.value
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	if f, ok := v.Type().FieldByName(goName); !ok || f.PkgPath != "" {
+		return reflect.Value{}
+	}
+	return v.FieldByName(goName)
+}
@@ -0,0 +1,69 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+)
+
+type dog struct {
+	Name string
+}
+
+func (d dog) Bark() string { return d.Name + ": bow" }
+
+func TestNewHostObject(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		members map[string]string
+		want    string
+	}{
+		{value: dog{}, members: map[string]string{"name": "Name", "bark": "Bark"}},
+		{value: &dog{}, members: map[string]string{"name": "Name", "bark": "Bark"}},
+		{value: dog{}, members: map[string]string{"age": "Age"}, want: "cannot expose Age as age: object.dog has no field or method Age"},
+		{value: 1, members: map[string]string{"name": "Name"}, want: "cannot expose Name as name: int has no field or method Name"},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			_, err := NewHostObject(test.value, test.members)
+			if test.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.want {
+				t.Fatalf("error wrong. want=%q, got=%v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestHostObjectMembers(t *testing.T) {
+	d := &dog{Name: "pochi"}
+	host, err := NewHostObject(d, map[string]string{"name": "Name", "bark": "Bark"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := host.SetMember("name", NewString("taro")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d.Name != "taro" {
+		t.Fatalf("field not set: %q", d.Name)
+	}
+
+	bark, err := host.GetMember("bark")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := bark.(*Builtin).Fn().Inspect(); got != "taro: bow" {
+		t.Fatalf("method result wrong. want=%q, got=%q", "taro: bow", got)
+	}
+
+	if _, err := host.GetMember("Name"); err == nil {
+		t.Fatalf("expected error for member not in allowlist")
+	}
+	if v, err := ToGo(host, reflect.TypeOf(d)); err != nil || v != d {
+		t.Fatalf("host value wrong: %v, %v", v, err)
+	}
+}
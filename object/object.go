@@ -1,13 +1,30 @@
 package object
 
-import "fmt"
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/token"
+)
 
 type Type string
 
 const (
-	IntegerType = "INTEGER"
-	BooleanType = "BOOLEAN"
-	NullType    = "NULL"
+	IntegerType     = "INTEGER"
+	FloatType       = "FLOAT"
+	BooleanType     = "BOOLEAN"
+	StringType      = "STRING"
+	ArrayType       = "ARRAY"
+	HashType        = "HASH"
+	FunctionType    = "FUNCTION"
+	BuiltinType     = "BUILTIN"
+	HostType        = "HOST"
+	NullType        = "NULL"
+	ReturnValueType = "RETURN_VALUE"
+	ErrorType       = "ERROR"
 )
 
 type Object interface {
@@ -15,6 +32,17 @@ type Object interface {
 	Inspect() string
 }
 
+// Hashable is implemented by objects which can be used as keys of Hash.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+type HashKey struct {
+	Type  Type
+	Value uint64
+}
+
 type Integer struct {
 	Value int64
 }
@@ -23,8 +51,30 @@ func NewInteger(value int64) *Integer {
 	return &Integer{Value: value}
 }
 
-func (i *Integer) Type() Type      { return IntegerType }
-func (i *Integer) Inspect() string { return fmt.Sprintf("%d", i.Value) }
+func (i *Integer) Type() Type       { return IntegerType }
+func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+func (i *Integer) HashKey() HashKey { return HashKey{Type: i.Type(), Value: uint64(i.Value)} }
+
+// Float is a floating-point number. Scripts have no literal for it, but get
+// it from the host, e.g. through FromGo.
+type Float struct {
+	Value float64
+}
+
+func NewFloat(value float64) *Float {
+	return &Float{Value: value}
+}
+
+func (f *Float) Type() Type      { return FloatType }
+func (f *Float) Inspect() string { return strconv.FormatFloat(f.Value, 'g', -1, 64) }
+
+// TRUE, FALSE and NULL are the only values of their types, so that they can
+// be compared by identity.
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
 
 type Boolean struct {
 	Value bool
@@ -32,8 +82,247 @@ type Boolean struct {
 
 func (b *Boolean) Type() Type      { return BooleanType }
 func (b *Boolean) Inspect() string { return fmt.Sprintf("%t", b.Value) }
+func (b *Boolean) HashKey() HashKey {
+	if b.Value {
+		return HashKey{Type: b.Type(), Value: 1}
+	}
+	return HashKey{Type: b.Type(), Value: 0}
+}
+
+type String struct {
+	Value string
+}
+
+func NewString(value string) *String {
+	return &String{Value: value}
+}
+
+func (s *String) Type() Type      { return StringType }
+func (s *String) Inspect() string { return s.Value }
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func NewArray(elements []Object) *Array {
+	return &Array{Elements: elements}
+}
+
+func (a *Array) Type() Type { return ArrayType }
+func (a *Array) Inspect() string {
+	var elemStrs []string
+	for _, e := range a.Elements {
+		elemStrs = append(elemStrs, e.Inspect())
+	}
+	return fmt.Sprintf("[%s]", strings.Join(elemStrs, ", "))
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	pairs map[HashKey]HashPair
+	keys  []HashKey // insertion order, so that Inspect and iteration are deterministic
+}
+
+func NewHash() *Hash {
+	return &Hash{pairs: make(map[HashKey]HashPair)}
+}
+
+func (h *Hash) Type() Type { return HashType }
+func (h *Hash) Inspect() string {
+	var pairStrs []string
+	for _, p := range h.Pairs() {
+		pairStrs = append(pairStrs, fmt.Sprintf("%s: %s", p.Key.Inspect(), p.Value.Inspect()))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(pairStrs, ", "))
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.pairs[key.HashKey()]
+	if !ok {
+		return nil, false
+	}
+	return pair.Value, true
+}
+
+func (h *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := h.pairs[hashKey]; !ok {
+		h.keys = append(h.keys, hashKey)
+	}
+	h.pairs[hashKey] = HashPair{Key: key, Value: value}
+}
+
+func (h *Hash) Len() int {
+	return len(h.keys)
+}
+
+// Pairs returns the key-value pairs of the hash in insertion order.
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.keys))
+	for _, k := range h.keys {
+		pairs = append(pairs, h.pairs[k])
+	}
+	return pairs
+}
+
+type Function struct {
+	Name       string // name of the binding the function literal was assigned to, if any
+	Parameters []*ast.Parameter
+	Rest       *ast.Identifier
+	Body       *ast.BlockStatement
+	Slots      int // number of slots of the environment of a call
+	Env        *Environment
+}
+
+func (f *Function) Type() Type { return FunctionType }
+func (f *Function) Inspect() string {
+	return fmt.Sprintf("fn (%s) { %s }", ast.ParametersString(f.Parameters, f.Rest), f.Body.String())
+}
+
+type BuiltinFunction func(args ...Object) Object
+
+// Builtin is a function implemented in Go.
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() Type      { return BuiltinType }
+func (b *Builtin) Inspect() string { return fmt.Sprintf("builtin function %s", b.Name) }
 
 type Null struct{}
 
 func (n *Null) Type() Type      { return NullType }
 func (n *Null) Inspect() string { return "null" }
+
+// ReturnValue wraps the value of a return statement while it unwinds enclosing blocks.
+type ReturnValue struct {
+	Value Object
+}
+
+func (r *ReturnValue) Type() Type      { return ReturnValueType }
+func (r *ReturnValue) Inspect() string { return r.Value.Inspect() }
+
+// Kinds of Error.
+const (
+	RuntimeErrorKind   = "RuntimeError" // raised by the evaluator
+	ThrownErrorKind    = "Error"        // thrown by a throw statement without an explicit kind
+	CanceledErrorKind  = "Canceled"     // raised when the context of the evaluation is done
+	OutOfFuelErrorKind = "OutOfFuel"    // raised when the evaluation exceeds its fuel
+)
+
+// Error is an error propagating through the evaluation, either raised by the
+// evaluator or thrown by a throw statement, until it is caught or reaches the top level.
+type Error struct {
+	Kind     string
+	Message  string
+	Position token.Position // zero until attributed to the node which raised it
+	Value    Object         // thrown value, nil for errors raised by the evaluator
+	Stack    []Frame        // call stack where the error was raised, most recent call last
+}
+
+func NewError(format string, args ...interface{}) *Error {
+	return &Error{Kind: RuntimeErrorKind, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Type() Type { return ErrorType }
+func (e *Error) Inspect() string {
+	if e.Position == (token.Position{}) {
+		return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Position, e.Kind, e.Message)
+}
+
+// Limits of the frames printed by Traceback, so that the traceback of an
+// unbounded recursion stays short.
+const (
+	maxRepeatedFrames = 3  // identical frames in a row printed before the rest are counted
+	tracebackEnds     = 50 // entries printed at each end of a longer traceback
+)
+
+// Traceback formats the error with the call stack where it was raised. Runs
+// of identical frames are collapsed, and only the ends of very deep stacks
+// are printed.
+func (e *Error) Traceback() string {
+	if len(e.Stack) == 0 {
+		return e.Inspect()
+	}
+
+	type entry struct {
+		text   string
+		frames int
+	}
+	var entries []entry
+	for i := 0; i < len(e.Stack); {
+		f := e.Stack[i]
+		n := 1
+		for i+n < len(e.Stack) && e.Stack[i+n] == f {
+			n++
+		}
+		i += n
+
+		var text string
+		switch f.Elided {
+		case 0:
+		case 1:
+			text = "  ... 1 tail call elided\n"
+		default:
+			text = fmt.Sprintf("  ... %d tail calls elided\n", f.Elided)
+		}
+		text += fmt.Sprintf("  %s\n", f.String())
+		for j := 0; j < n && j < maxRepeatedFrames; j++ {
+			entries = append(entries, entry{text: text, frames: 1})
+		}
+		switch repeated := n - maxRepeatedFrames; {
+		case repeated == 1:
+			entries = append(entries, entry{text: "  [previous frame repeated 1 more time]\n", frames: 1})
+		case repeated > 1:
+			entries = append(entries, entry{text: fmt.Sprintf("  [previous frame repeated %d more times]\n", repeated), frames: repeated})
+		}
+	}
+	if len(entries) > 2*tracebackEnds {
+		omitted := 0
+		for _, entry := range entries[tracebackEnds : len(entries)-tracebackEnds] {
+			omitted += entry.frames
+		}
+		omission := entry{text: fmt.Sprintf("  ... %d frames omitted\n", omitted)}
+		entries = append(append(entries[:tracebackEnds:tracebackEnds], omission), entries[len(entries)-tracebackEnds:]...)
+	}
+
+	var buff strings.Builder
+	buff.WriteString("Traceback (most recent call last):\n")
+	for _, entry := range entries {
+		buff.WriteString(entry.text)
+	}
+	buff.WriteString(e.Inspect())
+	return buff.String()
+}
+
+// Frame is an entry of the call stack.
+type Frame struct {
+	Function string         // name of the called function, or <anonymous>
+	Position token.Position // position of the call
+	Elided   int            // number of tail calls whose frames this one replaced
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("%s (called at %s)", f.Function, f.Position)
+}
+
+// CaughtError is an Error bound to the parameter of a catch clause. Unlike
+// Error, it is an ordinary value which does not propagate.
+type CaughtError struct {
+	Error *Error
+}
+
+func (c *CaughtError) Type() Type      { return ErrorType }
+func (c *CaughtError) Inspect() string { return c.Error.Inspect() }
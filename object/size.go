@@ -0,0 +1,93 @@
+package object
+
+// Approximate sizes in bytes of the parts of objects on a 64-bit host, for
+// accounting the memory used by scripts.
+const (
+	SlotSize = 16 // a reference to an object, e.g. an element of an array
+	PairSize = 64 // a key-value pair of a hash or a binding of an environment
+
+	headerSize      = 16
+	arrayHeaderSize = 24
+	hashHeaderSize  = 48
+	functionSize    = 64
+	errorSize       = 96
+	frameSize       = 32
+)
+
+// Size returns the approximate size in bytes of obj itself, excluding the
+// objects it refers to.
+func Size(obj Object) int64 {
+	switch obj := obj.(type) {
+	case *String:
+		return headerSize + int64(len(obj.Value))
+	case *Array:
+		return arrayHeaderSize + SlotSize*int64(len(obj.Elements))
+	case *Hash:
+		return hashHeaderSize + PairSize*int64(obj.Len())
+	case *Function:
+		return functionSize
+	case *Error:
+		return errorSize + int64(len(obj.Kind)+len(obj.Message)) + frameSize*int64(len(obj.Stack))
+	default:
+		return headerSize
+	}
+}
+
+// ReachableSize returns the approximate size in bytes of envs, objs and
+// everything reachable from them, counting each object once.
+func ReachableSize(envs []*Environment, objs ...Object) int64 {
+	w := &sizeWalker{seen: make(map[interface{}]bool)}
+	for _, env := range envs {
+		w.walkEnvironment(env)
+	}
+	for _, obj := range objs {
+		w.walk(obj)
+	}
+	return w.size
+}
+
+type sizeWalker struct {
+	seen map[interface{}]bool
+	size int64
+}
+
+func (w *sizeWalker) walk(obj Object) {
+	if obj == nil || w.seen[obj] {
+		return
+	}
+	w.seen[obj] = true
+	w.size += Size(obj)
+
+	switch obj := obj.(type) {
+	case *Array:
+		for _, e := range obj.Elements {
+			w.walk(e)
+		}
+	case *Hash:
+		for _, p := range obj.pairs {
+			w.walk(p.Key)
+			w.walk(p.Value)
+		}
+	case *Function:
+		w.walkEnvironment(obj.Env)
+	case *ReturnValue:
+		w.walk(obj.Value)
+	case *Error:
+		w.walk(obj.Value)
+	case *CaughtError:
+		w.walk(obj.Error)
+	}
+}
+
+func (w *sizeWalker) walkEnvironment(env *Environment) {
+	for ; env != nil && !w.seen[env]; env = env.outer {
+		w.seen[env] = true
+		w.size += hashHeaderSize + PairSize*int64(len(env.store)) + SlotSize*int64(len(env.slots))
+		for _, cell := range env.store {
+			w.walk(cell.value)
+		}
+		for _, value := range env.slots {
+			w.walk(value)
+		}
+	}
+}
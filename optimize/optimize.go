@@ -0,0 +1,145 @@
+// Package optimize simplifies the programs produced by the parser, so that
+// scripts generated with many constants evaluate in fewer steps.
+package optimize
+
+import (
+	"strconv"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/evaluate"
+	"github.com/maiyama18/dog/object"
+	"github.com/maiyama18/dog/token"
+)
+
+// Optimize rewrites program in place. Infix and prefix expressions of
+// literals are replaced by the literal of their value, as in `2 * 3 + 1` to
+// `7` or `!true` to `false`, and if expressions with a literal condition by
+// the branch taken. Expressions raising an error, such as `1 / 0`, are kept
+// so that the error is still raised, at the same position, when the program
+// runs. The optimized program evaluates to the same results, except that it
+// consumes less fuel and the source of its functions is printed simplified.
+func Optimize(program *ast.Program) {
+	ast.Modify(program, optimize)
+}
+
+// optimize rewrites node, whose children are already optimized.
+func optimize(node ast.Node) ast.Node {
+	switch node := node.(type) {
+	case *ast.Program:
+		node.Statements = splice(node.Statements)
+	case *ast.BlockStatement:
+		node.Statements = splice(node.Statements)
+	case *ast.PrefixExpression:
+		if right, ok := value(node.Right); ok {
+			if folded, ok := literal(evaluate.Prefix(node.Operator, right), node.Pos()); ok {
+				return folded
+			}
+		}
+	case *ast.InfixExpression:
+		left, okLeft := value(node.Left)
+		right, okRight := value(node.Right)
+		if okLeft && okRight {
+			if folded, ok := literal(evaluate.Infix(node.Operator, left, right), node.Left.Pos()); ok {
+				return folded
+			}
+		}
+	case *ast.IfExpression:
+		return ifExpression(node)
+	}
+	return node
+}
+
+// splice replaces the if statements of stmts having a literal condition by
+// the statements of the branch taken. An if statement is kept when splicing
+// would change the value of stmts: when its branch is empty and it is the
+// last of stmts. The names declared by the spliced statements keep their
+// bindings, which are slots of the enclosing scope already.
+func splice(stmts []ast.Statement) []ast.Statement {
+	var spliced []ast.Statement
+	for i, stmt := range stmts {
+		if expStmt, ok := stmt.(*ast.ExpressionStatement); ok {
+			if ifExp, ok := expStmt.Expression.(*ast.IfExpression); ok {
+				branch, ok := taken(ifExp)
+				empty := branch == nil || len(branch.Statements) == 0
+				if ok && (i < len(stmts)-1 || !empty) {
+					if branch != nil {
+						spliced = append(spliced, branch.Statements...)
+					}
+					continue
+				}
+			}
+		}
+		spliced = append(spliced, stmt)
+	}
+	return spliced
+}
+
+// ifExpression drops the branch which a literal condition does not take. An
+// if expression taking its alternative becomes one with a true condition
+// taking it as its consequence.
+func ifExpression(ifExp *ast.IfExpression) *ast.IfExpression {
+	branch, ok := taken(ifExp)
+	switch {
+	case !ok:
+	case branch == ifExp.Consequence:
+		ifExp.Alternative = nil
+	case branch != nil:
+		pos := ifExp.Condition.Pos()
+		ifExp.Condition = &ast.BooleanLiteral{Token: token.Token{Type: token.TRUE, Literal: "true", Position: pos}, Value: true}
+		ifExp.Consequence, ifExp.Alternative = branch, nil
+	}
+	return ifExp
+}
+
+// taken returns the branch of ifExp taken if its condition is a literal, or
+// nil if the condition is false and there is no alternative. It reports
+// false if the condition is not a literal.
+func taken(ifExp *ast.IfExpression) (*ast.BlockStatement, bool) {
+	cond, ok := value(ifExp.Condition)
+	if !ok {
+		return nil, false
+	}
+	if evaluate.Truthy(cond) {
+		return ifExp.Consequence, true
+	}
+	return ifExp.Alternative, true
+}
+
+// value returns the value of exp if it is a literal.
+func value(exp ast.Expression) (object.Object, bool) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return object.NewInteger(exp.Value), true
+	case *ast.BooleanLiteral:
+		if exp.Value {
+			return object.TRUE, true
+		}
+		return object.FALSE, true
+	case *ast.StringLiteral:
+		return object.NewString(exp.Value), true
+	default:
+		return nil, false
+	}
+}
+
+// literal returns the literal of obj at pos, reporting false if obj is not
+// an integer, boolean or string, e.g. an error to be raised at run time.
+func literal(obj object.Object, pos token.Position) (ast.Expression, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: strconv.FormatInt(obj.Value, 10), Position: pos}, Value: obj.Value}, true
+	case *object.Boolean:
+		return &ast.BooleanLiteral{Token: token.Token{Type: booleanType(obj.Value), Literal: strconv.FormatBool(obj.Value), Position: pos}, Value: obj.Value}, true
+	case *object.String:
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: obj.Value, Position: pos}, Value: obj.Value}, true
+	default:
+		return nil, false
+	}
+}
+
+func booleanType(b bool) token.Type {
+	if b {
+		return token.TRUE
+	}
+	return token.FALSE
+}
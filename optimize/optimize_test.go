@@ -0,0 +1,95 @@
+package optimize
+
+import (
+	"testing"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/evaluate"
+	"github.com/maiyama18/dog/lex"
+	"github.com/maiyama18/dog/object"
+	"github.com/maiyama18/dog/parse"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "2 * 3 + 1", want: "7;"},
+		{input: "!true", want: "false;"},
+		{input: "!!5", want: "true;"},
+		{input: `"a" + "b" == "ab"`, want: "true;"},
+		{input: "-(2 + 3) * x", want: "(-5 * x);"},
+		{input: "x + 2 * 3", want: "(x + 6);"},
+		{input: "1 + 2 + x", want: "(3 + x);"},
+		{input: "x + 1 + 2", want: "((x + 1) + 2);"},
+		{input: "1 / 0", want: "(1 / 0);"},
+		{input: "2 * (5 % (3 - 3))", want: "(2 * (5 % 0));"},
+		{input: `1 + "a"`, want: `(1 + "a");`},
+		{input: "[1 + 1, {2 * 2: -(-3)}][0]", want: "([2, {4: 3}][0]);"},
+		{input: "let f = fn(a = 2 * 2) { a * (3 - 1) }", want: "let f = fn (a = 4) { (a * 2); };"},
+		{input: "match (x) { n if 1 < 2 => n + (1 + 1) }", want: "match (x) { n if true => (n + 2) };"},
+		{input: "if (1 < 2) { a } else { b }", want: "a;"},
+		{input: "if (false) { a } else { b }; c", want: "b;c;"},
+		{input: "if (false) { a }; c", want: "c;"},
+		{input: "if (false) { a }", want: "if (false) { a; };"},
+		{input: "a; if (true) { }", want: "a;if (true) {  };"},
+		{input: "if (x) { if (1) { a } else { b } }", want: "if (x) { a; };"},
+		{input: "let x = if (true) { 1 } else { 2 }", want: "let x = if (true) { 1; };"},
+		{input: "let x = if (0 > 1) { 1 } else { 2 }", want: "let x = if (true) { 2; };"},
+		{input: "while (1 > 2) { x = 1 + 1 }", want: "while (false) { (x = 2); }"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			program := parseProgram(t, test.input)
+			Optimize(program)
+			if got := program.String(); got != test.want {
+				t.Fatalf("program wrong. want=%q, got=%q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestOptimizePreservesResults(t *testing.T) {
+	tests := []string{
+		"let x = 2; x * (3 + 4)",
+		"1 + 2 / 0",
+		"let f = fn() {\n  10 % (2 - 2)\n}; f()",
+		"let f = fn() { if (true) { return 1 }; 2 }; f()",
+		"let f = fn() { 1; if (true) { } }; f()",
+		"let f = fn() { 1; if (false) { 2 } }; f()",
+		"if (true) { let y = 1 + 1 }; y",
+		"let f = fn(n) { if (true) { if (n == 0) { 0 } else { f(n - 1) } } }; f(100000)",
+		"let f = fn() { let x = if (2 > 1) { 10 } else { 20 }; x }; f()",
+		`let s = "dog" + "!"; s + s`,
+		"try { 1 / (1 - 1) } catch (e) { e[\"line\"] + e[\"column\"] }",
+	}
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			want := evaluate.Eval(parseProgram(t, input))
+			program := parseProgram(t, input)
+			Optimize(program)
+			got := evaluate.Eval(program)
+
+			if got.Inspect() != want.Inspect() {
+				t.Fatalf("result wrong. want=%q, got=%q", want.Inspect(), got.Inspect())
+			}
+			if wantErr, ok := want.(*object.Error); ok {
+				if got.(*object.Error).Traceback() != wantErr.Traceback() {
+					t.Fatalf("traceback wrong. want=\n%s\ngot=\n%s", wantErr.Traceback(), got.(*object.Error).Traceback())
+				}
+			}
+		})
+	}
+}
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	parser := parse.NewParser(lex.NewLexer(input))
+	program := parser.ParseProgram()
+	if errs := parser.Errors(); len(errs) > 0 {
+		t.Fatalf("unexpected parser errors: %v", errs)
+	}
+	return program
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/maiyama18/dog/ast"
 	"github.com/maiyama18/dog/lex"
@@ -14,26 +15,38 @@ type Precedence int
 
 const (
 	LOWEST Precedence = iota + 1
+	ASSIGN
 	EQUALS
 	LESSGREATER
 	SUM
 	PRODUCT
 	PREFIX
 	CALL
+	INDEX
 )
 
+// OperatorPrecedence returns the precedence of a binary or assignment
+// operator such as "+" or "+=", or LOWEST for other strings.
+func OperatorPrecedence(operator string) Precedence {
+	return getPrecedence(token.Type(operator))
+}
+
 func getPrecedence(tokenType token.Type) Precedence {
 	switch tokenType {
+	case token.ASSIGN, token.PLUSASSIGN, token.MINUSASSIGN, token.ASTERISKASSIGN, token.SLASHASSIGN, token.PERCENTASSIGN:
+		return ASSIGN
 	case token.EQ, token.NOTEQ:
 		return EQUALS
 	case token.LT, token.GT:
 		return LESSGREATER
 	case token.PLUS, token.MINUS:
 		return SUM
-	case token.ASTERISK, token.SLASH:
+	case token.ASTERISK, token.SLASH, token.PERCENT:
 		return PRODUCT
 	case token.LPAREN:
 		return CALL
+	case token.LBRACKET, token.DOT:
+		return INDEX
 	default:
 		return LOWEST
 	}
@@ -61,6 +74,8 @@ func NewParser(lexer *lex.Lexer) *Parser {
 	return p
 }
 
+// ParseProgram parses the whole input. If it is free of syntax errors, the
+// program is then resolved, see Resolve.
 func (p *Parser) ParseProgram() *ast.Program {
 	var statements []ast.Statement
 
@@ -72,7 +87,11 @@ func (p *Parser) ParseProgram() *ast.Program {
 		p.consumeToken()
 	}
 
-	return &ast.Program{Statements: statements}
+	program := &ast.Program{Statements: statements, Comments: p.lexer.Comments()}
+	if len(p.errors) == 0 {
+		p.errors = Resolve(program)
+	}
+	return program
 }
 
 func (p *Parser) Errors() []error {
@@ -110,10 +129,14 @@ func (p *Parser) expectNextTokenType(tokenType token.Type) error {
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.currentToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -122,11 +145,11 @@ func (p *Parser) parseStatement() ast.Statement {
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	tok := p.currentToken
 
-	if err := p.expectNextTokenType(token.IDENT); err != nil {
-		p.addError(err)
+	p.consumeToken()
+	pattern := p.parsePattern()
+	if pattern == nil {
 		return nil
 	}
-	ident := &ast.Identifier{Token: p.currentToken, Name: p.currentToken.Literal}
 
 	if err := p.expectNextTokenType(token.ASSIGN); err != nil {
 		p.addError(err)
@@ -140,7 +163,14 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		p.consumeToken()
 	}
 
-	return &ast.LetStatement{Token: tok, Identifier: ident, Expression: expression}
+	// name the function for call stacks
+	if function, ok := expression.(*ast.FunctionLiteral); ok {
+		if ident, ok := pattern.(*ast.Identifier); ok {
+			function.Name = ident.Name
+		}
+	}
+
+	return &ast.LetStatement{Token: tok, Pattern: pattern, Expression: expression}
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
@@ -156,6 +186,50 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return &ast.ReturnStatement{Token: tok, Expression: expression}
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	tok := p.currentToken
+
+	p.consumeToken()
+	expression := p.parseExpression(LOWEST)
+
+	if p.isNextTokenType(token.SEMICOLON) {
+		p.consumeToken()
+	}
+
+	return &ast.ThrowStatement{Token: tok, Expression: expression}
+}
+
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	tok := p.currentToken
+	if err := p.expectNextTokenType(token.LPAREN); err != nil {
+		p.addError(err)
+		return nil
+	}
+	p.consumeToken()
+
+	condition := p.parseExpression(LOWEST)
+	if condition == nil {
+		p.addError(errors.New("failed to parse condition of while statement"))
+	}
+
+	if err := p.expectNextTokenType(token.RPAREN); err != nil {
+		p.addError(err)
+		return nil
+	}
+	if err := p.expectNextTokenType(token.LBRACE); err != nil {
+		p.addError(err)
+		return nil
+	}
+
+	body := p.parseBlockStatement()
+
+	for p.isNextTokenType(token.SEMICOLON) {
+		p.consumeToken()
+	}
+
+	return &ast.WhileStatement{Token: tok, Condition: condition, Body: body}
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	tok := p.currentToken
 
@@ -184,7 +258,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		return nil
 	}
 
-	return &ast.BlockStatement{Token: tok, Statements: statements}
+	return &ast.BlockStatement{Token: tok, Statements: statements, End: p.currentToken.Position}
 }
 
 func (p *Parser) parseExpression(precedence Precedence) ast.Expression {
@@ -215,6 +289,8 @@ func (p *Parser) getParsePrefixFunc() (parsePrefixFunc, error) {
 		return p.parseIdentifier, nil
 	case token.INT:
 		return p.parseIntegerLiteral, nil
+	case token.STRING:
+		return p.parseStringLiteral, nil
 	case token.TRUE, token.FALSE:
 		return p.parseBooleanLiteral, nil
 	case token.BANG, token.MINUS:
@@ -225,6 +301,20 @@ func (p *Parser) getParsePrefixFunc() (parsePrefixFunc, error) {
 		return p.parseIfExpression, nil
 	case token.FUNCTION:
 		return p.parseFunctionLiteral, nil
+	case token.MATCH:
+		return p.parseMatchExpression, nil
+	case token.TRY:
+		return p.parseTryExpression, nil
+	case token.LBRACKET:
+		return p.parseArrayLiteral, nil
+	case token.LBRACE:
+		return p.parseHashLiteral, nil
+	case token.ILLEGAL:
+		// the lexer makes unterminated strings illegal tokens
+		if strings.HasPrefix(p.currentToken.Literal, `"`) {
+			return nil, fmt.Errorf("%s: unterminated string", p.currentToken.Position)
+		}
+		fallthrough
 	default:
 		return nil, fmt.Errorf("could not find to parse prefix function for token type %+v", p.currentToken)
 	}
@@ -232,10 +322,16 @@ func (p *Parser) getParsePrefixFunc() (parsePrefixFunc, error) {
 
 func (p *Parser) getParseInfixFunc() (parseInfixFunc, error) {
 	switch p.nextToken.Type {
-	case token.PLUS, token.MINUS, token.ASTERISK, token.SLASH, token.EQ, token.NOTEQ, token.GT, token.LT:
+	case token.PLUS, token.MINUS, token.ASTERISK, token.SLASH, token.PERCENT, token.EQ, token.NOTEQ, token.GT, token.LT:
 		return p.parseInfixExpression, nil
+	case token.ASSIGN, token.PLUSASSIGN, token.MINUSASSIGN, token.ASTERISKASSIGN, token.SLASHASSIGN, token.PERCENTASSIGN:
+		return p.parseAssignExpression, nil
 	case token.LPAREN:
 		return p.parseCallExpression, nil
+	case token.LBRACKET:
+		return p.parseIndexExpression, nil
+	case token.DOT:
+		return p.parseMemberExpression, nil
 	default:
 		return nil, fmt.Errorf("could not find to parse infix function for token type %+v", p.currentToken)
 	}
@@ -256,6 +352,49 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return &ast.InfixExpression{Token: opToken, Operator: opToken.Literal, Left: left, Right: right}
 }
 
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	opToken := p.currentToken
+
+	switch target := target.(type) {
+	case *ast.Identifier, *ast.IndexExpression, *ast.MemberExpression:
+	case nil:
+		return nil
+	default:
+		p.addError(fmt.Errorf("cannot assign to %s", target.String()))
+		return nil
+	}
+
+	p.consumeToken()
+	// parse the right hand side with the lowest precedence so that assignments are right associative
+	value := p.parseExpression(LOWEST)
+
+	return &ast.AssignExpression{Token: opToken, Operator: opToken.Literal, Target: target, Value: value}
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	tok := p.currentToken
+	p.consumeToken()
+
+	index := p.parseExpression(LOWEST)
+	if err := p.expectNextTokenType(token.RBRACKET); err != nil {
+		p.addError(err)
+		return nil
+	}
+
+	return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+}
+
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	tok := p.currentToken
+	if err := p.expectNextTokenType(token.IDENT); err != nil {
+		p.addError(err)
+		return nil
+	}
+
+	member := &ast.Identifier{Token: p.currentToken, Name: p.currentToken.Literal}
+	return &ast.MemberExpression{Token: tok, Object: left, Member: member}
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.consumeToken()
 	exp := p.parseExpression(LOWEST)
@@ -303,6 +442,56 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return &ast.IfExpression{Token: tok, Condition: condition, Consequence: consequence, Alternative: alternative}
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	tok := p.currentToken
+	if err := p.expectNextTokenType(token.LBRACE); err != nil {
+		p.addError(err)
+		return nil
+	}
+	block := p.parseBlockStatement()
+
+	tryExp := &ast.TryExpression{Token: tok, Block: block}
+
+	if p.isNextTokenType(token.CATCH) {
+		p.consumeToken()
+		if err := p.expectNextTokenType(token.LPAREN); err != nil {
+			p.addError(err)
+			return nil
+		}
+		if err := p.expectNextTokenType(token.IDENT); err != nil {
+			p.addError(err)
+			return nil
+		}
+		tryExp.CatchParameter = &ast.Identifier{Token: p.currentToken, Name: p.currentToken.Literal}
+		if err := p.expectNextTokenType(token.RPAREN); err != nil {
+			p.addError(err)
+			return nil
+		}
+		if err := p.expectNextTokenType(token.LBRACE); err != nil {
+			p.addError(err)
+			return nil
+		}
+
+		tryExp.Catch = p.parseBlockStatement()
+	}
+
+	if p.isNextTokenType(token.FINALLY) {
+		p.consumeToken()
+		if err := p.expectNextTokenType(token.LBRACE); err != nil {
+			p.addError(err)
+			return nil
+		}
+		tryExp.Finally = p.parseBlockStatement()
+	}
+
+	if tryExp.Catch == nil && tryExp.Finally == nil {
+		p.addError(fmt.Errorf("%s: try without catch or finally", tok.Position))
+		return nil
+	}
+
+	return tryExp
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	tok := p.currentToken
 	if err := p.expectNextTokenType(token.LPAREN); err != nil {
@@ -310,7 +499,10 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	parameters := p.parseFunctionParameters()
+	parameters, rest, ok := p.parseFunctionParameters()
+	if !ok {
+		return nil
+	}
 
 	if err := p.expectNextTokenType(token.LBRACE); err != nil {
 		p.addError(err)
@@ -318,23 +510,49 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 	body := p.parseBlockStatement()
 
-	return &ast.FunctionLiteral{Token: tok, Parameters: parameters, Body: body}
+	return &ast.FunctionLiteral{Token: tok, Parameters: parameters, Rest: rest, Body: body}
 }
 
-func (p *Parser) parseFunctionParameters() []ast.Identifier {
+// parseFunctionParameters parses parameters up to the closing parenthesis. A
+// parameter with a default value can only be followed by parameters with
+// default values, and a rest parameter must be the last one. Parameters
+// bound to a plain identifier can also be passed by name, see
+// parseCallArguments.
+func (p *Parser) parseFunctionParameters() ([]*ast.Parameter, *ast.Identifier, bool) {
 	if p.isNextTokenType(token.RPAREN) {
 		p.consumeToken()
-		return nil
+		return nil, nil, true
 	}
 
 	p.consumeToken()
 
-	var parameters []ast.Identifier
+	var parameters []*ast.Parameter
+	var rest *ast.Identifier
 	for {
-		param, ok := p.parseIdentifier().(*ast.Identifier)
-		if ok {
-			parameters = append(parameters, *param)
+		if p.isCurrentTokenType(token.ELLIPSIS) {
+			if err := p.expectNextTokenType(token.IDENT); err != nil {
+				p.addError(err)
+				return nil, nil, false
+			}
+			rest = &ast.Identifier{Token: p.currentToken, Name: p.currentToken.Literal}
+			break
 		}
+
+		pattern := p.parsePattern()
+		if pattern == nil {
+			return nil, nil, false
+		}
+
+		param := &ast.Parameter{Pattern: pattern}
+		if p.isNextTokenType(token.ASSIGN) {
+			p.consumeToken()
+			p.consumeToken()
+			param.Default = p.parseExpression(LOWEST)
+		} else if len(parameters) > 0 && parameters[len(parameters)-1].Default != nil {
+			p.addError(fmt.Errorf("%s: parameter %s without default follows parameter with default", p.currentToken.Position, pattern.String()))
+		}
+		parameters = append(parameters, param)
+
 		if !p.isNextTokenType(token.COMMA) {
 			break
 		}
@@ -344,21 +562,24 @@ func (p *Parser) parseFunctionParameters() []ast.Identifier {
 
 	if err := p.expectNextTokenType(token.RPAREN); err != nil {
 		p.addError(err)
-		return nil
+		return nil, nil, false
 	}
 
-	return parameters
+	return parameters, rest, true
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	tok := p.currentToken
 
-	args := p.parseCallExpressionArguments()
+	args := p.parseCallArguments()
 
-	return &ast.CallExpression{Token: tok, Function: function, Arguments: args}
+	return &ast.CallExpression{Token: tok, Function: function, Arguments: args, End: p.currentToken.Position}
 }
 
-func (p *Parser) parseCallExpressionArguments() []ast.Expression {
+// parseCallArguments parses the arguments of a call, each of which may be
+// spread with `...`, followed by named arguments `name: value` passing
+// values to the parameters of these names.
+func (p *Parser) parseCallArguments() []ast.Expression {
 	if p.isNextTokenType(token.RPAREN) {
 		p.consumeToken()
 		return nil
@@ -367,8 +588,30 @@ func (p *Parser) parseCallExpressionArguments() []ast.Expression {
 	p.consumeToken()
 
 	var args []ast.Expression
+	named := map[string]bool{}
 	for {
-		arg := p.parseExpression(LOWEST)
+		var arg ast.Expression
+		isNamed := p.isCurrentTokenType(token.IDENT) && p.isNextTokenType(token.COLON)
+		if !isNamed && len(named) > 0 {
+			p.addError(fmt.Errorf("%s: positional argument after named arguments", p.currentToken.Position))
+		}
+		switch {
+		case isNamed:
+			tok := p.currentToken
+			if named[tok.Literal] {
+				p.addError(fmt.Errorf("%s: argument %s passed twice", tok.Position, tok.Literal))
+			}
+			named[tok.Literal] = true
+			p.consumeToken()
+			p.consumeToken()
+			arg = &ast.NamedArgument{Token: tok, Name: tok.Literal, Value: p.parseExpression(LOWEST)}
+		case p.isCurrentTokenType(token.ELLIPSIS):
+			tok := p.currentToken
+			p.consumeToken()
+			arg = &ast.SpreadExpression{Token: tok, Value: p.parseExpression(LOWEST)}
+		default:
+			arg = p.parseExpression(LOWEST)
+		}
 		if arg != nil {
 			args = append(args, arg)
 		}
@@ -387,6 +630,79 @@ func (p *Parser) parseCallExpressionArguments() []ast.Expression {
 	return args
 }
 
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	tok := p.currentToken
+
+	elements := p.parseExpressionList(token.RBRACKET)
+
+	return &ast.ArrayLiteral{Token: tok, Elements: elements, End: p.currentToken.Position}
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	tok := p.currentToken
+
+	var pairs []ast.HashPair
+	for !p.isNextTokenType(token.RBRACE) {
+		p.consumeToken()
+		key := p.parseExpression(LOWEST)
+
+		if err := p.expectNextTokenType(token.COLON); err != nil {
+			p.addError(err)
+			return nil
+		}
+
+		p.consumeToken()
+		value := p.parseExpression(LOWEST)
+
+		pairs = append(pairs, ast.HashPair{Key: key, Value: value})
+
+		if p.isNextTokenType(token.RBRACE) {
+			break
+		}
+		if err := p.expectNextTokenType(token.COMMA); err != nil {
+			p.addError(err)
+			return nil
+		}
+	}
+
+	if err := p.expectNextTokenType(token.RBRACE); err != nil {
+		p.addError(err)
+		return nil
+	}
+
+	return &ast.HashLiteral{Token: tok, Pairs: pairs, End: p.currentToken.Position}
+}
+
+// parseExpressionList parses comma separated expressions up to the end token.
+func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
+	if p.isNextTokenType(end) {
+		p.consumeToken()
+		return nil
+	}
+
+	p.consumeToken()
+
+	var exps []ast.Expression
+	for {
+		exp := p.parseExpression(LOWEST)
+		if exp != nil {
+			exps = append(exps, exp)
+		}
+		if !p.isNextTokenType(token.COMMA) {
+			break
+		}
+		p.consumeToken()
+		p.consumeToken()
+	}
+
+	if err := p.expectNextTokenType(end); err != nil {
+		p.addError(err)
+		return nil
+	}
+
+	return exps
+}
+
 func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.currentToken, Name: p.currentToken.Literal}
 }
@@ -400,6 +716,10 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return &ast.IntegerLiteral{Token: p.currentToken, Value: i}
 }
 
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
+}
+
 func (p *Parser) parseBooleanLiteral() ast.Expression {
 	return &ast.BooleanLiteral{Token: p.currentToken, Value: p.isCurrentTokenType(token.TRUE)}
 }
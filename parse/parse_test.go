@@ -1,6 +1,9 @@
 package parse
 
 import (
+	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
@@ -26,6 +29,18 @@ func TestLetStatements(t *testing.T) {
 			input: "let foo = true;",
 			want:  want{identifier: "foo", expression: true},
 		},
+		{
+			input: "const bar = 10;",
+			want:  want{identifier: "bar", expression: 10},
+		},
+		{
+			input: "let [a, [b, ..c]] = x;",
+			want:  want{identifier: "[a, [b, ..c]]", expression: "x"},
+		},
+		{
+			input: "let {name, \"age\": [_, a]} = x;",
+			want:  want{identifier: "{\"name\": name, \"age\": [_, a]}", expression: "x"},
+		},
 	}
 
 	for _, test := range tests {
@@ -49,13 +64,55 @@ func testLetStatement(t *testing.T, statement ast.Statement, expectedIdentName s
 		t.Fatalf("not LetStatement: %+v", statement)
 	}
 
-	if letStmt.Identifier.Name != expectedIdentName {
-		t.Fatalf("identifier name wrong. want=%q, got=%q", expectedIdentName, letStmt.Identifier.Name)
+	if letStmt.Pattern.String() != expectedIdentName {
+		t.Fatalf("identifier name wrong. want=%q, got=%q", expectedIdentName, letStmt.Pattern.String())
 	}
 
 	testLiteralExpression(t, letStmt.Expression, expectedExp)
 }
 
+func TestThrowStatements(t *testing.T) {
+	program := parseProgram(t, `throw "boom";`)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program statements length wrong. want=%d, got=%d", 1, len(program.Statements))
+	}
+
+	throwStmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("not ThrowStatement: %+v", program.Statements[0])
+	}
+	str, ok := throwStmt.Expression.(*ast.StringLiteral)
+	if !ok || str.Value != "boom" {
+		t.Fatalf("thrown expression wrong: %+v", throwStmt.Expression)
+	}
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			input: `while (x < 10) { x += 1 }`,
+			want:  `while ((x < 10)) { (x += 1); }`,
+		},
+		{
+			input: `while (true) { let y = f(); g(y); };`,
+			want:  `while (true) { let y = f();g(y); }`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			program := parseProgram(t, test.input)
+
+			if program.String() != test.want {
+				t.Fatalf("program string wrong. want=%q, got=%q", test.want, program.String())
+			}
+		})
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input string
@@ -366,8 +423,8 @@ func TestFunctionLiterals(t *testing.T) {
 	if len(params) != 2 {
 		t.Fatalf("parameters size wrong. want=%d, got=%d", 2, len(params))
 	}
-	testLiteralExpression(t, &params[0], "x")
-	testLiteralExpression(t, &params[1], "y")
+	testPattern(t, params[0].Pattern, "x")
+	testPattern(t, params[1].Pattern, "y")
 
 	stmts := funcLiteral.Body.Statements
 	if len(stmts) != 1 {
@@ -401,6 +458,10 @@ func TestFunctionParameters(t *testing.T) {
 			input: `fn (x, y, z) {}`,
 			want:  []string{"x", "y", "z"},
 		},
+		{
+			input: `fn ([x, y], {z}) {}`,
+			want:  []string{"[x, y]", "{\"z\": z}"},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.input, func(t *testing.T) {
@@ -423,7 +484,172 @@ func TestFunctionParameters(t *testing.T) {
 				t.Fatalf("parameters size wrong. want=%d, got=%d", len(test.want), len(funcLiteral.Parameters))
 			}
 			for i, w := range test.want {
-				testLiteralExpression(t, &funcLiteral.Parameters[i], w)
+				testPattern(t, funcLiteral.Parameters[i].Pattern, w)
+			}
+		})
+	}
+}
+
+func TestFunctionParameterDefaultsAndRest(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: `fn (x, y = 10) {}`, want: "x, y = 10"},
+		{input: `fn (x, y = x * 2, z = [1]) {}`, want: "x, y = (x * 2), z = [1]"},
+		{input: `fn (...rest) {}`, want: "...rest"},
+		{input: `fn (first, [a, b] = [1, 2], ...rest) {}`, want: "first, [a, b] = [1, 2], ...rest"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			program := parseProgram(t, test.input)
+
+			expStmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("not ExpressionStatement: %+v", program.Statements[0])
+			}
+			funcLiteral, ok := expStmt.Expression.(*ast.FunctionLiteral)
+			if !ok {
+				t.Fatalf("not FunctionLiteral: %+v", expStmt.Expression)
+			}
+
+			got := ast.ParametersString(funcLiteral.Parameters, funcLiteral.Rest)
+			if got != test.want {
+				t.Fatalf("parameters wrong. want=%q, got=%q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestInvalidFunctionParameters(t *testing.T) {
+	tests := []string{
+		"fn (x = 1, y) {}",
+		"fn (...rest, x) {}",
+		"fn (...[a, b]) {}",
+		"fn (x = ) {}",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			parser := NewParser(lex.NewLexer(input))
+			parser.ParseProgram()
+
+			if len(parser.Errors()) == 0 {
+				t.Fatalf("expected parser errors, got none")
+			}
+		})
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			input: `try { f() } catch (e) { g(e) }`,
+			want:  `try { f(); } catch (e) { g(e); };`,
+		},
+		{
+			input: `try { f() } finally { g() }`,
+			want:  `try { f(); } finally { g(); };`,
+		},
+		{
+			input: `let x = try { throw "boom"; } catch (e) { 1 } finally { 2 };`,
+			want:  `let x = try { throw "boom"; } catch (e) { 1; } finally { 2; };`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			program := parseProgram(t, test.input)
+
+			if program.String() != test.want {
+				t.Fatalf("program string wrong. want=%q, got=%q", test.want, program.String())
+			}
+		})
+	}
+}
+
+func TestInvalidMemberExpressions(t *testing.T) {
+	tests := []string{
+		"req.",
+		"req.1",
+		`req."header"`,
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			parser := NewParser(lex.NewLexer(input))
+			parser.ParseProgram()
+
+			if len(parser.Errors()) == 0 {
+				t.Fatalf("expected parser errors, got none")
+			}
+		})
+	}
+}
+
+func TestUnterminatedStrings(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: `"abc`, want: "1:1: unterminated string"},
+		{input: "let s = 1;\nputs(\"a\", \"b);", want: "2:11: unterminated string"},
+	}
+
+	for _, tt := range tests {
+		parser := NewParser(lex.NewLexer(tt.input))
+		parser.ParseProgram()
+
+		errs := parser.Errors()
+		if len(errs) == 0 || errs[0].Error() != tt.want {
+			t.Errorf("errors of %q wrong. want first=%q, got=%v", tt.input, tt.want, errs)
+		}
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "f(1, b: 2, c: x + 1)", want: "f(1, b: 2, c: (x + 1));"},
+		{input: "f(...xs, b: {\"k\": 1})", want: "f(...xs, b: {\"k\": 1});"},
+		{input: "f(b: 2, 1)", want: "1:9: positional argument after named arguments"},
+		{input: "f(b: 2, ...xs)", want: "1:9: positional argument after named arguments"},
+		{input: "f(b: 2, b: 3)", want: "1:9: argument b passed twice"},
+	}
+
+	for _, tt := range tests {
+		parser := NewParser(lex.NewLexer(tt.input))
+		program := parser.ParseProgram()
+
+		got := program.String()
+		if errs := parser.Errors(); len(errs) > 0 {
+			got = errs[0].Error()
+		}
+		if got != tt.want {
+			t.Errorf("parse of %q wrong. want=%q, got=%q", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestInvalidTryExpressions(t *testing.T) {
+	tests := []string{
+		"try { 1 }",
+		"try { 1 } catch { 2 }",
+		"try { 1 } catch (1) { 2 }",
+		"try 1 catch (e) { 2 }",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			parser := NewParser(lex.NewLexer(input))
+			parser.ParseProgram()
+
+			if len(parser.Errors()) == 0 {
+				t.Fatalf("expected parser errors, got none")
 			}
 		})
 	}
@@ -509,6 +735,34 @@ func TestOperatorPrecedences(t *testing.T) {
 			input: "!(true == true);",
 			want:  "(!(true == true));",
 		},
+		{
+			input: "a * [1, 2][b * c];",
+			want:  "(a * ([1, 2][(b * c)]));",
+		},
+		{
+			input: "a = b = 3 + 5;",
+			want:  "(a = (b = (3 + 5)));",
+		},
+		{
+			input: "a += b * 2 % 3;",
+			want:  "(a += ((b * 2) % 3));",
+		},
+		{
+			input: "a[1] = b == c;",
+			want:  "((a[1]) = (b == c));",
+		},
+		{
+			input: `h["k"] -= f(x)[0];`,
+			want:  `((h["k"]) -= (f(x)[0]));`,
+		},
+		{
+			input: "f(a, ...b + c, ...[d]);",
+			want:  "f(a, ...(b + c), ...[d]);",
+		},
+		{
+			input: `-req.header("X") + a.b.c[0];`,
+			want:  `((-(req.header)("X")) + (((a.b).c)[0]));`,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.input, func(t *testing.T) {
@@ -521,6 +775,351 @@ func TestOperatorPrecedences(t *testing.T) {
 	}
 }
 
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+		target   string
+		value    interface{}
+	}{
+		{input: "x = 5;", operator: "=", target: "x", value: 5},
+		{input: "x += y;", operator: "+=", target: "x", value: "y"},
+		{input: "x -= 1;", operator: "-=", target: "x", value: 1},
+		{input: "x *= 2;", operator: "*=", target: "x", value: 2},
+		{input: "x /= 3;", operator: "/=", target: "x", value: 3},
+		{input: "x %= 4;", operator: "%=", target: "x", value: 4},
+		{input: "x[0] = true;", operator: "=", target: "(x[0])", value: true},
+		{input: "req.status += 1;", operator: "+=", target: "(req.status)", value: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			program := parseProgram(t, test.input)
+
+			if len(program.Statements) != 1 {
+				t.Fatalf("program statements length wrong. want=%d, got=%d", 1, len(program.Statements))
+			}
+
+			expStmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("not ExpressionStatement: %+v", program.Statements[0])
+			}
+			assignExp, ok := expStmt.Expression.(*ast.AssignExpression)
+			if !ok {
+				t.Fatalf("not AssignExpression: %+v", expStmt.Expression)
+			}
+			if assignExp.Operator != test.operator {
+				t.Fatalf("operator wrong. want=%q, got=%q", test.operator, assignExp.Operator)
+			}
+			if assignExp.Target.String() != test.target {
+				t.Fatalf("target wrong. want=%q, got=%q", test.target, assignExp.Target.String())
+			}
+			testLiteralExpression(t, assignExp.Value, test.value)
+		})
+	}
+}
+
+func TestInvalidAssignTargets(t *testing.T) {
+	tests := []string{
+		"1 = 2;",
+		"a + b = 3;",
+		"f(x) += 1;",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			parser := NewParser(lex.NewLexer(input))
+			parser.ParseProgram()
+
+			if len(parser.Errors()) == 0 {
+				t.Fatalf("expected parser errors, got none")
+			}
+		})
+	}
+}
+
+func TestConstantReassignments(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			input: "const x = 1; x = 2;",
+			want:  "1:14: cannot assign to constant x declared at 1:7",
+		},
+		{
+			input: "const x = 1;\nlet f = fn() { x += 1 };",
+			want:  "2:16: cannot assign to constant x declared at 1:7",
+		},
+		{
+			input: "const x = 1; let x = 2;",
+			want:  "1:18: cannot redeclare constant x declared at 1:7",
+		},
+		{
+			input: "const x = 1; if (true) { x = 2 };",
+			want:  "1:26: cannot assign to constant x declared at 1:7",
+		},
+		{
+			input: "const [x, y] = [1, 2]; y = 2;",
+			want:  "1:24: cannot assign to constant y declared at 1:11",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			parser := NewParser(lex.NewLexer(test.input))
+			parser.ParseProgram()
+
+			errs := parser.Errors()
+			if len(errs) != 1 {
+				t.Fatalf("parser errors length wrong. want=%d, got=%d (%v)", 1, len(errs), errs)
+			}
+			if errs[0].Error() != test.want {
+				t.Fatalf("error message wrong. want=%q, got=%q", test.want, errs[0].Error())
+			}
+		})
+	}
+}
+
+func TestShadowedConstants(t *testing.T) {
+	tests := []string{
+		"const x = 1; let f = fn(x) { x = 2 };",
+		"const x = 1; let f = fn() { let x = 2; x = 3 };",
+		"let f = fn() { const x = 1 }; let x = 2; x = 3;",
+		"const x = 1; match (2) { x => x = 3 };",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			parseProgram(t, input)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string // name@position=depth/slot of each identifier, or name@position for names looked up by name
+	}{
+		{
+			input: "let x = 1; x",
+			want:  []string{"x@1:5", "x@1:12"},
+		},
+		{
+			input: "let f = fn(a, b) { let c = a + b; c }",
+			want:  []string{"f@1:5", "a@1:12=0/0", "b@1:15=0/1", "c@1:24=0/2", "a@1:28=0/0", "b@1:32=0/1", "c@1:35=0/2"},
+		},
+		{
+			input: "let f = fn(a) { fn() { a + g } }",
+			want:  []string{"f@1:5", "a@1:12=0/0", "a@1:24=1/0", "g@1:28"},
+		},
+		{
+			input: "let f = fn() { let even = fn() { odd() }; let odd = fn() { even() }; even }",
+			want:  []string{"f@1:5", "even@1:20=0/0", "odd@1:34=1/1", "odd@1:47=0/1", "even@1:60=1/0", "even@1:70=0/0"},
+		},
+		{
+			input: "let f = fn(xs) { match (xs) { [h, ..t] if h => t, y => try { y } catch (e) { e } } }",
+			want:  []string{"f@1:5", "xs@1:12=0/0", "xs@1:25=0/0", "h@1:32=0/0", "t@1:37=0/1", "h@1:43=0/0", "t@1:48=0/1", "y@1:51=0/0", "y@1:62=0/0", "e@1:73=0/0", "e@1:78=0/0"},
+		},
+		{
+			input: "let f = fn() { let i = 0; while (i < 3) { let j = i; i = j + 1 } }",
+			want:  []string{"f@1:5", "i@1:20=0/0", "i@1:34=0/0", "j@1:47=0/0", "i@1:51=1/0", "i@1:54=1/0", "j@1:58=0/0"},
+		},
+		{
+			// initializers refer to the declarations preceding the statement
+			input: "let x = 1; let f = fn(x = x) { while (x) { let x = x - 1; x } }",
+			want:  []string{"x@1:5", "f@1:16", "x@1:23=0/0", "x@1:27", "x@1:39=0/0", "x@1:48=0/0", "x@1:52=1/0", "x@1:59=0/0"},
+		},
+		{
+			// the branches of an if expression declare their names apart,
+			// in slots of the enclosing scope
+			input: "let f = fn(c) { if (c) { let x = 1; x } else { let x = 2; x }; x }",
+			want:  []string{"f@1:5", "c@1:12=0/0", "c@1:21=0/0", "x@1:30=0/1", "x@1:37=0/1", "x@1:52=0/2", "x@1:59=0/2", "x@1:64"},
+		},
+		{
+			input: "let f = fn() { try { let x = 1 } finally { let x = 2; while (x) { x } } }",
+			want:  []string{"f@1:5", "x@1:26=0/0", "x@1:48=0/1", "x@1:62=0/1", "x@1:67=1/1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			program := parseProgram(t, test.input)
+
+			var got []string
+			for _, ident := range identifiers(program) {
+				s := fmt.Sprintf("%s@%s", ident.Name, ident.Token.Position)
+				if ident.Binding != nil {
+					s += fmt.Sprintf("=%d/%d", ident.Binding.Depth, ident.Binding.Slot)
+				}
+				got = append(got, s)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("bindings wrong. want=%v, got=%v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestResolveSlots(t *testing.T) {
+	program := parseProgram(t, "let f = fn(a, [b, c], ...d) { let e = 1; if (a) { let g = 2 }; while (b) { let h = 3 } }")
+
+	function := program.Statements[0].(*ast.LetStatement).Expression.(*ast.FunctionLiteral)
+	if function.Slots != 6 {
+		t.Fatalf("slots of function wrong. want=%d, got=%d", 6, function.Slots)
+	}
+	while := function.Body.Statements[2].(*ast.WhileStatement)
+	if while.Slots != 1 {
+		t.Fatalf("slots of while body wrong. want=%d, got=%d", 1, while.Slots)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			input: "x + 1; let x = 2;",
+			want:  "1:1: x used before its declaration at 1:12",
+		},
+		{
+			input: "let f = fn() { let y = x; let x = 1 };",
+			want:  "1:24: x used before its declaration at 1:31",
+		},
+		{
+			input: "let f = fn() { let y = x + 1; let x = x };",
+			want:  "1:24: x used before its declaration at 1:35",
+		},
+		{
+			input: "let f = fn(a = b, b = 1) { a };",
+			want:  "1:16: b used before its declaration at 1:19",
+		},
+		{
+			input: "let f = fn() { while (true) { i = 1 }; let i = 0 };",
+			want:  "1:31: i used before its declaration at 1:44",
+		},
+		{
+			input: "let x = 1; let x = 2;",
+			want:  "1:16: cannot redeclare x declared at 1:5",
+		},
+		{
+			input: "let f = fn(a, a) { a };",
+			want:  "1:15: cannot redeclare a declared at 1:12",
+		},
+		{
+			input: `match (1) { [a, {"b": a}] => a };`,
+			want:  "1:23: cannot redeclare a declared at 1:14",
+		},
+		{
+			input: "let f = fn() { if (true) { let y = 1; let y = 2 } };",
+			want:  "1:43: cannot redeclare y declared at 1:32",
+		},
+		{
+			input: "let f = fn() { g() }; f(); const g = fn() { 1 }; g = 2;",
+			want:  "1:50: cannot assign to constant g declared at 1:34",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			parser := NewParser(lex.NewLexer(test.input))
+			parser.ParseProgram()
+
+			errs := parser.Errors()
+			if len(errs) != 1 {
+				t.Fatalf("parser errors length wrong. want=%d, got=%d (%v)", 1, len(errs), errs)
+			}
+			if errs[0].Error() != test.want {
+				t.Fatalf("error message wrong. want=%q, got=%q", test.want, errs[0].Error())
+			}
+		})
+	}
+}
+
+func TestResolveScopesOfFunctions(t *testing.T) {
+	tests := []string{
+		"let f = fn() { g() }; let g = fn() { f() };",
+		"let f = fn() { let g = fn() { h }; let h = 1; g() };",
+		"let f = fn() { while (true) { let k = fn() { z } }; let z = 1 };",
+		"let x = 1; let f = fn(x) { x };",
+		"let f = fn() { let x = 1; match (x) { x => x } };",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			parseProgram(t, input)
+		})
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			input: `match (x) { 1 => "one", -1 => "minus one", _ => "other" }`,
+			want:  `match (x) { 1 => "one", (-1) => "minus one", _ => "other" }`,
+		},
+		{
+			input: `match (x) { "a" => 1, true => 2, y => y, }`,
+			want:  `match (x) { "a" => 1, true => 2, y => y }`,
+		},
+		{
+			input: `match (xs) { [] => 0, [h, ..t] if h > 0 => h + 1, [_, [a, b]] => a }`,
+			want:  `match (xs) { [] => 0, [h, ..t] if (h > 0) => (h + 1), [_, [a, b]] => a }`,
+		},
+		{
+			input: `match (p) { {name, "age": 20} => name, {} => "" }`,
+			want:  `match (p) { {"name": name, "age": 20} => name, {} => "" }`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			program := parseProgram(t, test.input)
+
+			if len(program.Statements) != 1 {
+				t.Fatalf("program statements length wrong. want=%d, got=%d", 1, len(program.Statements))
+			}
+
+			expStmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("not ExpressionStatement: %+v", program.Statements[0])
+			}
+			matchExp, ok := expStmt.Expression.(*ast.MatchExpression)
+			if !ok {
+				t.Fatalf("not MatchExpression: %+v", expStmt.Expression)
+			}
+			if matchExp.String() != test.want {
+				t.Fatalf("match expression string wrong. want=%q, got=%q", test.want, matchExp.String())
+			}
+		})
+	}
+}
+
+func TestInvalidPatterns(t *testing.T) {
+	tests := []string{
+		"match (x) { 1 + 2 => 3 }",
+		"match (x) { -y => 3 }",
+		"match (x) { [..t, h] => 3 }",
+		"match (x) { {1} => 3 }",
+		"match (x) { 1 -> 3 }",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			parser := NewParser(lex.NewLexer(input))
+			parser.ParseProgram()
+
+			if len(parser.Errors()) == 0 {
+				t.Fatalf("expected parser errors, got none")
+			}
+		})
+	}
+}
+
 func parseProgram(t *testing.T, input string) *ast.Program {
 	t.Helper()
 
@@ -605,6 +1204,14 @@ func testBooleanLiteral(t *testing.T, exp ast.Expression, want bool) {
 	}
 }
 
+func testPattern(t *testing.T, pattern ast.Pattern, want string) {
+	t.Helper()
+
+	if pattern.String() != want {
+		t.Fatalf("pattern wrong. want=%q, got=%q", want, pattern.String())
+	}
+}
+
 func testIdentifier(t *testing.T, exp ast.Expression, want string) {
 	t.Helper()
 
@@ -616,3 +1223,20 @@ func testIdentifier(t *testing.T, exp ast.Expression, want string) {
 		t.Fatalf("identifier name wrong. want=%q, got=%q", want, ident.Name)
 	}
 }
+
+// identifiers returns the identifiers of node in the order of their positions.
+func identifiers(node ast.Node) []*ast.Identifier {
+	var idents []*ast.Identifier
+	ast.Inspect(node, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			idents = append(idents, ident)
+		}
+		return true
+	})
+
+	sort.SliceStable(idents, func(i, j int) bool {
+		a, b := idents[i].Token.Position, idents[j].Token.Position
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return idents
+}
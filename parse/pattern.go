@@ -0,0 +1,231 @@
+package parse
+
+import (
+	"fmt"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/token"
+)
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	tok := p.currentToken
+	if err := p.expectNextTokenType(token.LPAREN); err != nil {
+		p.addError(err)
+		return nil
+	}
+	p.consumeToken()
+
+	subject := p.parseExpression(LOWEST)
+
+	if err := p.expectNextTokenType(token.RPAREN); err != nil {
+		p.addError(err)
+		return nil
+	}
+	if err := p.expectNextTokenType(token.LBRACE); err != nil {
+		p.addError(err)
+		return nil
+	}
+
+	var arms []ast.MatchArm
+	for !p.isNextTokenType(token.RBRACE) {
+		p.consumeToken()
+		arm, ok := p.parseMatchArm()
+		if !ok {
+			return nil
+		}
+		arms = append(arms, arm)
+
+		if p.isNextTokenType(token.RBRACE) {
+			break
+		}
+		if err := p.expectNextTokenType(token.COMMA); err != nil {
+			p.addError(err)
+			return nil
+		}
+	}
+
+	if err := p.expectNextTokenType(token.RBRACE); err != nil {
+		p.addError(err)
+		return nil
+	}
+
+	return &ast.MatchExpression{Token: tok, Subject: subject, Arms: arms, End: p.currentToken.Position}
+}
+
+func (p *Parser) parseMatchArm() (ast.MatchArm, bool) {
+	pattern := p.parsePattern()
+	if pattern == nil {
+		return ast.MatchArm{}, false
+	}
+
+	var guard ast.Expression
+	if p.isNextTokenType(token.IF) {
+		p.consumeToken()
+		p.consumeToken()
+		guard = p.parseExpression(LOWEST)
+	}
+
+	if err := p.expectNextTokenType(token.ARROW); err != nil {
+		p.addError(err)
+		return ast.MatchArm{}, false
+	}
+	p.consumeToken()
+
+	body := p.parseExpression(LOWEST)
+
+	return ast.MatchArm{Pattern: pattern, Guard: guard, Body: body}, true
+}
+
+func isLiteral(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.BooleanLiteral:
+		return true
+	case *ast.PrefixExpression:
+		_, ok := exp.Right.(*ast.IntegerLiteral)
+		return exp.Operator == "-" && ok
+	default:
+		return false
+	}
+}
+
+// patternIdentifiers returns the identifiers bound by pattern.
+func patternIdentifiers(pattern ast.Pattern) []*ast.Identifier {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return []*ast.Identifier{pattern}
+	case *ast.ArrayPattern:
+		var idents []*ast.Identifier
+		for _, e := range pattern.Elements {
+			idents = append(idents, patternIdentifiers(e)...)
+		}
+		if pattern.Rest != nil {
+			idents = append(idents, pattern.Rest)
+		}
+		return idents
+	case *ast.HashPattern:
+		var idents []*ast.Identifier
+		for _, pair := range pattern.Pairs {
+			idents = append(idents, patternIdentifiers(pair.Value)...)
+		}
+		return idents
+	default:
+		return nil
+	}
+}
+
+// parsePattern parses the pattern starting at the current token.
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.currentToken.Type {
+	case token.IDENT:
+		if p.currentToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.currentToken}
+		}
+		return &ast.Identifier{Token: p.currentToken, Name: p.currentToken.Literal}
+	case token.INT, token.STRING, token.TRUE, token.FALSE, token.MINUS:
+		tok := p.currentToken
+		value := p.parseExpression(PREFIX)
+		if value == nil {
+			return nil
+		}
+		if !isLiteral(value) {
+			p.addError(fmt.Errorf("%s: %s is not a literal pattern", tok.Position, value.String()))
+			return nil
+		}
+		return &ast.LiteralPattern{Token: tok, Value: value}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	default:
+		p.addError(fmt.Errorf("%s: unexpected token %q in pattern", p.currentToken.Position, p.currentToken.Literal))
+		return nil
+	}
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	tok := p.currentToken
+
+	var elements []ast.Pattern
+	var rest *ast.Identifier
+	for !p.isNextTokenType(token.RBRACKET) {
+		p.consumeToken()
+
+		if p.isCurrentTokenType(token.DOTDOT) {
+			if err := p.expectNextTokenType(token.IDENT); err != nil {
+				p.addError(err)
+				return nil
+			}
+			rest = &ast.Identifier{Token: p.currentToken, Name: p.currentToken.Literal}
+			break
+		}
+
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		elements = append(elements, element)
+
+		if p.isNextTokenType(token.RBRACKET) {
+			break
+		}
+		if err := p.expectNextTokenType(token.COMMA); err != nil {
+			p.addError(err)
+			return nil
+		}
+	}
+
+	if err := p.expectNextTokenType(token.RBRACKET); err != nil {
+		p.addError(err)
+		return nil
+	}
+
+	return &ast.ArrayPattern{Token: tok, Elements: elements, Rest: rest}
+}
+
+func (p *Parser) parseHashPattern() ast.Pattern {
+	tok := p.currentToken
+
+	var pairs []ast.HashPatternPair
+	for !p.isNextTokenType(token.RBRACE) {
+		p.consumeToken()
+
+		var pair ast.HashPatternPair
+		switch p.currentToken.Type {
+		case token.IDENT:
+			// shorthand `{name}` for `{"name": name}`
+			ident := &ast.Identifier{Token: p.currentToken, Name: p.currentToken.Literal}
+			pair = ast.HashPatternPair{Key: &ast.StringLiteral{Token: p.currentToken, Value: ident.Name}, Value: ident}
+		case token.STRING, token.INT, token.TRUE, token.FALSE:
+			key := p.parseExpression(PREFIX)
+			if err := p.expectNextTokenType(token.COLON); err != nil {
+				p.addError(err)
+				return nil
+			}
+			p.consumeToken()
+			value := p.parsePattern()
+			if value == nil {
+				return nil
+			}
+			pair = ast.HashPatternPair{Key: key, Value: value}
+		default:
+			p.addError(fmt.Errorf("%s: unexpected token %q as key of hash pattern", p.currentToken.Position, p.currentToken.Literal))
+			return nil
+		}
+		pairs = append(pairs, pair)
+
+		if p.isNextTokenType(token.RBRACE) {
+			break
+		}
+		if err := p.expectNextTokenType(token.COMMA); err != nil {
+			p.addError(err)
+			return nil
+		}
+	}
+
+	if err := p.expectNextTokenType(token.RBRACE); err != nil {
+		p.addError(err)
+		return nil
+	}
+
+	return &ast.HashPattern{Token: tok, Pairs: pairs}
+}
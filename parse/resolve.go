@@ -0,0 +1,306 @@
+package parse
+
+import (
+	"fmt"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/token"
+)
+
+type declaration struct {
+	constant bool
+	position token.Position
+	binding  *ast.Binding // nil in the top level scope
+}
+
+// scope records the names declared in a scope of the program. Like the
+// environments of the evaluator, a scope is created for the program, for each
+// call of a function, for each iteration of a while loop, for each match arm
+// and for each catch block. The blocks of if expressions and the try and
+// finally blocks are block scopes: their names are visible only in the block,
+// so that sibling blocks can declare the same names, but they are stored in
+// slots of the enclosing scope, as these blocks run in its environment.
+type scope struct {
+	declarations map[string]declaration
+	used         map[string]token.Position // names used here before being declared here, at their first use
+	slots        int
+	block        bool
+	outer        *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{declarations: make(map[string]declaration), used: make(map[string]token.Position), outer: outer}
+}
+
+// resolver resolves the identifiers of a program to slots of the scopes
+// declaring them. The bodies of function literals are resolved after the
+// scopes enclosing them, so that they can refer to names declared after the
+// literal, e.g. for mutual recursion.
+type resolver struct {
+	scope     *scope
+	functions []pendingFunction
+	errors    []error
+}
+
+type pendingFunction struct {
+	function *ast.FunctionLiteral
+	outer    *scope
+}
+
+// Resolve resolves the identifiers of program, setting their Binding and the
+// number of slots of the scopes declaring them. It reports names used before
+// their declaration in the same scope, names declared twice in the same scope
+// and assignments to constants. ParseProgram resolves the programs it parses,
+// so Resolve is needed only for programs built or modified otherwise.
+func Resolve(program *ast.Program) []error {
+	r := &resolver{scope: newScope(nil)}
+	r.statements(program.Statements)
+	for len(r.functions) > 0 {
+		pending := r.functions[0]
+		r.functions = r.functions[1:]
+		r.function(pending.function, pending.outer)
+	}
+	return r.errors
+}
+
+func (r *resolver) addError(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Errorf(format, args...))
+}
+
+// enter starts a scope enclosed by the current one, and leave ends it,
+// returning the number of its slots.
+func (r *resolver) enter() {
+	r.scope = newScope(r.scope)
+}
+
+// enterBlock starts a block scope enclosed by the current one. It is ended
+// by leave, which returns 0 for it.
+func (r *resolver) enterBlock() {
+	r.enter()
+	r.scope.block = true
+}
+
+func (r *resolver) leave() int {
+	slots := r.scope.slots
+	r.scope = r.scope.outer
+	return slots
+}
+
+// declare declares the names bound by pattern in the current scope.
+func (r *resolver) declare(pattern ast.Pattern, constant bool) {
+	for _, ident := range patternIdentifiers(pattern) {
+		s := r.scope
+		if decl, ok := s.declarations[ident.Name]; ok {
+			if decl.constant {
+				r.addError("%s: cannot redeclare constant %s declared at %s", ident.Token.Position, ident.Name, decl.position)
+			} else {
+				r.addError("%s: cannot redeclare %s declared at %s", ident.Token.Position, ident.Name, decl.position)
+			}
+			ident.Binding = decl.binding
+			continue
+		}
+		if pos, ok := s.used[ident.Name]; ok {
+			r.addError("%s: %s used before its declaration at %s", pos, ident.Name, ident.Token.Position)
+		}
+
+		decl := declaration{constant: constant, position: ident.Token.Position}
+		owner := s
+		for owner.block {
+			owner = owner.outer
+		}
+		if owner.outer != nil {
+			decl.binding = &ast.Binding{Slot: owner.slots}
+			owner.slots++
+		}
+		s.declarations[ident.Name] = decl
+		ident.Binding = decl.binding
+	}
+}
+
+// lookup resolves ident to the nearest declaration of its name, recording
+// the use in the scopes passed through.
+func (r *resolver) lookup(ident *ast.Identifier) (declaration, bool) {
+	depth := 0
+	for s := r.scope; s != nil; s = s.outer {
+		if decl, ok := s.declarations[ident.Name]; ok {
+			ident.Binding = nil
+			if decl.binding != nil {
+				ident.Binding = &ast.Binding{Depth: depth, Slot: decl.binding.Slot}
+			}
+			return decl, true
+		}
+		if _, ok := s.used[ident.Name]; !ok {
+			s.used[ident.Name] = ident.Token.Position
+		}
+		if !s.block {
+			depth++
+		}
+	}
+	ident.Binding = nil
+	return declaration{}, false
+}
+
+func (r *resolver) statements(stmts []ast.Statement) {
+	for _, s := range stmts {
+		r.statement(s)
+	}
+}
+
+// block resolves the statements of block in a block scope.
+func (r *resolver) block(block *ast.BlockStatement) {
+	r.enterBlock()
+	r.statements(block.Statements)
+	r.leave()
+}
+
+func (r *resolver) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.BlockStatement:
+		r.statements(stmt.Statements)
+	case *ast.ExpressionStatement:
+		r.expression(stmt.Expression)
+	case *ast.LetStatement:
+		r.initializer(stmt.Expression, stmt.Pattern)
+		r.pattern(stmt.Pattern)
+		r.declare(stmt.Pattern, stmt.Constant())
+	case *ast.ReturnStatement:
+		r.expression(stmt.Expression)
+	case *ast.ThrowStatement:
+		r.expression(stmt.Expression)
+	case *ast.WhileStatement:
+		r.expression(stmt.Condition)
+		r.enter()
+		r.statements(stmt.Body.Statements)
+		stmt.Slots = r.leave()
+	}
+}
+
+func (r *resolver) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		r.lookup(exp)
+	case *ast.PrefixExpression:
+		r.expression(exp.Right)
+	case *ast.InfixExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Right)
+	case *ast.AssignExpression:
+		if ident, ok := exp.Target.(*ast.Identifier); ok {
+			if decl, ok := r.lookup(ident); ok && decl.constant {
+				r.addError("%s: cannot assign to constant %s declared at %s", ident.Token.Position, ident.Name, decl.position)
+			}
+		} else {
+			r.expression(exp.Target)
+		}
+		r.expression(exp.Value)
+	case *ast.MemberExpression:
+		r.expression(exp.Object)
+	case *ast.IndexExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Index)
+	case *ast.CallExpression:
+		r.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			r.expression(arg)
+		}
+	case *ast.SpreadExpression:
+		r.expression(exp.Value)
+	case *ast.NamedArgument:
+		r.expression(exp.Value)
+	case *ast.ArrayLiteral:
+		for _, element := range exp.Elements {
+			r.expression(element)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			r.expression(pair.Key)
+			r.expression(pair.Value)
+		}
+	case *ast.FunctionLiteral:
+		r.functions = append(r.functions, pendingFunction{function: exp, outer: r.scope})
+	case *ast.IfExpression:
+		r.expression(exp.Condition)
+		r.block(exp.Consequence)
+		if exp.Alternative != nil {
+			r.block(exp.Alternative)
+		}
+	case *ast.TryExpression:
+		r.block(exp.Block)
+		if exp.Catch != nil {
+			r.enter()
+			r.declare(exp.CatchParameter, false)
+			r.statements(exp.Catch.Statements)
+			exp.CatchSlots = r.leave()
+		}
+		if exp.Finally != nil {
+			r.block(exp.Finally)
+		}
+	case *ast.MatchExpression:
+		r.expression(exp.Subject)
+		for i := range exp.Arms {
+			arm := &exp.Arms[i]
+			r.enter()
+			r.pattern(arm.Pattern)
+			r.declare(arm.Pattern, false)
+			if arm.Guard != nil {
+				r.expression(arm.Guard)
+			}
+			r.expression(arm.Body)
+			arm.Slots = r.leave()
+		}
+	}
+}
+
+// initializer resolves exp, the value of the names bound by pattern. It is
+// evaluated before they are declared, so the names of pattern it uses refer
+// to the enclosing declarations, as x in let x = x + 1, and are not uses
+// before the declaration.
+func (r *resolver) initializer(exp ast.Expression, pattern ast.Pattern) {
+	s := r.scope
+	var names []string
+	for _, ident := range patternIdentifiers(pattern) {
+		if _, ok := s.used[ident.Name]; !ok {
+			names = append(names, ident.Name)
+		}
+	}
+	r.expression(exp)
+	for _, name := range names {
+		delete(s.used, name)
+	}
+}
+
+// pattern resolves the expressions of the literals and keys of pattern.
+func (r *resolver) pattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		r.expression(pattern.Value)
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			r.pattern(element)
+		}
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			r.expression(pair.Key)
+			r.pattern(pair.Value)
+		}
+	}
+}
+
+// function resolves the parameters and the body of function in a scope
+// enclosed by outer. Defaults can refer to the preceding parameters.
+func (r *resolver) function(function *ast.FunctionLiteral, outer *scope) {
+	r.scope = outer
+	r.enter()
+	for _, param := range function.Parameters {
+		if param.Default != nil {
+			r.initializer(param.Default, param.Pattern)
+		}
+		r.pattern(param.Pattern)
+		r.declare(param.Pattern, false)
+	}
+	if function.Rest != nil {
+		r.declare(function.Rest, false)
+	}
+	r.statements(function.Body.Statements)
+	function.Slots = r.leave()
+}
@@ -2,35 +2,96 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
 
-	"github.com/maiyama18/dog/evaluate"
-
-	"github.com/maiyama18/dog/lex"
-	"github.com/maiyama18/dog/parse"
+	"github.com/maiyama18/dog"
+	"github.com/maiyama18/dog/compile"
 )
 
 const PROMPT = "~> "
 
 func main() {
 	scanner := bufio.NewScanner(os.Stdin)
+	interpreter := dog.New()
+	interrupts := newInterrupter()
 
 	fmt.Print(PROMPT)
 	for scanner.Scan() {
 		line := scanner.Text()
-		lexer := lex.NewLexer(line)
-		parser := parse.NewParser(lexer)
 
-		program := parser.ParseProgram()
-		if len(parser.Errors()) > 0 {
-			for _, err := range parser.Errors() {
-				fmt.Println(err.Error())
+		// `:disasm CODE` prints the bytecode of CODE instead of evaluating it
+		if code, ok := strings.CutPrefix(line, ":disasm"); ok {
+			if bytecode, err := dog.Compile(code); err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Print(compile.Disassemble(bytecode))
 			}
-		} else {
-			fmt.Println(evaluate.Eval(program).Inspect())
+			fmt.Print(PROMPT)
+			continue
+		}
+
+		result, err := interpreter.Eval(interrupts.start(), line)
+		interrupts.done()
+
+		var dogErr *dog.Error
+		switch {
+		case errors.As(err, &dogErr):
+			fmt.Println(dogErr.Traceback())
+		case err != nil:
+			fmt.Println(err)
+		default:
+			fmt.Println(result.Inspect())
 		}
 
 		fmt.Print(PROMPT)
 	}
 }
+
+// interrupter handles Ctrl-C for the whole session: it cancels the
+// evaluation in flight, if any, and reprints the prompt otherwise, so that
+// Ctrl-C never ends the session.
+type interrupter struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc // cancels the evaluation in flight, nil between evaluations
+}
+
+func newInterrupter() *interrupter {
+	i := &interrupter{}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		for range signals {
+			i.mu.Lock()
+			if i.cancel != nil {
+				i.cancel()
+			} else {
+				fmt.Print("\n" + PROMPT)
+			}
+			i.mu.Unlock()
+		}
+	}()
+	return i
+}
+
+// start returns the context of an evaluation, canceled on Ctrl-C until done
+// is called.
+func (i *interrupter) start() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	i.mu.Lock()
+	i.cancel = cancel
+	i.mu.Unlock()
+	return ctx
+}
+
+func (i *interrupter) done() {
+	i.mu.Lock()
+	i.cancel()
+	i.cancel = nil
+	i.mu.Unlock()
+}
@@ -1,18 +1,33 @@
 package token
 
+import "fmt"
+
 type Type string
 
 type Token struct {
-	Type    Type
-	Literal string
+	Type     Type     `json:"type"`
+	Literal  string   `json:"literal"`
+	Position Position `json:"position"`
+}
+
+// Position is the location of a token in the source, both 1-based.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // never returned by the lexer, see lex.Lexer.Comments
 
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	STRING = "STRING"
 
 	// operators
 	ASSIGN   = "="
@@ -20,8 +35,15 @@ const (
 	MINUS    = "-"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 	BANG     = "!"
 
+	PLUSASSIGN     = "+="
+	MINUSASSIGN    = "-="
+	ASTERISKASSIGN = "*="
+	SLASHASSIGN    = "/="
+	PERCENTASSIGN  = "%="
+
 	EQ    = "=="
 	NOTEQ = "!="
 	LT    = "<"
@@ -30,18 +52,32 @@ const (
 	// delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	ARROW     = "=>"
+	DOT       = "."
+	DOTDOT    = ".."
+	ELLIPSIS  = "..."
 
-	LPAREN = "("
-	RPAREN = ")"
-	LBRACE = "{"
-	RBRACE = "}"
+	LPAREN   = "("
+	RPAREN   = ")"
+	LBRACE   = "{"
+	RBRACE   = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	// keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	MATCH    = "MATCH"
+	THROW    = "THROW"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 )
@@ -52,12 +88,26 @@ func TypeFromLiteral(literal string) Type {
 		return FUNCTION
 	case "let":
 		return LET
+	case "const":
+		return CONST
 	case "if":
 		return IF
 	case "else":
 		return ELSE
 	case "return":
 		return RETURN
+	case "while":
+		return WHILE
+	case "match":
+		return MATCH
+	case "throw":
+		return THROW
+	case "try":
+		return TRY
+	case "catch":
+		return CATCH
+	case "finally":
+		return FINALLY
 	case "true":
 		return TRUE
 	case "false":
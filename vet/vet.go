@@ -0,0 +1,453 @@
+// Package vet reports suspicious constructs of dog programs, such as
+// variables never used or code never reached, as `dog vet` does.
+package vet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/evaluate"
+	"github.com/maiyama18/dog/format"
+	"github.com/maiyama18/dog/lex"
+	"github.com/maiyama18/dog/parse"
+	"github.com/maiyama18/dog/token"
+)
+
+// Names of the checks, as reported in diagnostics and accepted by
+// suppression comments.
+const (
+	Unused            = "unused"      // local let bindings and parameters never used
+	Shadow            = "shadow"      // declarations hiding a name declared before them in an enclosing scope, or a builtin
+	Unreachable       = "unreachable" // statements following a return or a throw
+	SelfComparison    = "selfcompare" // comparisons of an expression to itself, as in `x == x`
+	ConstantCondition = "constcond"   // if expressions whose condition is made of literals only
+	Arity             = "arity"       // calls of function literals with a wrong number of arguments
+)
+
+// Diagnostic is a problem found by a check.
+type Diagnostic struct {
+	Position token.Position `json:"position"`
+	Check    string         `json:"check"`
+	Message  string         `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s (%s)", d.Position, d.Message, d.Check)
+}
+
+// Source checks the dog program src, returning an error if it does not
+// parse. Diagnostics are sorted by position.
+//
+// A comment `// vet:ignore` suppresses the diagnostics of the line it ends,
+// or of the following line if it is on a line of its own. It can be
+// restricted to some checks by listing their names, as in
+// `// vet:ignore unused shadow`.
+func Source(src []byte) ([]Diagnostic, error) {
+	p := parse.NewParser(lex.NewLexer(string(src)))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	ignored := ignoredChecks(program.Comments, strings.Split(string(src), "\n"))
+	var diagnostics []Diagnostic
+	for _, d := range Program(program) {
+		if !ignored(d) {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	return diagnostics, nil
+}
+
+// Program checks a resolved program, without suppression comments.
+// Diagnostics are sorted by position.
+//
+// The let bindings of the top level scope are not checked by Unused, as
+// hosts and the REPL may use them by name after the program runs.
+func Program(program *ast.Program) []Diagnostic {
+	c := newChecker()
+	ast.Inspect(program, c.visit)
+	c.checkUnused()
+	c.checkArity()
+
+	sort.SliceStable(c.diagnostics, func(i, j int) bool {
+		a, b := c.diagnostics[i].Position, c.diagnostics[j].Position
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return c.diagnostics
+}
+
+// ignoredChecks returns a function reporting whether a diagnostic is
+// suppressed by one of comments.
+func ignoredChecks(comments []token.Token, lines []string) func(Diagnostic) bool {
+	ignored := map[int][]string{} // checks ignored by line, "*" for all of them
+	for _, comment := range comments {
+		fields := strings.Fields(strings.TrimPrefix(comment.Literal, "//"))
+		if len(fields) == 0 || fields[0] != "vet:ignore" {
+			continue
+		}
+
+		line := comment.Position.Line
+		if line > len(lines) || strings.TrimSpace(string([]rune(lines[line-1])[:comment.Position.Column-1])) == "" {
+			line++
+		}
+		if len(fields) == 1 {
+			ignored[line] = append(ignored[line], "*")
+		} else {
+			ignored[line] = append(ignored[line], fields[1:]...)
+		}
+	}
+
+	return func(d Diagnostic) bool {
+		for _, check := range ignored[d.Position.Line] {
+			if check == "*" || check == d.Check {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// variable is a local variable, located by its slot in a scope, or a name
+// of the top level scope.
+type variable struct {
+	ident    *ast.Identifier // the declaration, nil until it is visited
+	kind     string          // "variable" or "parameter" for the ones checked by Unused
+	used     bool
+	assigned bool
+	function *ast.FunctionLiteral // the literal bound by let, if any
+}
+
+// scope holds the variables of a scope of the resolver by slot: a scope is
+// created for each call of a function, for each iteration of a while loop,
+// for each match arm and for each catch block.
+type scope struct {
+	slots []*variable
+}
+
+func (s *scope) variable(slot int) *variable {
+	for len(s.slots) <= slot {
+		s.slots = append(s.slots, nil)
+	}
+	if s.slots[slot] == nil {
+		s.slots[slot] = &variable{}
+	}
+	return s.slots[slot]
+}
+
+type call struct {
+	call     *ast.CallExpression
+	name     string
+	function *ast.FunctionLiteral
+	variable *variable // variable called by name, whose function is known at the end
+}
+
+// checker inspects a program in the order of the source, locating the
+// variables with the bindings set by the resolver. As a function can use
+// names declared after it, uses are recorded in variables which may not be
+// declared yet, and the checks depending on all of them run at the end.
+type checker struct {
+	scopes      []*scope // local scopes enclosing the node visited, innermost last
+	topLevel    map[string]*variable
+	locals      []*variable // in order of declaration
+	roles       map[*ast.Identifier]string
+	enters      map[ast.Node]bool // nodes starting a scope
+	leaves      map[ast.Node]bool // nodes ending a scope
+	nodes       []ast.Node        // nodes being inspected
+	calls       []call
+	diagnostics []Diagnostic
+}
+
+// Roles of the identifiers which are not uses of a variable, recorded when
+// visiting their parent. The kinds of variables declared are roles as well.
+const (
+	roleBinding = "binding" // names bound by match arms and catch blocks
+	roleTarget  = "target"  // variables assigned
+	roleMember  = "member"  // member names, as b in a.b
+)
+
+func newChecker() *checker {
+	return &checker{
+		topLevel: make(map[string]*variable),
+		roles:    make(map[*ast.Identifier]string),
+		enters:   make(map[ast.Node]bool),
+		leaves:   make(map[ast.Node]bool),
+	}
+}
+
+func (c *checker) report(pos token.Position, check, format string, args ...interface{}) {
+	c.diagnostics = append(c.diagnostics, Diagnostic{Position: pos, Check: check, Message: fmt.Sprintf(format, args...)})
+}
+
+// variable returns the variable ident declares or refers to.
+func (c *checker) variable(ident *ast.Identifier) *variable {
+	if ident.Binding == nil {
+		v, ok := c.topLevel[ident.Name]
+		if !ok {
+			v = &variable{}
+			c.topLevel[ident.Name] = v
+		}
+		return v
+	}
+	return c.scopes[len(c.scopes)-1-ident.Binding.Depth].variable(ident.Binding.Slot)
+}
+
+// declaring records the identifiers of pattern as declarations of kind.
+func (c *checker) declaring(pattern ast.Pattern, kind string) {
+	for _, ident := range identifiers(pattern) {
+		c.roles[ident] = kind
+	}
+}
+
+// scoped records that the nodes from first to last make a scope.
+func (c *checker) scoped(first, last ast.Node) {
+	c.enters[first] = true
+	c.leaves[last] = true
+}
+
+func (c *checker) visit(node ast.Node) bool {
+	if node == nil {
+		last := c.nodes[len(c.nodes)-1]
+		c.nodes = c.nodes[:len(c.nodes)-1]
+		if c.leaves[last] {
+			c.scopes = c.scopes[:len(c.scopes)-1]
+		}
+		return false
+	}
+	c.nodes = append(c.nodes, node)
+	if c.enters[node] {
+		c.scopes = append(c.scopes, &scope{})
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		c.statements(node.Statements)
+	case *ast.BlockStatement:
+		c.statements(node.Statements)
+	case *ast.LetStatement:
+		c.declaring(node.Pattern, "variable")
+		if function, ok := node.Expression.(*ast.FunctionLiteral); ok {
+			if ident, ok := node.Pattern.(*ast.Identifier); ok {
+				c.variable(ident).function = function
+			}
+		}
+	case *ast.WhileStatement:
+		c.scoped(node.Body, node.Body)
+	case *ast.FunctionLiteral:
+		c.scopes = append(c.scopes, &scope{})
+		c.leaves[node] = true
+		for _, param := range node.Parameters {
+			c.declaring(param.Pattern, "parameter")
+		}
+		if node.Rest != nil {
+			c.roles[node.Rest] = "parameter"
+		}
+	case *ast.TryExpression:
+		if node.Catch != nil {
+			c.scoped(node.CatchParameter, node.Catch)
+			c.roles[node.CatchParameter] = roleBinding
+		}
+	case *ast.MatchExpression:
+		for _, arm := range node.Arms {
+			c.scoped(arm.Pattern, arm.Body)
+			c.declaring(arm.Pattern, roleBinding)
+		}
+	case *ast.AssignExpression:
+		// assigning a variable does not use it
+		if ident, ok := node.Target.(*ast.Identifier); ok {
+			c.roles[ident] = roleTarget
+		}
+	case *ast.MemberExpression:
+		c.roles[node.Member] = roleMember
+	case *ast.Identifier:
+		switch role := c.roles[node]; role {
+		case "":
+			c.variable(node).used = true
+		case roleTarget:
+			c.variable(node).assigned = true
+		case roleMember:
+		default:
+			c.declare(node, role)
+		}
+	case *ast.InfixExpression:
+		c.checkSelfComparison(node)
+	case *ast.IfExpression:
+		if constant(node.Condition) {
+			c.report(node.Condition.Pos(), ConstantCondition, "condition %s is constant", format.Node(node.Condition))
+		}
+	case *ast.CallExpression:
+		c.recordCall(node)
+	}
+	return true
+}
+
+func (c *checker) statements(stmts []ast.Statement) {
+	for i, s := range stmts {
+		switch s.(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement:
+			if i+1 < len(stmts) {
+				c.report(stmts[i+1].Pos(), Unreachable, "unreachable code after %s", s.TokenLiteral())
+			}
+		}
+	}
+}
+
+// declare declares the variable of ident, which is local unless it has no
+// binding.
+func (c *checker) declare(ident *ast.Identifier, kind string) {
+	c.checkShadow(ident)
+	v := c.variable(ident)
+	v.ident, v.kind = ident, kind
+	if ident.Binding != nil {
+		c.locals = append(c.locals, v)
+	}
+}
+
+// checkShadow reports the declaration ident if it hides a name of an
+// enclosing scope declared before it, or a builtin.
+func (c *checker) checkShadow(ident *ast.Identifier) {
+	if ident.Binding != nil {
+		for i := len(c.scopes) - 2; i >= 0; i-- {
+			for _, v := range c.scopes[i].slots {
+				if v != nil && v.ident != nil && v.ident.Name == ident.Name {
+					c.report(ident.Pos(), Shadow, "%s shadows the declaration at %s", ident.Name, v.ident.Pos())
+					return
+				}
+			}
+		}
+		if v, ok := c.topLevel[ident.Name]; ok && v.ident != nil {
+			c.report(ident.Pos(), Shadow, "%s shadows the declaration at %s", ident.Name, v.ident.Pos())
+			return
+		}
+	}
+	if _, ok := evaluate.Builtin(ident.Name, nil); ok {
+		c.report(ident.Pos(), Shadow, "%s shadows the builtin %s", ident.Name, ident.Name)
+	}
+}
+
+func (c *checker) checkUnused() {
+	for _, v := range c.locals {
+		if (v.kind == "variable" || v.kind == "parameter") && !v.used && !strings.HasPrefix(v.ident.Name, "_") {
+			c.report(v.ident.Pos(), Unused, "%s %s is never used", v.kind, v.ident.Name)
+		}
+	}
+}
+
+// recordCall records a call of a function literal, directly or by the name
+// of a variable, to check its arguments once all of the declarations and
+// assignments are known.
+func (c *checker) recordCall(exp *ast.CallExpression) {
+	for _, arg := range exp.Arguments {
+		switch arg.(type) {
+		case *ast.SpreadExpression, *ast.NamedArgument:
+			return
+		}
+	}
+	switch function := exp.Function.(type) {
+	case *ast.FunctionLiteral:
+		c.calls = append(c.calls, call{call: exp, name: "function literal", function: function})
+	case *ast.Identifier:
+		c.calls = append(c.calls, call{call: exp, name: function.Name, variable: c.variable(function)})
+	}
+}
+
+// checkArity checks the recorded calls like the evaluator does, skipping
+// the variables not bound to a function literal or assigned another value.
+func (c *checker) checkArity() {
+	for _, call := range c.calls {
+		function := call.function
+		if call.variable != nil {
+			if call.variable.assigned {
+				continue
+			}
+			function = call.variable.function
+		}
+		if function == nil {
+			continue
+		}
+		required := 0
+		for _, p := range function.Parameters {
+			if p.Default == nil {
+				required++
+			}
+		}
+		max := len(function.Parameters)
+		n := len(call.call.Arguments)
+
+		switch {
+		case function.Rest != nil:
+			if n < required {
+				c.report(call.call.Pos(), Arity, "wrong number of arguments to %s: want at least %d, got %d", call.name, required, n)
+			}
+		case required == max:
+			if n != required {
+				c.report(call.call.Pos(), Arity, "wrong number of arguments to %s: want=%d, got=%d", call.name, required, n)
+			}
+		default:
+			if n < required || n > max {
+				c.report(call.call.Pos(), Arity, "wrong number of arguments to %s: want %d to %d, got %d", call.name, required, max, n)
+			}
+		}
+	}
+}
+
+func (c *checker) checkSelfComparison(exp *ast.InfixExpression) {
+	var result bool
+	switch exp.Operator {
+	case "==":
+		result = true
+	case "!=", "<", ">":
+		result = false
+	default:
+		return
+	}
+	if exp.Left.String() != exp.Right.String() || !pure(exp.Left) {
+		return
+	}
+	c.report(exp.Pos(), SelfComparison, "%s compares an expression to itself, always %t", format.Node(exp), result)
+}
+
+// pure reports whether exp has no side effects, so that evaluating it twice
+// gives the same value.
+func pure(exp ast.Expression) bool {
+	result := true
+	ast.Inspect(exp, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.CallExpression, *ast.AssignExpression, *ast.FunctionLiteral, *ast.MatchExpression, *ast.TryExpression, *ast.IfExpression:
+			result = false
+		}
+		return result
+	})
+	return result
+}
+
+// constant reports whether exp is made of literals and operators only.
+func constant(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral, *ast.BooleanLiteral, *ast.StringLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return constant(exp.Right)
+	case *ast.InfixExpression:
+		return constant(exp.Left) && constant(exp.Right)
+	default:
+		return false
+	}
+}
+
+// identifiers returns the identifiers bound by pattern.
+func identifiers(pattern ast.Pattern) []*ast.Identifier {
+	var idents []*ast.Identifier
+	ast.Inspect(pattern, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Identifier:
+			idents = append(idents, node)
+		case *ast.LiteralPattern:
+			return false
+		}
+		return true
+	})
+	return idents
+}
@@ -0,0 +1,143 @@
+package vet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: "let x = 1; x", want: nil},
+		{
+			// top level bindings are not checked, hosts may use them by name
+			input: "let x = 1;",
+			want:  nil,
+		},
+		{
+			input: "let f = fn(a, b, _c) { let y = a; let _z = 1; 0 }; f(1, 2, 3)",
+			want: []string{
+				"1:15: parameter b is never used (unused)",
+				"1:28: variable y is never used (unused)",
+			},
+		},
+		{
+			input: "let f = fn(...rest) { let [a, ..b] = [1]; a }; f()",
+			want: []string{
+				"1:15: parameter rest is never used (unused)",
+				"1:33: variable b is never used (unused)",
+			},
+		},
+		{
+			// assignments do not count as uses
+			input: "let f = fn() { let n = 0; n = 1; n += 2 }; f()",
+			want:  []string{"1:20: variable n is never used (unused)"},
+		},
+		{
+			// match arms and catch blocks bind names which need not be used
+			input: `let f = fn(x) { match (x) { [h, ..t] => h } }; try { f(1) } catch (e) { 0 }`,
+			want:  nil,
+		},
+		{
+			// functions see the names declared after them
+			input: "let f = fn() { let g = fn() { h() }; let h = fn() { 1 }; g() }; f()",
+			want:  nil,
+		},
+		{
+			input: "let x = 1; let f = fn(x) { let len = x; len }; f(x)",
+			want: []string{
+				"1:23: x shadows the declaration at 1:5 (shadow)",
+				"1:32: len shadows the builtin len (shadow)",
+			},
+		},
+		{
+			input: "let f = fn(y) { while (y > 0) { let y = 0; y } }; f(1)",
+			want:  []string{"1:37: y shadows the declaration at 1:12 (shadow)"},
+		},
+		{
+			// names declared after the shadowing point are not hidden
+			input: "let f = fn(x) { let g = fn(y) { y }; let y = g(x); y }; let x = f(1); x",
+			want:  nil,
+		},
+		{
+			input: "let f = fn() {\n  let g = fn() { let h = 1; h };\n  let h = g();\n  fn() { let h = 2; h }() + h\n}; f()",
+			want:  []string{"4:14: h shadows the declaration at 3:7 (shadow)"},
+		},
+		{
+			input: "let f = fn() { return 1; 2; 3 }; f(); throw 1; 4",
+			want: []string{
+				"1:26: unreachable code after return (unreachable)",
+				"1:48: unreachable code after throw (unreachable)",
+			},
+		},
+		{
+			input: "let x = 1; let y = [x == x, x[0] != x[0], x < x + 1, f() == f()]",
+			want: []string{
+				"1:23: x == x compares an expression to itself, always true (selfcompare)",
+				"1:34: x[0] != x[0] compares an expression to itself, always false (selfcompare)",
+			},
+		},
+		{
+			input: `if (true) { 1 }; if (1 + 2 > 3) { 1 }; if (!"a") { 1 }; let x = 1; if (x) { 1 }`,
+			want: []string{
+				"1:5: condition true is constant (constcond)",
+				"1:28: condition 1 + 2 > 3 is constant (constcond)",
+				`1:44: condition !"a" is constant (constcond)`,
+			},
+		},
+		{
+			input: "let f = fn(a, b) { a + b }; f(1); f(1, 2); f(1, 2, 3); f(...[1])",
+			want: []string{
+				"1:30: wrong number of arguments to f: want=2, got=1 (arity)",
+				"1:45: wrong number of arguments to f: want=2, got=3 (arity)",
+			},
+		},
+		{
+			input: "let f = fn(a, b = 1, ...c) { [a, b, c] }; f(); let g = fn(a, b = 1) { a + b }; g(1, 2, 3); fn(x) { x }()",
+			want: []string{
+				"1:44: wrong number of arguments to f: want at least 1, got 0 (arity)",
+				"1:81: wrong number of arguments to g: want 1 to 2, got 3 (arity)",
+				"1:103: wrong number of arguments to function literal: want=1, got=0 (arity)",
+			},
+		},
+		{
+			// reassigned variables may refer to other functions
+			input: "let f = fn(a) { a }; f = fn() { 0 }; f()",
+			want:  nil,
+		},
+		{
+			input: `let f = fn(a, b) { // vet:ignore
+  1 // vet:ignore unused
+};
+// vet:ignore arity
+f(1);
+f(
+  1); // vet:ignore shadow
+if (true) { 1 } // vet:ignore unused constcond`,
+			want: []string{"6:2: wrong number of arguments to f: want=2, got=1 (arity)"},
+		},
+	}
+
+	for _, tt := range tests {
+		diagnostics, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		var got []string
+		for _, d := range diagnostics {
+			got = append(got, d.String())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Source(%q) wrong.\nwant=%q\ngot= %q", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	if _, err := Source([]byte("let x = ;")); err == nil {
+		t.Errorf("Source returned no error for a syntax error")
+	}
+}
@@ -0,0 +1,110 @@
+package vm
+
+import (
+	"github.com/maiyama18/dog/compile"
+	"github.com/maiyama18/dog/object"
+)
+
+var (
+	definitions [256]*compile.Definition
+	offsets     [256][]int // of the operands of the instructions of each opcode
+	widths      [256]int   // of the operands of the instructions of each opcode
+)
+
+func init() {
+	for op := range definitions {
+		def, err := compile.Lookup(compile.Opcode(op))
+		if err != nil {
+			continue
+		}
+		definitions[op] = def
+		at := 1
+		for _, w := range def.OperandWidths {
+			offsets[op] = append(offsets[op], at)
+			at += w
+		}
+		widths[op] = at - 1
+	}
+}
+
+var infixOperators = map[compile.Opcode]string{
+	compile.OpAdd:         "+",
+	compile.OpSub:         "-",
+	compile.OpMul:         "*",
+	compile.OpDiv:         "/",
+	compile.OpMod:         "%",
+	compile.OpEqual:       "==",
+	compile.OpNotEqual:    "!=",
+	compile.OpGreaterThan: ">",
+	compile.OpLessThan:    "<",
+}
+
+// compoundOpcodes are the opcodes of the operators of compound assignments,
+// indexed by the operand of OpCompound.
+var compoundOpcodes = []compile.Opcode{compile.OpAdd, compile.OpSub, compile.OpMul, compile.OpDiv, compile.OpMod}
+
+// integerInfix applies the operator of op to integers as the evaluator does,
+// without looking up the operator by name. It returns nil if left or right
+// is not an integer, or for a division by zero, leaving the error to the
+// evaluator.
+func integerInfix(op compile.Opcode, left, right object.Object) object.Object {
+	l, ok := left.(*object.Integer)
+	if !ok {
+		return nil
+	}
+	r, ok := right.(*object.Integer)
+	if !ok {
+		return nil
+	}
+
+	switch op {
+	case compile.OpAdd:
+		return object.NewInteger(l.Value + r.Value)
+	case compile.OpSub:
+		return object.NewInteger(l.Value - r.Value)
+	case compile.OpMul:
+		return object.NewInteger(l.Value * r.Value)
+	case compile.OpDiv:
+		if r.Value == 0 {
+			return nil
+		}
+		return object.NewInteger(l.Value / r.Value)
+	case compile.OpMod:
+		if r.Value == 0 {
+			return nil
+		}
+		return object.NewInteger(l.Value % r.Value)
+	case compile.OpEqual:
+		return boolean(l.Value == r.Value)
+	case compile.OpNotEqual:
+		return boolean(l.Value != r.Value)
+	case compile.OpGreaterThan:
+		return boolean(l.Value > r.Value)
+	case compile.OpLessThan:
+		return boolean(l.Value < r.Value)
+	default:
+		return nil
+	}
+}
+
+func boolean(b bool) object.Object {
+	if b {
+		return object.TRUE
+	}
+	return object.FALSE
+}
+
+// uint16At decodes the two byte operand at offset at.
+func uint16At(ins compile.Instructions, at int) int {
+	return int(ins[at])<<8 | int(ins[at+1])
+}
+
+// operand decodes the ith operand of the instruction at ip.
+func operand(ins compile.Instructions, ip, i int) int {
+	op := ins[ip]
+	at := ip + offsets[op][i]
+	if definitions[op].OperandWidths[i] == 1 {
+		return int(ins[at])
+	}
+	return compile.ReadUint16(ins[at:])
+}
@@ -0,0 +1,85 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/maiyama18/dog/compile"
+	"github.com/maiyama18/dog/evaluate"
+	"github.com/maiyama18/dog/object"
+)
+
+// bindFunc binds a name pattern to the part of the value it matched.
+type bindFunc func(pattern *compile.Pattern, value object.Object) *object.Error
+
+// destructure matches value against pattern as the evaluator does, calling
+// bind for every name of the pattern. If value does not have the shape of
+// pattern, it returns a description of the mismatch.
+func destructure(pattern *compile.Pattern, value object.Object, bind bindFunc) (string, *object.Error) {
+	switch pattern.Kind {
+	case compile.WildcardPattern:
+		return "", nil
+	case compile.NamePattern:
+		return "", bind(pattern, value)
+	case compile.LiteralPattern:
+		if pattern.Value.Type() != value.Type() || evaluate.Infix("==", pattern.Value, value) != object.TRUE {
+			return fmt.Sprintf("expected %s, got %s", pattern.Value.Inspect(), value.Inspect()), nil
+		}
+		return "", nil
+	case compile.ArrayPattern:
+		return destructureArray(pattern, value, bind)
+	case compile.HashPattern:
+		return destructureHash(pattern, value, bind)
+	default:
+		return "", object.NewError("unknown pattern: %s", pattern.Source)
+	}
+}
+
+func destructureArray(pattern *compile.Pattern, value object.Object, bind bindFunc) (string, *object.Error) {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return fmt.Sprintf("array pattern %s cannot match %s", pattern.Source, value.Type()), nil
+	}
+
+	if pattern.Rest == nil && len(array.Elements) != len(pattern.Elements) {
+		return fmt.Sprintf("array pattern %s expects %d elements, got %d", pattern.Source, len(pattern.Elements), len(array.Elements)), nil
+	}
+	if len(array.Elements) < len(pattern.Elements) {
+		return fmt.Sprintf("array pattern %s expects at least %d elements, got %d", pattern.Source, len(pattern.Elements), len(array.Elements)), nil
+	}
+
+	for i, elem := range pattern.Elements {
+		mismatch, err := destructure(elem, array.Elements[i], bind)
+		if mismatch != "" || err != nil {
+			return mismatch, err
+		}
+	}
+
+	if pattern.Rest != nil {
+		rest := make([]object.Object, len(array.Elements)-len(pattern.Elements))
+		copy(rest, array.Elements[len(pattern.Elements):])
+		if err := bind(pattern.Rest, object.NewArray(rest)); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+func destructureHash(pattern *compile.Pattern, value object.Object, bind bindFunc) (string, *object.Error) {
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return fmt.Sprintf("hash pattern %s cannot match %s", pattern.Source, value.Type()), nil
+	}
+
+	for i, key := range pattern.Keys {
+		v, ok := hash.Get(key)
+		if !ok {
+			return fmt.Sprintf("hash pattern %s expects key %s", pattern.Source, pattern.KeySources[i]), nil
+		}
+
+		mismatch, err := destructure(pattern.Elements[i], v, bind)
+		if mismatch != "" || err != nil {
+			return mismatch, err
+		}
+	}
+	return "", nil
+}
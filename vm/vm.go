@@ -0,0 +1,744 @@
+// Package vm runs bytecode compiled by the compile package on an operand
+// stack with call frames.
+//
+// The VM produces the same results and errors as the evaluator, whose
+// operators and builtins it shares. It does not meter fuel or memory: the
+// WithFuel and WithMemory options of the evaluator have no counterpart here.
+package vm
+
+import (
+	"context"
+
+	"github.com/maiyama18/dog/compile"
+	"github.com/maiyama18/dog/evaluate"
+	"github.com/maiyama18/dog/object"
+	"github.com/maiyama18/dog/token"
+)
+
+// Closure is a compiled function closed over the environment it was created in.
+type Closure struct {
+	Function *compile.Function
+	Env      *object.Environment
+	program  *program // the function was compiled in
+}
+
+func (c *Closure) Type() object.Type { return object.FunctionType }
+func (c *Closure) Inspect() string   { return c.Function.Source }
+
+// frame is the state of a running unit of code: a call of a closure, or the
+// top level or a try block, which run in the scope of their caller. The
+// arguments of a call and its stack slots are on the operand stack, above the
+// function called.
+type frame struct {
+	fn       *compile.Function
+	ip       int
+	env      *object.Environment
+	program  *program
+	base     int            // height of the operand stack when the frame was entered, below the function of a call
+	call     bool           // whether the frame is a call of a closure
+	args     int            // index of the first argument of a call on the stack
+	argc     int            // number of arguments of a call
+	slots    int            // index of the first stack slot of the call on the stack
+	position token.Position // of the call
+}
+
+// program is the bytecode of a run, which its closures keep running in later
+// runs, with the names of the top level it looks up by their index in the
+// bytecode. A name is resolved to its cell in the environment of the run once
+// bound there, and else to a builtin made for the VM running the code.
+type program struct {
+	constants []object.Object
+	env       *object.Environment
+	names     []string
+	cells     []*object.Cell
+	vm        *VM
+	builtins  []*object.Builtin // made for vm
+}
+
+// completion is the outcome of running a unit of code.
+type completion struct {
+	value    object.Object // an *object.Error if the unit raised it
+	returned bool          // whether the unit ran a return statement
+}
+
+// VM holds the state of a single run.
+type VM struct {
+	ctx      context.Context
+	stack    []object.Object
+	frames   []frame
+	calls    []object.Frame // call stack, most recent call last
+	maxDepth int
+}
+
+// Option configures a run.
+type Option func(*VM)
+
+// WithMaxDepth limits the depth of the call stack, like the option of the
+// same name of the evaluator. A non-positive depth removes the limit.
+func WithMaxDepth(depth int) Option {
+	return func(vm *VM) {
+		vm.maxDepth = depth
+	}
+}
+
+// Run runs bytecode in env until ctx is done, returning its value or the
+// *object.Error it raised.
+func Run(ctx context.Context, bytecode *compile.Bytecode, env *object.Environment, opts ...Option) object.Object {
+	vm := &VM{ctx: ctx, maxDepth: evaluate.DefaultMaxDepth}
+	for _, opt := range opts {
+		opt(vm)
+	}
+	prog := &program{
+		constants: bytecode.Constants,
+		env:       env,
+		names:     bytecode.Globals,
+		cells:     make([]*object.Cell, len(bytecode.Globals)),
+	}
+	return vm.runUnit(bytecode.Main, env, prog, 0).value
+}
+
+// Frames returns the call stack of the run.
+func (vm *VM) Frames() []object.Frame {
+	return vm.calls
+}
+
+// Consume does nothing, as the VM does not meter fuel.
+func (vm *VM) Consume(cost int64) *object.Error {
+	return nil
+}
+
+// Allocate does nothing, as the VM does not meter memory.
+func (vm *VM) Allocate(size int64) *object.Error {
+	return nil
+}
+
+// runUnit runs fn, a unit of code other than a function, in env with the
+// stack slots of the call running it from slots.
+func (vm *VM) runUnit(fn *compile.Function, env *object.Environment, prog *program, slots int) completion {
+	vm.frames = append(vm.frames, frame{fn: fn, env: env, program: prog, base: len(vm.stack), slots: slots})
+	return vm.run(len(vm.frames) - 1)
+}
+
+// run runs the frames above stop until the frame at stop ends, returning how it ended.
+func (vm *VM) run(stop int) completion {
+	for {
+		// f is not used once a frame is pushed, which may move the frames
+		f := &vm.frames[len(vm.frames)-1]
+		fn := f.fn
+		constants := f.program.constants
+		ins := fn.Instructions
+		ip := f.ip
+		op := compile.Opcode(ins[ip])
+		f.ip += 1 + widths[op]
+
+		var result object.Object // error raised by the instruction, if any
+		switch op {
+		case compile.OpConstant:
+			vm.stack = append(vm.stack, constants[uint16At(ins, ip+1)])
+		case compile.OpNull:
+			vm.push(object.NULL)
+		case compile.OpTrue:
+			vm.push(object.TRUE)
+		case compile.OpFalse:
+			vm.push(object.FALSE)
+		case compile.OpPop:
+			vm.pop()
+
+		case compile.OpAdd, compile.OpSub, compile.OpMul, compile.OpDiv, compile.OpMod,
+			compile.OpEqual, compile.OpNotEqual, compile.OpGreaterThan, compile.OpLessThan:
+			n := len(vm.stack)
+			if value := integerInfix(op, vm.stack[n-2], vm.stack[n-1]); value != nil {
+				vm.stack[n-2] = value
+				vm.stack = vm.stack[:n-1]
+				break
+			}
+			right := vm.pop()
+			left := vm.pop()
+			result = vm.push(evaluate.Infix(infixOperators[op], left, right))
+		case compile.OpMinus:
+			result = vm.push(evaluate.Prefix("-", vm.pop()))
+		case compile.OpBang:
+			n := len(vm.stack)
+			vm.stack[n-1] = boolean(!evaluate.Truthy(vm.stack[n-1]))
+
+		case compile.OpGetName:
+			result = vm.push(vm.getGlobal(f.program, uint16At(ins, ip+1)))
+		case compile.OpCheckAssignable:
+			if err := f.env.CheckAssignable(f.program.names[operand(ins, ip, 0)]); err != nil {
+				result = object.NewError("%s", err)
+			}
+		case compile.OpAssign:
+			if err := f.env.Assign(f.program.names[operand(ins, ip, 0)], vm.peek()); err != nil {
+				result = object.NewError("%s", err)
+			}
+		case compile.OpGetLocal:
+			if value, ok := f.env.GetSlot(operand(ins, ip, 1), operand(ins, ip, 2)); ok {
+				vm.push(value)
+			} else {
+				result = object.NewError("identifier not found: %s", name(constants, ins, ip, 0))
+			}
+		case compile.OpLocalTarget:
+			if current, ok := f.env.GetSlot(operand(ins, ip, 1), operand(ins, ip, 2)); !ok {
+				result = object.NewError("assignment to undeclared identifier: %s", name(constants, ins, ip, 0))
+			} else if operand(ins, ip, 3) == 1 {
+				vm.push(current)
+			}
+		case compile.OpSetLocal:
+			f.env.SetSlot(operand(ins, ip, 1), operand(ins, ip, 2), vm.peek())
+		case compile.OpGetSlot:
+			if value := vm.stack[f.slots+uint16At(ins, ip+3)]; value != nil {
+				vm.stack = append(vm.stack, value)
+			} else {
+				result = object.NewError("identifier not found: %s", name(constants, ins, ip, 0))
+			}
+		case compile.OpSlotTarget:
+			if current := vm.stack[f.slots+operand(ins, ip, 1)]; current == nil {
+				result = object.NewError("assignment to undeclared identifier: %s", name(constants, ins, ip, 0))
+			} else if operand(ins, ip, 2) == 1 {
+				vm.push(current)
+			}
+		case compile.OpSetSlot:
+			vm.stack[f.slots+uint16At(ins, ip+3)] = vm.peek()
+		case compile.OpLet, compile.OpConst:
+			result = vm.define(f.env, f.slots, pattern(constants, ins, ip, 0), vm.pop(), op == compile.OpConst)
+		case compile.OpBindArgument:
+			if err := vm.bindArgument(f, pattern(constants, ins, ip, 0), vm.pop(), operand(ins, ip, 1)); err != nil {
+				return vm.raise(err, stop)
+			}
+		case compile.OpArgument:
+			if i := operand(ins, ip, 0); i < f.argc && vm.stack[f.args+i] != nil {
+				vm.push(vm.stack[f.args+i])
+				f.ip = operand(ins, ip, 1)
+			}
+		case compile.OpRest:
+			var rest []object.Object
+			if n := operand(ins, ip, 0); f.argc > n {
+				rest = append(rest, vm.stack[f.args+n:f.args+f.argc]...)
+			}
+			vm.push(object.NewArray(rest))
+
+		case compile.OpArray:
+			n := operand(ins, ip, 0)
+			var elements []object.Object
+			if n > 0 {
+				elements = append(elements, vm.stack[len(vm.stack)-n:]...)
+			}
+			vm.stack = vm.stack[:len(vm.stack)-n]
+			vm.push(object.NewArray(elements))
+		case compile.OpAppend:
+			value := vm.pop()
+			array := vm.peek().(*object.Array)
+			array.Elements = append(array.Elements, value)
+		case compile.OpExtend:
+			value := vm.pop()
+			spread, ok := value.(*object.Array)
+			if !ok {
+				result = object.NewError("cannot spread %s, want ARRAY", value.Type())
+				break
+			}
+			array := vm.peek().(*object.Array)
+			array.Elements = append(array.Elements, spread.Elements...)
+		case compile.OpHash:
+			result = vm.push(vm.hash(operand(ins, ip, 0)))
+		case compile.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			result = vm.push(evaluate.Index(left, index))
+
+		case compile.OpIndexTarget:
+			result = vm.indexTarget(operand(ins, ip, 0) == 1)
+		case compile.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			result = vm.setIndex(left, index, value)
+		case compile.OpMember:
+			result = vm.push(evaluate.Member(vm.pop(), name(constants, ins, ip, 0)))
+		case compile.OpMemberTarget:
+			obj := vm.peek()
+			if _, ok := obj.(*object.HostObject); !ok {
+				result = object.NewError("member assignment not supported: %s", obj.Type())
+			} else if operand(ins, ip, 1) == 1 {
+				result = vm.push(evaluate.Member(obj, name(constants, ins, ip, 0)))
+			}
+		case compile.OpSetMember:
+			value := vm.pop()
+			host := vm.pop().(*object.HostObject)
+			if err := host.SetMember(name(constants, ins, ip, 0), value); err != nil {
+				result = object.NewError("%s", err)
+			} else {
+				vm.push(value)
+			}
+		case compile.OpCompound:
+			n := len(vm.stack)
+			if value := integerInfix(compoundOpcodes[operand(ins, ip, 0)], vm.stack[n-2], vm.stack[n-1]); value != nil {
+				vm.stack[n-2] = value
+				vm.stack = vm.stack[:n-1]
+				break
+			}
+			value := vm.pop()
+			current := vm.pop()
+			operator := compile.CompoundOperators[operand(ins, ip, 0)]
+			combined := evaluate.Infix(operator, current, value)
+			if combined == object.NULL {
+				result = object.NewError("unsupported operands for %s=: %s and %s", operator, current.Type(), value.Type())
+			} else {
+				result = vm.push(combined)
+			}
+
+		case compile.OpJump:
+			f.ip = uint16At(ins, ip+1)
+		case compile.OpJumpIfFalse:
+			if !evaluate.Truthy(vm.pop()) {
+				f.ip = uint16At(ins, ip+1)
+			}
+		case compile.OpIterate:
+			if err := vm.checkCanceled(); err != nil {
+				result = err
+			}
+		case compile.OpEnterScope:
+			f.env = object.NewLocalEnvironment(f.env, operand(ins, ip, 0))
+		case compile.OpLeaveScope:
+			f.env = f.env.Outer()
+		case compile.OpMatch:
+			env, slots := f.env, f.slots
+			bind := func(p *compile.Pattern, value object.Object) *object.Error {
+				vm.bindName(env, slots, p, value)
+				return nil
+			}
+			if mismatch, err := destructure(pattern(constants, ins, ip, 0), vm.peek(), bind); err != nil {
+				result = err
+			} else if mismatch != "" {
+				f.ip = operand(ins, ip, 1)
+			}
+		case compile.OpNoMatch:
+			result = object.NewError("no match arm matched value: %s", vm.pop().Inspect())
+
+		case compile.OpClosure:
+			vm.push(&Closure{Function: constants[operand(ins, ip, 0)].(*compile.Function), Env: f.env, program: f.program})
+		case compile.OpCall:
+			result = vm.call(f, uint16At(ins, ip+1), int(ins[ip+3]), fn.Position(ip))
+		case compile.OpReturn, compile.OpEnd:
+			if c, done := vm.exit(vm.pop(), op == compile.OpReturn, stop); done {
+				return c
+			}
+		case compile.OpThrow:
+			result = evaluate.Throw(vm.pop())
+		case compile.OpTry:
+			c := vm.try(f, constants[operand(ins, ip, 0)].(*compile.Try))
+			switch {
+			case isError(c.value):
+				return vm.raise(c.value.(*object.Error), stop)
+			case c.returned:
+				if c, done := vm.exit(c.value, true, stop); done {
+					return c
+				}
+			default:
+				vm.push(c.value)
+			}
+		}
+
+		if err, ok := result.(*object.Error); ok {
+			if err.Position == (token.Position{}) {
+				err.Position = fn.Position(ip)
+			}
+			if err.Stack == nil && len(vm.calls) > 0 {
+				err.Stack = append([]object.Frame(nil), vm.calls...)
+			}
+			return vm.raise(err, stop)
+		}
+	}
+}
+
+// raise ends the frames down to the one at stop with err.
+func (vm *VM) raise(err *object.Error, stop int) completion {
+	for len(vm.frames) > stop {
+		vm.popFrame()
+	}
+	return completion{value: err}
+}
+
+// exit ends the current frame with value, returning from the function if
+// returned. It reports whether the run ended, with the completion of the
+// frame at stop.
+func (vm *VM) exit(value object.Object, returned bool, stop int) (completion, bool) {
+	f := vm.popFrame()
+	if f.call {
+		vm.push(value)
+		return completion{}, false
+	}
+	// frames other than calls are only entered by run
+	return completion{value: value, returned: returned}, true
+}
+
+func (vm *VM) popFrame() frame {
+	f := vm.frames[len(vm.frames)-1]
+	vm.frames = vm.frames[:len(vm.frames)-1]
+	vm.stack = vm.stack[:f.base]
+	if f.call {
+		vm.calls = vm.calls[:len(vm.calls)-1]
+	}
+	return f
+}
+
+// call calls the function below the arguments on the stack, as the evaluator
+// applies a function. A call of a closure pushes a frame for it, or replaces
+// the frame of the current call if it is a tail call.
+func (vm *VM) call(f *frame, argc, flags int, pos token.Position) object.Object {
+	var named *object.Hash
+	if flags&compile.CallNamed != 0 {
+		named = vm.pop().(*object.Hash)
+	}
+	if flags&compile.CallSpread != 0 {
+		elements := vm.pop().(*object.Array).Elements
+		vm.stack = append(vm.stack, elements...)
+		argc = len(elements)
+	}
+	callee := len(vm.stack) - argc - 1
+	function := vm.stack[callee]
+	if named != nil {
+		args, err := namedArguments(function, append([]object.Object(nil), vm.stack[callee+1:]...), named)
+		if err != nil {
+			return err
+		}
+		vm.stack = append(vm.stack[:callee+1], args...)
+		argc = len(args)
+	}
+
+	tail := flags&compile.CallTail != 0 && f.call
+	if closure, ok := function.(*Closure); ok && tail {
+		if err := checkArity(closure.Function, argc); err != nil {
+			return err
+		}
+		if err := vm.checkCanceled(); err != nil {
+			return err
+		}
+		copy(vm.stack[f.base:], vm.stack[callee:])
+		vm.stack = vm.stack[:f.base+1+argc]
+		replaced := vm.calls[len(vm.calls)-1]
+		vm.calls[len(vm.calls)-1] = newFrame(closure.Function.Name, pos)
+		vm.calls[len(vm.calls)-1].Elided = replaced.Elided + 1
+		vm.enter(f, closure)
+		return nil
+	}
+
+	if err := vm.checkCanceled(); err != nil {
+		return err
+	}
+	if vm.maxDepth > 0 && len(vm.calls) >= vm.maxDepth {
+		return object.NewError("maximum recursion depth exceeded (%d)", vm.maxDepth)
+	}
+
+	switch function := function.(type) {
+	case *Closure:
+		if err := checkArity(function.Function, argc); err != nil {
+			return err
+		}
+		vm.calls = append(vm.calls, newFrame(function.Function.Name, pos))
+		vm.frames = append(vm.frames, frame{base: callee, call: true, position: pos})
+		vm.enter(&vm.frames[len(vm.frames)-1], function)
+		return nil
+	case *object.Builtin:
+		args := append([]object.Object(nil), vm.stack[callee+1:]...)
+		vm.stack = vm.stack[:callee]
+		vm.calls = append(vm.calls, newFrame(function.Name, pos))
+		result := function.Fn(args...)
+		vm.calls = vm.calls[:len(vm.calls)-1]
+		return vm.push(result)
+	default:
+		return object.NewError("not a function: %s", function.Type())
+	}
+}
+
+// enter starts the call of closure in f, whose function and arguments are on
+// the stack from the base of f. The scopes of the call are either stack
+// slots above the arguments or an environment enclosed by the one of closure.
+func (vm *VM) enter(f *frame, closure *Closure) {
+	fn := closure.Function
+	f.fn, f.ip, f.program = fn, 0, closure.program
+	f.args, f.argc, f.slots = f.base+1, len(vm.stack)-f.base-1, len(vm.stack)
+	if !fn.Stack {
+		f.env = object.NewLocalEnvironment(closure.Env, fn.Slots)
+		return
+	}
+	f.env = closure.Env
+	for i := 0; i < fn.Slots; i++ {
+		vm.stack = append(vm.stack, nil)
+	}
+}
+
+// namedArguments places the named arguments of a call of function at the
+// positions of their parameters, after args.
+func namedArguments(function object.Object, args []object.Object, named *object.Hash) ([]object.Object, *object.Error) {
+	switch function := function.(type) {
+	case *Closure:
+		var names []string
+		var values []object.Object
+		for _, pair := range named.Pairs() {
+			names = append(names, pair.Key.(*object.String).Value)
+			values = append(values, pair.Value)
+		}
+		return evaluate.NamedArguments(function.Function.Names, function.Function.Required, args, names, values)
+	case *object.Builtin:
+		return nil, object.NewError("%s does not take named arguments", function.Name)
+	default:
+		return nil, object.NewError("not a function: %s", function.Type())
+	}
+}
+
+func newFrame(name string, pos token.Position) object.Frame {
+	if name == "" {
+		name = "<anonymous>"
+	}
+	return object.Frame{Function: name, Position: pos}
+}
+
+// bindArgument binds the argument of the ith parameter of the current call.
+// A mismatch is attributed to the call, outside the called function.
+func (vm *VM) bindArgument(f *frame, pattern *compile.Pattern, arg object.Object, i int) *object.Error {
+	bind := func(p *compile.Pattern, value object.Object) *object.Error {
+		vm.bindName(f.env, f.slots, p, value)
+		return nil
+	}
+	mismatch, err := destructure(pattern, arg, bind)
+	if err == nil && mismatch == "" {
+		return nil
+	}
+	if err == nil {
+		err = object.NewError("cannot destructure argument %d: %s", i+1, mismatch)
+	}
+	if err.Position == (token.Position{}) {
+		err.Position = f.position
+	}
+	if err.Stack == nil && len(vm.calls) > 1 {
+		err.Stack = append([]object.Frame(nil), vm.calls[:len(vm.calls)-1]...)
+	}
+	return err
+}
+
+// try runs a try expression as the evaluator does.
+func (vm *VM) try(f *frame, try *compile.Try) completion {
+	// the blocks push frames, moving f
+	env, prog, slots := f.env, f.program, f.slots
+	result := vm.runUnit(try.Block, env, prog, slots)
+	if evaluate.Uncatchable(result.value) {
+		return result
+	}
+
+	if err, ok := result.value.(*object.Error); ok && try.Catch != nil {
+		catchEnv := env
+		if !try.CatchParameter.Stack {
+			catchEnv = object.NewLocalEnvironment(env, try.CatchSlots)
+		}
+		vm.bindName(catchEnv, slots, try.CatchParameter, &object.CaughtError{Error: err})
+		result = vm.runUnit(try.Catch, catchEnv, prog, slots)
+		if evaluate.Uncatchable(result.value) {
+			return result
+		}
+	}
+
+	if try.Finally != nil {
+		finally := vm.runUnit(try.Finally, env, prog, slots)
+		if finally.returned || isError(finally.value) {
+			return finally
+		}
+	}
+	return result
+}
+
+func (vm *VM) define(env *object.Environment, slots int, pattern *compile.Pattern, value object.Object, constant bool) object.Object {
+	mismatch, err := destructure(pattern, value, func(p *compile.Pattern, value object.Object) *object.Error {
+		if p.Local || p.Stack {
+			vm.bindName(env, slots, p, value)
+			return nil
+		}
+
+		var err error
+		if constant {
+			err = env.DefineConstant(p.Name, value, p.Position)
+		} else {
+			err = env.Define(p.Name, value)
+		}
+		if err != nil {
+			return object.NewError("%s", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if mismatch != "" {
+		return object.NewError("cannot destructure %s: %s", value.Inspect(), mismatch)
+	}
+	return nil
+}
+
+// bindName binds the name of p to value in env, or in its slot of env or of
+// the stack slots from slots if it has one.
+func (vm *VM) bindName(env *object.Environment, slots int, p *compile.Pattern, value object.Object) {
+	switch {
+	case p.Stack:
+		vm.stack[slots+p.Slot] = value
+	case p.Local:
+		env.SetSlot(0, p.Slot, value)
+	default:
+		env.Set(p.Name, value)
+	}
+}
+
+// getGlobal returns the value bound to the ith global of prog, or else the
+// builtin of its name.
+func (vm *VM) getGlobal(prog *program, i int) object.Object {
+	if cell := prog.cells[i]; cell != nil {
+		return cell.Value()
+	}
+	name := prog.names[i]
+	if cell := prog.env.Cell(name); cell != nil {
+		prog.cells[i] = cell
+		return cell.Value()
+	}
+	if value, ok := prog.env.Get(name); ok {
+		return value
+	}
+
+	if prog.vm != vm {
+		prog.vm, prog.builtins = vm, make([]*object.Builtin, len(prog.names))
+	}
+	if prog.builtins[i] == nil {
+		if builtin, ok := evaluate.Builtin(name, vm); ok {
+			prog.builtins[i] = builtin
+		}
+	}
+	if prog.builtins[i] != nil {
+		return prog.builtins[i]
+	}
+	return object.NewError("identifier not found: %s", name)
+}
+
+// hash pops n key-value pairs into a hash.
+func (vm *VM) hash(n int) object.Object {
+	pairs := vm.stack[len(vm.stack)-2*n:]
+	vm.stack = vm.stack[:len(vm.stack)-2*n]
+
+	hash := object.NewHash()
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(object.Hashable)
+		if !ok {
+			return object.NewError("unusable as hash key: %s", pairs[i].Type())
+		}
+		hash.Set(key, pairs[i+1])
+	}
+	return hash
+}
+
+// indexTarget checks the object and index on the stack as the target of an
+// index assignment, pushing the current value for a compound assignment.
+func (vm *VM) indexTarget(compound bool) object.Object {
+	index := vm.stack[len(vm.stack)-1]
+	left := vm.stack[len(vm.stack)-2]
+
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return object.NewError("array index must be INTEGER, got %s", index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return object.NewError("array index out of range: index=%d, length=%d", i.Value, len(left.Elements))
+		}
+		if compound {
+			vm.push(left.Elements[i.Value])
+		}
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return object.NewError("unusable as hash key: %s", index.Type())
+		}
+		if compound {
+			current, ok := left.Get(key)
+			if !ok {
+				return object.NewError("key not found in hash: %s", key.Inspect())
+			}
+			vm.push(current)
+		}
+	default:
+		return object.NewError("index assignment not supported: %s", left.Type())
+	}
+	return nil
+}
+
+// setIndex assigns value to the element of left at index, both checked by indexTarget.
+func (vm *VM) setIndex(left, index, value object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		i := index.(*object.Integer).Value
+		if i >= int64(len(left.Elements)) {
+			return object.NewError("array index out of range: index=%d, length=%d", i, len(left.Elements))
+		}
+		left.Elements[i] = value
+	case *object.Hash:
+		left.Set(index.(object.Hashable), value)
+	}
+	vm.push(value)
+	return nil
+}
+
+// checkCanceled returns an error if the context of the run is done.
+func (vm *VM) checkCanceled() *object.Error {
+	if err := vm.ctx.Err(); err != nil {
+		return &object.Error{Kind: object.CanceledErrorKind, Message: err.Error()}
+	}
+	return nil
+}
+
+// checkArity returns an error describing the expected number of arguments if
+// fn cannot be called with n arguments.
+func checkArity(fn *compile.Function, n int) *object.Error {
+	switch {
+	case fn.Rest:
+		if n < fn.Required {
+			return object.NewError("wrong number of arguments: want at least %d, got %d", fn.Required, n)
+		}
+	case fn.Required == fn.Parameters:
+		if n != fn.Required {
+			return object.NewError("wrong number of arguments: want=%d, got=%d", fn.Required, n)
+		}
+	default:
+		if n < fn.Required || n > fn.Parameters {
+			return object.NewError("wrong number of arguments: want %d to %d, got %d", fn.Required, fn.Parameters, n)
+		}
+	}
+	return nil
+}
+
+// push pushes obj, unless it is an error, which it returns to be raised instead.
+func (vm *VM) push(obj object.Object) object.Object {
+	if isError(obj) {
+		return obj
+	}
+	vm.stack = append(vm.stack, obj)
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	obj := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return obj
+}
+
+func (vm *VM) peek() object.Object {
+	return vm.stack[len(vm.stack)-1]
+}
+
+func name(constants []object.Object, ins compile.Instructions, ip, i int) string {
+	return constants[operand(ins, ip, i)].(*object.String).Value
+}
+
+func pattern(constants []object.Object, ins compile.Instructions, ip, i int) *compile.Pattern {
+	return constants[operand(ins, ip, i)].(*compile.Pattern)
+}
+
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
@@ -0,0 +1,258 @@
+package vm
+
+import (
+	"context"
+	goast "go/ast"
+	"go/parser"
+	gotoken "go/token"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/maiyama18/dog/ast"
+	"github.com/maiyama18/dog/compile"
+	"github.com/maiyama18/dog/evaluate"
+	"github.com/maiyama18/dog/lex"
+	"github.com/maiyama18/dog/object"
+	"github.com/maiyama18/dog/parse"
+)
+
+// TestRunLikeEval runs the inputs of the tests of the evaluator on both
+// engines, expecting the same results and errors. Inputs of the tests of fuel,
+// memory and cancellation are skipped: the VM does not meter the former, and
+// the point at which the latter stops differs between the engines.
+func TestRunLikeEval(t *testing.T) {
+	skipped := map[string]bool{
+		"TestEvalContext":     true,
+		"TestEvalFuel":        true,
+		"TestEvalMemoryLimit": true,
+		"TestEvalMemoryUsage": true,
+	}
+
+	for _, input := range evaluateTestInputs(t, skipped) {
+		t.Run(input, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			want := evalInput(t, input)
+			got := runInput(t, ctx, input)
+			testSameResult(t, got, want)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "let f = fn(x) { x * 2 }; f", want: "fn (x) { (x * 2); }"},
+		{input: "let i = 0; while (i < 3) { let j = i; i = j + 1 }; i", want: "3"},
+		{input: "let f = fn() { let i = 0; while (true) { i += 1; if (i == 4) { return i } } }; f()", want: "4"},
+		{input: "let f = fn() { try { try { return 1 } finally { 2 } } finally { 3 } }; f()", want: "1"},
+		{input: "let f = fn(n) { match (n) { 0 => 0, _ => try { f(n - 1) } catch (e) { 0 } } }; f(3)", want: "0"},
+		{input: `let h = {"a": [1, 2]}; h["a"][1] += 5; h["a"]`, want: "[1, 7]"},
+		{input: "let f = fn(a, b = a + 1, ...c) { [a, b, c] }; [f(1), f(1, 5, 6, 7)]", want: "[[1, 2, []], [1, 5, [6, 7]]]"},
+		{input: "let f = fn(x) { let [a, ..b] = [x, x]; while (a > 0) { let y = a; a = y - 1; b = [y, b] }; b }; f(2)", want: "[1, [2, [2]]]"},
+		{input: "let f = fn(x) { try { throw x } catch (e) { match (x) { {\"v\": v} => fn() { v + x[\"v\"] + len([e]) }() } } }; f({\"v\": 1})", want: "3"},
+		{input: "let f = fn(x) { let n = 0; while (n < x) { let m = n + 1; n = m }; try { throw n } catch (e) { match (e[\"value\"]) { 2 => n * 10, k => k } } }; [f(2), f(3)]", want: "[20, 3]"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := runInput(t, context.Background(), test.input)
+			if got.Inspect() != test.want {
+				t.Fatalf("result wrong. want=%q, got=%q", test.want, got.Inspect())
+			}
+			testSameResult(t, got, evalInput(t, test.input))
+		})
+	}
+}
+
+// TestRunGlobals runs programs one after the other in the same environment,
+// as the REPL does, with functions looking up the names of the top level
+// bound by later programs and in the environment enclosing it.
+func TestRunGlobals(t *testing.T) {
+	outer := object.NewEnvironment()
+	outer.Set("base", object.NewInteger(10))
+	env := object.NewEnclosedEnvironment(outer)
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: `let f = fn(x) { len(x) + base }; f("ab")`, want: "12"},
+		{input: `let base = 1; f("ab")`, want: "3"},
+		{input: `let len = fn(x) { 0 }; f("ab")`, want: "1"},
+		{input: `base = 5; f("ab")`, want: "5"},
+	}
+	for _, test := range tests {
+		bytecode, err := compile.Compile(parseInput(t, test.input))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got := Run(context.Background(), bytecode, env); got.Inspect() != test.want {
+			t.Fatalf("result of %q wrong. want=%q, got=%q", test.input, test.want, got.Inspect())
+		}
+	}
+}
+
+func TestRunMaxDepth(t *testing.T) {
+	tests := []struct {
+		input string
+		opts  []Option
+		want  string
+	}{
+		{input: "let f = fn(n) { 1 + f(n + 1) }; f(0)", opts: []Option{WithMaxDepth(10)}, want: "maximum recursion depth exceeded (10)"},
+		{input: "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(20000)", opts: []Option{WithMaxDepth(0)}, want: "20000"},
+		{input: "let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } }; loop(1000)", opts: []Option{WithMaxDepth(10)}, want: "0"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			bytecode, err := compile.Compile(parseInput(t, test.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := Run(context.Background(), bytecode, object.NewEnvironment(), test.opts...)
+			if err, ok := got.(*object.Error); ok {
+				if err.Message != test.want {
+					t.Fatalf("error message wrong. want=%q, got=%q", test.want, err.Message)
+				}
+				return
+			}
+			if got.Inspect() != test.want {
+				t.Fatalf("result wrong. want=%q, got=%q", test.want, got.Inspect())
+			}
+		})
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	tests := []struct {
+		input string
+	}{
+		{input: "while (true) { 1 }"},
+		{input: "let f = fn(n) { f(n + 1) }; f(0)"},
+		{input: "try { while (true) { 1 } } finally { while (true) { 1 } }"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			got := runInput(t, ctx, test.input)
+			err, ok := got.(*object.Error)
+			if !ok {
+				t.Fatalf("not Error: %+v", got)
+			}
+			if err.Kind != object.CanceledErrorKind {
+				t.Fatalf("error kind wrong. want=%q, got=%q", object.CanceledErrorKind, err.Kind)
+			}
+		})
+	}
+}
+
+// evaluateTestInputs returns the dog programs given as inputs to the tests of
+// the evaluator, except those of the tests in skipped.
+func evaluateTestInputs(t *testing.T, skipped map[string]bool) []string {
+	t.Helper()
+
+	file, err := parser.ParseFile(gotoken.NewFileSet(), "../evaluate/evaluate_test.go", nil, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var inputs []string
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*goast.FuncDecl)
+		if !ok || skipped[fn.Name.Name] {
+			continue
+		}
+		goast.Inspect(fn, func(node goast.Node) bool {
+			var value goast.Expr
+			switch node := node.(type) {
+			case *goast.KeyValueExpr:
+				if key, ok := node.Key.(*goast.Ident); ok && key.Name == "input" {
+					value = node.Value
+				}
+			case *goast.AssignStmt:
+				if key, ok := node.Lhs[0].(*goast.Ident); ok && key.Name == "input" && len(node.Rhs) == 1 {
+					value = node.Rhs[0]
+				}
+			}
+			if lit, ok := value.(*goast.BasicLit); ok && lit.Kind == gotoken.STRING {
+				input, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				inputs = append(inputs, input)
+			}
+			return true
+		})
+	}
+	if len(inputs) == 0 {
+		t.Fatalf("no inputs found in evaluate_test.go")
+	}
+	return inputs
+}
+
+// parseInput parses input, ignoring parse errors like the tests of the
+// evaluator do, so that both engines run what the parser recovered.
+func parseInput(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	return parse.NewParser(lex.NewLexer(input)).ParseProgram()
+}
+
+func testSameResult(t *testing.T, got, want object.Object) {
+	t.Helper()
+
+	if got.Type() != want.Type() {
+		t.Fatalf("type wrong. want=%s (%s), got=%s (%s)", want.Type(), want.Inspect(), got.Type(), got.Inspect())
+	}
+	if got.Inspect() != want.Inspect() {
+		t.Fatalf("result wrong. want=%q, got=%q", want.Inspect(), got.Inspect())
+	}
+	if wantErr, ok := want.(*object.Error); ok {
+		gotErr := got.(*object.Error)
+		if gotErr.Traceback() != wantErr.Traceback() {
+			t.Fatalf("traceback wrong. want=\n%s\ngot=\n%s", wantErr.Traceback(), gotErr.Traceback())
+		}
+	}
+}
+
+// benchmarkInput is a program spending its time on calls, loops and local
+// bindings, for comparing the engines.
+const benchmarkInput = `let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let sum = fn(n) { let total = 0; let i = 0; while (i < n) { total += i; i += 1 }; total };
+[fib(20), sum(100000)]`
+
+func BenchmarkEval(b *testing.B) {
+	program := parse.NewParser(lex.NewLexer(benchmarkInput)).ParseProgram()
+	for i := 0; i < b.N; i++ {
+		evaluate.Eval(program)
+	}
+}
+
+func BenchmarkVM(b *testing.B) {
+	bytecode, err := compile.Compile(parse.NewParser(lex.NewLexer(benchmarkInput)).ParseProgram())
+	if err != nil {
+		b.Fatalf("unexpected error: %s", err)
+	}
+	for i := 0; i < b.N; i++ {
+		Run(context.Background(), bytecode, object.NewEnvironment())
+	}
+}
+
+func evalInput(t *testing.T, input string) object.Object {
+	t.Helper()
+	return evaluate.Eval(parseInput(t, input))
+}
+
+func runInput(t *testing.T, ctx context.Context, input string) object.Object {
+	t.Helper()
+
+	bytecode, err := compile.Compile(parseInput(t, input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return Run(ctx, bytecode, object.NewEnvironment())
+}